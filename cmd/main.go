@@ -12,15 +12,15 @@ import (
 	"time"
 
 	_ "github.com/denisenkom/go-mssqldb/azuread"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/routes"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/websockets"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
-	"github.com/go-chi/httprate"
 	"github.com/lesismal/nbio/nbhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -48,9 +48,14 @@ func init() {
 
 	// NOTE: Initialize the most essential services only
 	// No doing so will effect the startup time of the container
-	connector.InitRedis(&e)
-	connector.InitKafkaWriters(&e)
+	connector.InitState(&e)
+	connector.InitPartitions(&e)
+	connector.InitBus(&e)
+	connector.InitHub()
 	connector.InitDB(&e)
+	connector.InitArchive(&e)
+	connector.InitGeocoder(&e)
+	connector.InitRouter(&e)
 
 	rt = routes.Route{
 		E: &e,
@@ -64,17 +69,15 @@ func init() {
 
 func router() *chi.Mux {
 	r := chi.NewRouter()
+	r.NotFound(middlewares.NotFound)
+	r.MethodNotAllowed(middlewares.MethodNotAllowed)
 
 	r.Use(middlewares.RequestID)
 	r.Use(middlewares.RealIP)
 	r.Use(middlewares.Logger)
 	r.Use(middlewares.Recoverer)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"https://*", "http://*"},
-		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowedHeaders: []string{"Content-Type", "X-CSRF-Token"},
-	}))
-	r.Use(httprate.LimitByIP(100, 1*time.Minute))
+	r.Use(lib.WrapMiddleware(middlewares.CORS, &e, &connector))
+	r.Use(lib.WrapMiddleware(middlewares.RateLimit, &e, &connector))
 
 	r.Mount("/", rt.Routes())
 	r.Mount("/ws", ws.Websocket())
@@ -112,9 +115,24 @@ func main() {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, syscall.SIGHUP)
+	go func() {
+		for range reloadCh {
+			if err := e.Reload(); err != nil {
+				log.Error().Err(err).Msg("failed to reload the configuration, keeping the current settings")
+				continue
+			}
+
+			log.Info().Msg("reloaded the configuration")
+		}
+	}()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go services.WatchSignals(ctx, &e, &connector)
+
 	log.Info().
 		Msgf("port : %d\t starting .... ", e.Port)
 	go func() {
@@ -1,10 +1,14 @@
 package lib
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strconv"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/bytedance/sonic"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
@@ -14,7 +18,8 @@ type RedisBookingID int
 // [
 //  partition_no,
 //  kafka_last_offset,
-//  driver_id
+//  driver_id,
+//  topic
 // ]
 
 const (
@@ -24,31 +29,78 @@ const (
 	BookingIDLastOffset
 	// BookingIDDriverID is used to get the BookingIDDriverID of the driver in the active booking ID
 	BookingIDDriverID
+	// BookingIDTopic is used to get the topic of the partition under the booking ID
+	BookingIDTopic
 
 	// BookingIDSize is used to get the booking ID size in Redis
 	BookingIDSize
 )
 
+// BookingIDValue is a single value of the booking ID array
+type BookingIDValue string
+
+// UnmarshalJSON is used to read a value of the booking ID array as either a string or a number, the arrays that are
+// stored before the slots had a topic contain numbers instead of strings
+func (v *BookingIDValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var val string
+		if err := sonic.Unmarshal(data, &val); err != nil {
+			return err
+		}
+		*v = BookingIDValue(val)
+		return nil
+	}
+
+	number, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid booking ID value %s: %w", data, err)
+	}
+	*v = BookingIDValue(strconv.FormatInt(number, 10))
+
+	return nil
+}
+
 // NewBookingID is a function that is used to create a new booking ID array
-func NewBookingID() [BookingIDSize]int {
-	return [BookingIDSize]int{}
+func NewBookingID() [BookingIDSize]BookingIDValue {
+	return [BookingIDSize]BookingIDValue{}
 }
 
 // SetBookingID is a function that is used to create a fully populated BookingID array
 func SetBookingID(
-	partitionNo,
+	slot partitions.Slot,
 	lastOffset,
 	driverID int,
-) [BookingIDSize]int {
+) [BookingIDSize]BookingIDValue {
 	BookingID := NewBookingID()
 
-	BookingID[BookingIDPartitionNo] = partitionNo
-	BookingID[BookingIDLastOffset] = lastOffset
-	BookingID[BookingIDDriverID] = driverID
+	BookingID[BookingIDPartitionNo] = BookingIDValue(fmt.Sprint(slot.Partition))
+	BookingID[BookingIDLastOffset] = BookingIDValue(fmt.Sprint(lastOffset))
+	BookingID[BookingIDDriverID] = BookingIDValue(fmt.Sprint(driverID))
+	BookingID[BookingIDTopic] = BookingIDValue(slot.Topic)
 
 	return BookingID
 }
 
+// GetBookingID is a function that is used to parse the slot, last offset and the driver ID
+// from the BookingID array, the bookings that are created before the slots had a topic are in the given primary topic
+func GetBookingID(BookingID [BookingIDSize]BookingIDValue, topic string) (slot partitions.Slot, lastOffset int64, driverID int, err error) {
+	slot, err = getSlot(string(BookingID[BookingIDTopic]), string(BookingID[BookingIDPartitionNo]), topic)
+	if err != nil {
+		return partitions.Slot{}, 0, 0, err
+	}
+	lastOffset, err = strconv.ParseInt(string(BookingID[BookingIDLastOffset]), 10, 64)
+	if err != nil {
+		return partitions.Slot{}, 0, 0, err
+	}
+	driverID, err = strconv.Atoi(string(BookingID[BookingIDDriverID]))
+	if err != nil {
+		return partitions.Slot{}, 0, 0, err
+	}
+
+	return slot, lastOffset, driverID, nil
+}
+
 // RedisN is used to represent the backup that is stored regarding the booking
 // this is used to get information regarding the booking if the driver/admin fails to
 // clear the stream
@@ -94,7 +146,8 @@ type RedisDriverID int
 // [
 //  driver_token_id,
 //  booking_id,
-//  partition_no
+//  partition_no,
+//  topic
 // ]
 
 const (
@@ -104,6 +157,8 @@ const (
 	DriverIDBookingID
 	// DriverIDPartitionNo is used to get the partition number of the given driver
 	DriverIDPartitionNo
+	// DriverIDTopic is used to get the topic of the partition of the given driver
+	DriverIDTopic
 
 	// DriverIDSize is used to get the size of the driver ID array
 	DriverIDSize
@@ -118,53 +173,52 @@ func NewDriverID() [DriverIDSize]string {
 func SetDriverID(
 	driverToken,
 	bookingID string,
-	partitionNo int,
+	slot partitions.Slot,
 ) [DriverIDSize]string {
 	DriverID := NewDriverID()
 
 	DriverID[DriverIDDriverToken] = driverToken
 	DriverID[DriverIDBookingID] = bookingID
-	DriverID[DriverIDPartitionNo] = fmt.Sprint(partitionNo)
+	DriverID[DriverIDPartitionNo] = fmt.Sprint(slot.Partition)
+	DriverID[DriverIDTopic] = slot.Topic
 
 	return DriverID
 }
 
-// DelBooking is a function that is used to remove a booking and all its related components
-// from the redis database
-func DelBooking(
-	ctx context.Context,
-	client *redis.Client,
-	driverID int,
-	bookingID string,
-	partition int,
-) error {
-	pipe := client.Pipeline()
-
-	pipe.Del(ctx, fmt.Sprint(driverID))
-	pipe.Del(ctx, bookingID)
-	pipe.Del(ctx, L(partition))
-	pipe.Del(ctx, C(partition))
-	pipe.Del(ctx, N(partition))
+// GetDriverIDSlot is a function that is used to parse the slot from the DriverID array, the drivers that are
+// assigned before the slots had a topic are in the given primary topic
+func GetDriverIDSlot(DriverID [DriverIDSize]string, topic string) (partitions.Slot, error) {
+	return getSlot(DriverID[DriverIDTopic], DriverID[DriverIDPartitionNo], topic)
+}
 
-	_, err := pipe.Exec(ctx)
+// getSlot is used to parse the slot of the given topic and partition, the arrays that are stored before the slots
+// had a topic do not contain the topic so the given primary topic is used instead
+func getSlot(topic, partitionNo, primary string) (partitions.Slot, error) {
+	if topic == "" {
+		topic = primary
+	}
+	partition, err := strconv.Atoi(partitionNo)
 	if err != nil {
-		return err
+		return partitions.Slot{}, err
+	}
+	if partition < 0 {
+		return partitions.Slot{}, partitions.ErrInvalidSlot
 	}
 
-	return nil
+	return partitions.Slot{
+		Topic:     topic,
+		Partition: partition,
+	}, nil
 }
 
-// Free is used to deallocate the used partition for upcomming jobs
-func Free(ctx context.Context, client *redis.Client, key string, partition int) {
-	pipe := client.Pipeline()
-	pipe.Del(ctx, L(partition))
-	pipe.SRem(ctx, key, partition)
-	_, err := pipe.Exec(ctx)
+// Free is used to deallocate the used slot for upcomming jobs
+func Free(ctx context.Context, allocator *partitions.Allocator, slot partitions.Slot) {
+	err := allocator.Release(ctx, slot)
 	if err != nil {
 		log.Error().Err(err).
 			Msgf(
-				"partition : %d\tfailed to remove the partition",
-				partition,
+				"slot : %s\tfailed to remove the slot",
+				slot,
 			)
 	}
 }
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+func TestGetBookingID(t *testing.T) {
+	slot := partitions.Slot{Topic: "extra", Partition: 3}
+
+	// the bookings that are created before the slots had a topic are stored as an array of numbers
+	cases := map[string]struct {
+		val  string
+		want partitions.Slot
+	}{
+		"topic":      {val: `["3","10","7","extra"]`, want: slot},
+		"old format": {val: `[3,10,7]`, want: partitions.Slot{Topic: "locations", Partition: 3}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			BookingID := NewBookingID()
+			if err := sonic.UnmarshalString(tc.val, &BookingID); err != nil {
+				t.Fatal(err)
+			}
+
+			got, lastOffset, driverID, err := GetBookingID(BookingID, "locations")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want || lastOffset != 10 || driverID != 7 {
+				t.Errorf("got %s, %d, %d, want %s, 10, 7", got, lastOffset, driverID, tc.want)
+			}
+		})
+	}
+
+	// the booking round trips with its topic
+	val, err := sonic.MarshalString(SetBookingID(slot, 10, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	BookingID := NewBookingID()
+	if err := sonic.UnmarshalString(val, &BookingID); err != nil {
+		t.Fatal(err)
+	}
+	got, lastOffset, driverID, err := GetBookingID(BookingID, "locations")
+	if err != nil || got != slot || lastOffset != 10 || driverID != 7 {
+		t.Errorf("got %s, %d, %d, %v, want %s, 10, 7", got, lastOffset, driverID, err, slot)
+	}
+
+	// the values that are neither a string nor a number are rejected
+	if err := sonic.UnmarshalString(`[3,true,7]`, &BookingID); err == nil {
+		t.Errorf("want a boolean value to be rejected")
+	}
+}
+
+func TestGetDriverIDSlot(t *testing.T) {
+	DriverID := NewDriverID()
+	if err := sonic.UnmarshalString(`["token","B1","2"]`, &DriverID); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetDriverIDSlot(DriverID, "locations")
+	if err != nil || got != (partitions.Slot{Topic: "locations", Partition: 2}) {
+		t.Errorf("got %s, %v, want the partition 2 of the primary topic", got, err)
+	}
+
+	DriverID[DriverIDPartitionNo] = "-1"
+	if _, err := GetDriverIDSlot(DriverID, "locations"); err == nil {
+		t.Errorf("want a negative partition to be rejected")
+	}
+}
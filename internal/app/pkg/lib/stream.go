@@ -19,3 +19,25 @@ const (
 	// DefaultStatus is the default status when the status is not provided
 	DefaultStatus JobStatus = OnTheWay
 )
+
+// transitions contains the statuses that a job can move to from every status, a job can skip the statuses
+// in between as long as it moves forward and it can be cleared from any status
+var transitions = map[JobStatus][]JobStatus{
+	NotAccepted:      {Accepted, OnTheWay, PickupPoint, PassengerOnBoard, Clear},
+	Accepted:         {OnTheWay, PickupPoint, PassengerOnBoard, Clear},
+	OnTheWay:         {PickupPoint, PassengerOnBoard, Clear},
+	PickupPoint:      {PassengerOnBoard, Clear},
+	PassengerOnBoard: {Clear},
+	Clear:            {},
+}
+
+// CanTransition is used to check wether a job with the status can move to the given status
+func (status JobStatus) CanTransition(to JobStatus) bool {
+	for _, next := range transitions[status] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package lib
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	statuses := []JobStatus{NotAccepted, Accepted, OnTheWay, PickupPoint, PassengerOnBoard, Clear}
+
+	cases := []struct {
+		from, to JobStatus
+		want     bool
+	}{
+		{NotAccepted, Accepted, true},
+		{NotAccepted, OnTheWay, true},
+		{NotAccepted, PickupPoint, true},
+		{NotAccepted, PassengerOnBoard, true},
+		{Accepted, OnTheWay, true},
+		{Accepted, PassengerOnBoard, true},
+		{OnTheWay, PickupPoint, true},
+		{OnTheWay, PassengerOnBoard, true},
+		{PickupPoint, PassengerOnBoard, true},
+		{OnTheWay, Accepted, false},
+		{PickupPoint, OnTheWay, false},
+		{PassengerOnBoard, PickupPoint, false},
+		{PassengerOnBoard, NotAccepted, false},
+		{Accepted, Accepted, false},
+	}
+	for _, tc := range cases {
+		if got := tc.from.CanTransition(tc.to); got != tc.want {
+			t.Errorf("%d -> %d: got %t, want %t", tc.from, tc.to, got, tc.want)
+		}
+	}
+
+	// a job can be cleared from any status and nothing follows a cleared job
+	for _, status := range statuses {
+		if status != Clear && !status.CanTransition(Clear) {
+			t.Errorf("%d -> %d: want the job to be cleared", status, Clear)
+		}
+		if Clear.CanTransition(status) {
+			t.Errorf("%d -> %d: want a cleared job to stay cleared", Clear, status)
+		}
+	}
+
+	// the table agrees with its rule, every status moves forward only
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := from != Clear && to > from
+			if got := from.CanTransition(to); got != want {
+				t.Errorf("%d -> %d: got %t, want %t", from, to, got, want)
+			}
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/bytedance/sonic"
@@ -15,6 +14,8 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	_errors "github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
@@ -25,8 +26,8 @@ type (
 	DriverIDCtx string
 	// BookingIDCtx contains the type for the booking ID type
 	BookingIDCtx string
-	// PartitionNoCtx contains the type for the partition no type
-	PartitionNoCtx string
+	// SlotCtx contains the type for the slot (topic and partition) type
+	SlotCtx string
 	// AdminIDCtx contains the type for the Admin ID
 	AdminIDCtx string
 )
@@ -38,8 +39,8 @@ const (
 	DriverID DriverIDCtx = "driver_id"
 	// BookingID is a key to notate the booking id
 	BookingID BookingIDCtx = "booking_id"
-	// PartitionNo is a key to notate the partition number
-	PartitionNo PartitionNoCtx = "partition_no"
+	// Slot is a key to notate the slot (topic and partition)
+	Slot SlotCtx = "slot"
 	// AdminID is a key to indicate the admin id
 	AdminID AdminIDCtx = "admin_id"
 )
@@ -57,7 +58,7 @@ func IsDriver(next http.Handler, e *env.Env, c *connections.C) http.Handler {
 			driverTokenC, err := r.Cookie(e.DriverCookieName)
 			if err != nil {
 				log.Error().Err(err).Msg("failed to read the cookie")
-				http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, unauthorizedErr)
 				return
 			}
 
@@ -71,7 +72,7 @@ func IsDriver(next http.Handler, e *env.Env, c *connections.C) http.Handler {
 					r.Header.Clone(),
 					r.Header.Get("Authorization"),
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
@@ -84,12 +85,12 @@ func IsDriver(next http.Handler, e *env.Env, c *connections.C) http.Handler {
 					"driver_token : %s\tfailed to authenticate the driver",
 					driverToken,
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 		driverID, _, err := dt.Get(token)
 		if err != nil {
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
@@ -116,7 +117,7 @@ func IsBookingTokenValid(
 			bookingTokenC, err := r.Cookie(e.BookingCookieName)
 			if err != nil {
 				log.Error().Err(err).Msg("failed to read the cookie")
-				http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, unauthorizedErr)
 				return
 			}
 
@@ -130,7 +131,7 @@ func IsBookingTokenValid(
 					r.Header.Clone(),
 					r.Header.Get("Authorization"),
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
@@ -143,18 +144,18 @@ func IsBookingTokenValid(
 					"booking_token : %s\tfailed to validate the booking token",
 					bookingToken,
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
-		bookingTokenID, driverID, bookingID, partitionNo, err := bt.Get(token)
+		bookingTokenID, driverID, bookingID, slot, err := bt.Get(token)
 		if err != nil {
 			log.Error().
 				Msgf(
 					"booking_token : %s\tfailed to validate the booking token",
 					bookingToken,
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
@@ -163,7 +164,7 @@ func IsBookingTokenValid(
 		ctx = context.WithValue(ctx, BookingTokenID, bookingTokenID)
 		ctx = context.WithValue(ctx, DriverID, driverID)
 		ctx = context.WithValue(ctx, BookingID, bookingID)
-		ctx = context.WithValue(ctx, PartitionNo, partitionNo)
+		ctx = context.WithValue(ctx, Slot, slot)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -191,7 +192,7 @@ func ValidateDriverOrBookingToken(
 					r.Header.Clone(),
 					r.Header.Get("Authorization"),
 				)
-			http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, unauthorizedErr)
 			return
 		}
 
@@ -205,7 +206,7 @@ func ValidateDriverOrBookingToken(
 			if !isValid {
 				log.Error().
 					Msgf("token : %s\tfailed to validate the token", token)
-				http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, unauthorizedErr)
 				return
 			}
 
@@ -213,21 +214,21 @@ func ValidateDriverOrBookingToken(
 		}
 
 		var (
-			bookingID   string
-			driverID    int
-			partitionNo int
-			err         error
+			bookingID string
+			driverID  int
+			slot      partitions.Slot
+			err       error
 		)
 
 		if isBookingToken {
-			_, driverID, bookingID, partitionNo, err = bt.Get(tk)
+			_, driverID, bookingID, slot, err = bt.Get(tk)
 			if err != nil {
 				log.Error().Err(err).
 					Msgf(
 						"booking_token : %s\tfailed to get the booking token details",
 						token,
 					)
-				http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, unauthorizedErr)
 				return
 			}
 		} else {
@@ -238,20 +239,18 @@ func ValidateDriverOrBookingToken(
 						"driver_token : %s\tfailed to validate the booking token",
 						token,
 					)
-				http.Error(w, unauthorizedErr.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, unauthorizedErr)
 				return
 			}
 
-			client := c.R.DB
-
-			val := client.Get(r.Context(), fmt.Sprint(driverID)).Val()
+			val, _ := c.State.GetDriver(r.Context(), driverID)
 			if val == "" {
 				log.Error().
 					Msgf(
 						"driver_token : %s\tfailed to get value from Redis",
 						token,
 					)
-				http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+				lib.ErrorResponse(w, r, _errors.ErrServer)
 				return
 			}
 			DriverID := _lib.NewDriverID()
@@ -263,21 +262,22 @@ func ValidateDriverOrBookingToken(
 						token,
 						val,
 					)
-				http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+				lib.ErrorResponse(w, r, _errors.ErrServer)
 				return
 			}
 
 			bookingID = DriverID[_lib.DriverIDBookingID]
-			partitionNo, err = strconv.Atoi(DriverID[_lib.DriverIDPartitionNo])
+			slot, err = _lib.GetDriverIDSlot(DriverID, e.Topic)
 			if err != nil {
 				log.Error().Err(err).
 					Msgf(
-						"driver_token : %s\tbooking_id : %s\tpartition : %s\tfailed to convert partition number to int",
+						"driver_token : %s\tbooking_id : %s\ttopic : %s\tpartition : %s\tfailed to parse the slot",
 						token,
 						bookingID,
+						DriverID[_lib.DriverIDTopic],
 						DriverID[_lib.DriverIDPartitionNo],
 					)
-				http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+				lib.ErrorResponse(w, r, _errors.ErrServer)
 				return
 			}
 		}
@@ -286,13 +286,18 @@ func ValidateDriverOrBookingToken(
 
 		ctx = context.WithValue(ctx, DriverID, driverID)
 		ctx = context.WithValue(ctx, BookingID, bookingID)
-		ctx = context.WithValue(ctx, PartitionNo, partitionNo)
+		ctx = context.WithValue(ctx, Slot, slot)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 func isSuperAdmin(r *http.Request, e *env.Env, _ *connections.C) (isAdmin bool, err error) {
+	if !e.HasEdgeConfig() {
+		// the secret of the super admin is stored in the edge config
+		return false, nil
+	}
+
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://edge-config.vercel.com/%s/item/secret", e.EdgeConfig), nil)
 	if err != nil {
 		return false, err
@@ -338,16 +343,16 @@ func IsSuperAdmin(next http.Handler, e *env.Env, c *connections.C) http.Handler
 		isSuperAdmin, err := isSuperAdmin(r, e, c)
 		if err != nil {
 			if errors.Is(err, _errors.ErrUnauthorized) {
-				http.Error(w, _errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+				lib.ErrorResponse(w, r, _errors.ErrUnauthorized)
 				return
 			}
 
 			log.Error().Err(err).Msg("failed to validate the superadmin")
-			http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+			lib.ErrorResponse(w, r, _errors.ErrServer)
 			return
 		}
 		if !isSuperAdmin {
-			http.Error(w, _errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, _errors.ErrUnauthorized)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -403,7 +408,7 @@ func IsAdminOrIsSuperAdmin(next http.Handler, e *env.Env, c *connections.C) http
 
 		_, err := getAdmin(r, e, c)
 		if err != nil {
-			http.Error(w, _errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, _errors.ErrUnauthorized)
 			return
 		}
 
@@ -416,7 +421,7 @@ func IsAdmin(next http.Handler, e *env.Env, c *connections.C) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		adminID, err := getAdmin(r, e, c)
 		if err != nil {
-			http.Error(w, _errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, _errors.ErrUnauthorized)
 			return
 		}
 
@@ -432,7 +437,7 @@ func IsCron(next http.Handler, e *env.Env, c *connections.C) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		secret := r.URL.Query().Get("secret")
 		if secret != e.AdminSecret {
-			http.Error(w, _errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			lib.ErrorResponse(w, r, _errors.ErrUnauthorized)
 			return
 		}
 
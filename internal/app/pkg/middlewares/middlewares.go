@@ -3,10 +3,18 @@ package middlewares
 
 import (
 	"net/http"
+	"runtime/debug"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/go-chi/httprate"
 	"github.com/rs/zerolog/log"
 )
 
@@ -38,13 +46,98 @@ func Logger(next http.Handler) http.Handler {
 	return middleware.Logger(next)
 }
 
-// Recoverer is a middleware that recovers from panics, logs the panic (and a backtrace), and returns a HTTP 500 (Internal Server Error) status if possible. Recoverer prints a request ID if one is provided.
+// Recoverer is a middleware that recovers from panics, logs the panic (and a backtrace), and returns a HTTP 500 (Internal Server Error) problem response if possible. Recoverer prints a request ID if one is provided.
 //
-// Alternatively, look at [https://github.com/go-chi/httplog](https://github.com/go-chi/httplog) middleware pkgs.
-//
-// [`middleware.Recoverer` on pkg.go.dev](https://pkg.go.dev/github.com/go-chi/chi/v5@v5.0.12/middleware#Recoverer)
+// This is adapted from [`middleware.Recoverer` on pkg.go.dev](https://pkg.go.dev/github.com/go-chi/chi/v5@v5.0.12/middleware#Recoverer)
 func Recoverer(next http.Handler) http.Handler {
-	return middleware.Recoverer(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				if rvr == http.ErrAbortHandler {
+					// the response to the client is aborted, this should not be recovered or logged
+					panic(rvr)
+				}
+
+				if logEntry := middleware.GetLogEntry(r); logEntry != nil {
+					logEntry.Panic(rvr, debug.Stack())
+				} else {
+					middleware.PrintPrettyStack(rvr)
+				}
+
+				if r.Header.Get("Connection") != "Upgrade" {
+					lib.ErrorResponse(w, r, errors.ErrServer)
+				}
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit is a middleware that limits the number of requests that a single IP address can make within the
+// rate limit window of the settings, the limiter is replaced when the settings are reloaded
+func RateLimit(next http.Handler, e *env.Env, _ *connections.C) http.Handler {
+	type limiter struct {
+		handler  http.Handler
+		requests int
+		window   int
+	}
+
+	var current atomic.Pointer[limiter]
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := e.Settings()
+
+		l := current.Load()
+		if l == nil || l.requests != settings.RateLimit || l.window != settings.RateLimitWindow {
+			l = &limiter{
+				requests: settings.RateLimit,
+				window:   settings.RateLimitWindow,
+				handler: httprate.Limit(
+					settings.RateLimit,
+					time.Duration(settings.RateLimitWindow)*time.Second,
+					httprate.WithKeyFuncs(httprate.KeyByIP),
+					httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
+						lib.ErrorResponse(w, r, errors.ErrTooManyRequests)
+					}),
+				)(next),
+			}
+			current.Store(l)
+		}
+
+		l.handler.ServeHTTP(w, r)
+	})
+}
+
+// CORS is a middleware that is used to handle the cross origin requests from the allowed origins of the settings
+func CORS(next http.Handler, e *env.Env, _ *connections.C) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowOriginFunc: func(_ *http.Request, origin string) bool {
+			for _, allowed := range e.Settings().CORSOrigins {
+				prefix, suffix, found := strings.Cut(allowed, "*")
+				if !found && origin == allowed {
+					return true
+				}
+				if found && len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+					return true
+				}
+			}
+
+			return false
+		},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowedHeaders: []string{"Content-Type", "X-CSRF-Token"},
+	})(next)
+}
+
+// NotFound is a handler that is used to respond to the requests to routes that do not exist
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	lib.ErrorResponse(w, r, errors.ErrNotFound)
+}
+
+// MethodNotAllowed is a handler that is used to respond to the requests with a method that the route does not support
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	lib.ErrorResponse(w, r, errors.ErrMethodNotAllowed)
 }
 
 // IsContentJSON is a middleware that checks wether the application content is json
@@ -57,15 +150,30 @@ func IsContentJSON(next http.Handler) http.Handler {
 					"Content-Type : %s\tinvalid content type provided",
 					contentType,
 				)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			sonic.ConfigDefault.NewEncoder(w).Encode(
-				map[string]interface{}{
-					"message": "only conent type of application/json is allowed",
-				})
+			lib.ErrorResponse(w, r, errors.ErrUnsupportedContentType)
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// ProtobufContentType is the content type of the requests that are encoded in protocol buffers
+const ProtobufContentType = "application/x-protobuf"
+
+// IsProtobuf is a function that is used to check wether the content of the given request is protocol buffers
+func IsProtobuf(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), ProtobufContentType)
+}
+
+// IsContentJSONOrProtobuf is a middleware that checks wether the application content is json or protocol buffers
+func IsContentJSONOrProtobuf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsProtobuf(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		IsContentJSON(next).ServeHTTP(w, r)
+	})
+}
@@ -23,6 +23,7 @@ func Router(e *env.Env, c *connections.C) http.Handler {
 	r.Route("/", func(r chi.Router) {
 		r.Use(m(middlewares.IsAdminOrIsSuperAdmin, e, c))
 		r.Get("/", h(index, e, c))
+		r.Get("/deviation/{booking_id}", h(deviation, e, c))
 	})
 
 	r.Get("/view/{booking_id}", h(view, e, c))
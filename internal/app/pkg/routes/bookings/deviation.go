@@ -0,0 +1,57 @@
+package bookings
+
+import (
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	_errors "github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// deviation is a route that is used to get the expected route of an active booking along with the times
+// that the driver left and returned to it
+func deviation(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	bookingID := chi.URLParam(r, "booking_id")
+	if bookingID == "" {
+		lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
+		return
+	}
+
+	val, _ := c.State.GetBooking(r.Context(), bookingID)
+	if val == "" {
+		lib.ErrorResponse(w, r, _errors.ErrBookingNotFound)
+		return
+	}
+	BookingID := _lib.NewBookingID()
+	err := sonic.UnmarshalString(val, &BookingID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal the value from Redis")
+		lib.ErrorResponse(w, r, _errors.ErrServer)
+		return
+	}
+	slot, _, _, err := _lib.GetBookingID(BookingID, e.Topic)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tredis_value : %s\tfailed to parse the booking",
+				bookingID,
+				val,
+			)
+		lib.ErrorResponse(w, r, _errors.ErrServer)
+		return
+	}
+
+	state := services.GetDeviation(r.Context(), c, slot)
+
+	lib.JSONResponseWInterface(w, http.StatusOK, map[string]any{
+		"route":      state.Route,
+		"deviated":   state.Deviated,
+		"deviations": state.Events,
+	})
+}
@@ -8,14 +8,14 @@ import (
 	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
-	"github.com/redis/go-redis/v9"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
 func index(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	bookingID := ""
-	client := c.R.DB
 
 	response := struct {
 		Active   []string `json:"active"`
@@ -25,16 +25,21 @@ func index(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C)
 		Inactive: []string{},
 	}
 
-	jobs := client.SMembers(r.Context(), e.PartitionManagerKey).Val()
+	jobs, err := c.State.Partitions(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get the partitions that are in use")
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
 	if len(jobs) == 0 {
 		lib.JSONResponseWInterface(w, http.StatusOK, response)
 		return
 	}
 
 	for _, job := range jobs {
-		val := client.Get(r.Context(), _lib.C(job)).Val()
+		val, _ := c.State.GetViewers(r.Context(), job)
 		if val == "" {
-			bookingID = getBookingID(r.Context(), e, client, job)
+			bookingID = getBookingID(r.Context(), c, job)
 			if bookingID == "" {
 				continue
 			}
@@ -43,15 +48,15 @@ func index(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C)
 			continue
 		}
 
-		bookingID = getBookingID(r.Context(), e, client, job)
+		bookingID = getBookingID(r.Context(), c, job)
 		response.Active = append(response.Active, bookingID)
 	}
 
 	lib.JSONResponseWInterface(w, http.StatusOK, response)
 }
 
-func getBookingID(ctx context.Context, e *env.Env, client *redis.Client, job string) (bookingID string) {
-	val := client.Get(ctx, _lib.N(job)).Val()
+func getBookingID(ctx context.Context, c *connections.C, job partitions.Slot) (bookingID string) {
+	val, _ := c.State.GetPartition(ctx, job)
 	if val == "" {
 		log.Warn().
 			Msgf(
@@ -60,14 +65,7 @@ func getBookingID(ctx context.Context, e *env.Env, client *redis.Client, job str
 				val,
 			)
 
-		err := client.SRem(ctx, e.PartitionManagerKey, job).Err()
-		if err != nil {
-			log.Error().Err(err).
-				Msgf(
-					"job : %s\tfailed to delete the job from the job manager",
-					job,
-				)
-		}
+		_lib.Free(ctx, c.Partitions, job)
 		return ""
 	}
 
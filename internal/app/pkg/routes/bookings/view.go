@@ -1,12 +1,14 @@
 package bookings
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
@@ -18,21 +20,6 @@ import (
 
 // TODO: remove the usage of the google geocode API and replace it with the proper latitude and longitude cordinates
 
-type bookingDetails struct {
-	JobStatus       *int
-	BookTotal       *float64
-	BookRefNo       *string
-	BookPassengerNm *string
-	BookingContact  *string
-	BookPickUpAddr  *string
-	BookDropAddr    *string
-	DriverName      *string
-	DriverContact   *string
-	VehicleRegNo    *string
-	VehicleModel    *string
-	VehicleColor    *string
-}
-
 type geo struct {
 	Lat float64 `json:"lat"`
 	Lon float64 `json:"lon"`
@@ -42,124 +29,84 @@ type geo struct {
 func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	bookingID := chi.URLParam(r, "booking_id")
 	if bookingID == "" {
-		lib.JSONResponse(w, http.StatusBadRequest, _errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
 		return
 	}
 
 	started := false
-	val := c.R.DB.Get(r.Context(), bookingID).Val()
+	val, _ := c.State.GetBooking(r.Context(), bookingID)
 	if val != "" {
 		started = true
 	}
-	var payload bookingDetails
-
-	query := `
-SELECT
-	bookings.BookRefNo,
-	bookings.BookPassengerNm,
-	bookings.BookingContact,
-	bookings.BookPickUpAddr,
-	bookings.BookDropAddr,
-  bookings.JobStatus,
-	bookings.BookTotal,
-	bookings.DriverName,
-	bookings.DriverContact,
-	vehicles.VehicleRegNo,
-	vehicles.VehicleModel,
-	vehicles.VehicleColor
-FROM
-	Tbl_BookingDetails bookings
-	INNER JOIN Tbl_VehicleDetails vehicles ON bookings.VehicleId = vehicles.VehicleId
-WHERE
-	bookings.BookRefNo = @BookRefNo
-`
-
-	err := c.DB.QueryRow(query, sql.Named("BookRefNo", bookingID)).Scan(
-		&payload.BookRefNo,
-		&payload.BookPassengerNm,
-		&payload.BookingContact,
-		&payload.BookPickUpAddr,
-		&payload.BookDropAddr,
-		&payload.JobStatus,
-		&payload.BookTotal,
-		&payload.DriverName,
-		&payload.DriverContact,
-		&payload.VehicleRegNo,
-		&payload.VehicleModel,
-		&payload.VehicleColor,
-	)
+	booking, err := c.Bookings.GetBooking(r.Context(), bookingID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			lib.JSONResponse(w, http.StatusNotFound, _errors.ErrBookingIDNotValid.Error())
+		if errors.Is(err, connections.ErrBookingNotFound) {
+			lib.ErrorResponse(w, r, _errors.ErrBookingNotFound)
 			return
 		}
 
 		log.Error().Err(err).Msg("failed to get the query from the database")
-		lib.JSONResponse(w, http.StatusInternalServerError, _errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, _errors.ErrServer)
 		return
 	}
 
 	JobFinished := true
-	BookTotal := 0.0
-	BookPassengerNm := ""
-	BookingContact := ""
-	DriverName := ""
-	DriverContact := ""
-	VehicleRegNo := ""
-	VehicleModel := ""
-	VehicleColor := ""
-
-	if payload.BookPassengerNm != nil {
-		BookPassengerNm = *payload.BookPassengerNm
-	}
-	if payload.BookingContact != nil {
-		BookingContact = *payload.BookingContact
-	}
-	if payload.BookTotal != nil {
-		BookTotal = *payload.BookTotal
-	}
-	if payload.JobStatus != nil {
-		JobFinished = *payload.JobStatus == 5
-	}
-	if payload.DriverName != nil {
-		DriverName = *payload.DriverName
-	}
-	if payload.DriverContact != nil {
-		DriverContact = *payload.DriverContact
-	}
-	if payload.VehicleRegNo != nil {
-		VehicleRegNo = *payload.VehicleRegNo
-	}
-	if payload.VehicleModel != nil {
-		VehicleModel = *payload.VehicleModel
-	}
-	if payload.VehicleColor != nil {
-		VehicleColor = *payload.VehicleColor
+	if booking.JobStatus != nil {
+		JobFinished = *booking.JobStatus == 5
 	}
 
 	pickups := []services.Geo{}
-	if payload.BookPickUpAddr != nil {
-		pickups = append(pickups, services.Geocode(r.Context(), e, c, true, lib.Seperator(*payload.BookPickUpAddr, "|"))...)
+	if booking.PickupAddr != "" {
+		pickups, err = services.Geocode(r.Context(), c, lib.Seperator(booking.PickupAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the pickup addresses",
+					bookingID,
+				)
+		}
 	}
 
 	dropoffs := []services.Geo{}
-	if payload.BookDropAddr != nil {
-		dropoffs = append(dropoffs, services.Geocode(r.Context(), e, c, true, lib.Seperator(*payload.BookDropAddr, "|"))...)
+	if booking.DropAddr != "" {
+		dropoffs, err = services.Geocode(r.Context(), c, lib.Seperator(booking.DropAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the dropoff addresses",
+					bookingID,
+				)
+		}
 	}
 
 	data := map[string]any{}
 	data["active"] = started
-	data["name"] = BookPassengerNm
-	data["contact_no"] = BookingContact
-	data["total"] = BookTotal
-	data["driver_name"] = DriverName
-	data["driver_contact"] = DriverContact
-	data["vehicle_registration_no"] = VehicleRegNo
-	data["vehicle_modal"] = VehicleModel
-	data["vehicle_color"] = VehicleColor
+	data["name"] = booking.PassengerName
+	data["contact_no"] = booking.Contact
+	data["total"] = booking.Total
+	data["driver_name"] = booking.Driver.Name
+	data["driver_contact"] = booking.Driver.Contact
+	data["vehicle_registration_no"] = booking.Vehicle.RegNo
+	data["vehicle_modal"] = booking.Vehicle.Model
+	data["vehicle_color"] = booking.Vehicle.Color
 	data["pickups"] = pickups
 	data["dropoffs"] = dropoffs
 	if started {
+		data["timeline"] = []types.Transition{}
+
+		BookingID := _lib.NewBookingID()
+		if err := sonic.UnmarshalString(val, &BookingID); err == nil {
+			if slot, _, _, err := _lib.GetBookingID(BookingID, e.Topic); err == nil {
+				job := services.GetJobState(r.Context(), c, slot)
+				if job.Status != nil {
+					data["status"] = *job.Status
+				}
+				if len(job.Timeline) > 0 {
+					data["timeline"] = job.Timeline
+				}
+			}
+		}
+
 		if e.Env == string(enums.Dev) {
 			data["stream"] = fmt.Sprintf("ws://%s/ws/stream/view/%s", e.WebsocketURL, bookingID)
 		} else {
@@ -13,9 +13,11 @@ import (
 func Router(e *env.Env, _ *connections.C) http.Handler {
 	r := chi.NewRouter()
 
-	r.Get("/api/doc", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, e.APIDoc, http.StatusMovedPermanently)
-	})
+	if e.APIDoc != "" {
+		r.Get("/api/doc", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, e.APIDoc, http.StatusMovedPermanently)
+		})
+	}
 	r.Get("/health", health)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "https://github.com/flitlabs/spotoncars_stream", http.StatusTemporaryRedirect)
@@ -11,40 +11,41 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
 func checkJob(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	var wg sync.WaitGroup
 
-	client := c.R.DB
-	jobs := client.SMembers(r.Context(), e.PartitionManagerKey).Val()
+	jobs, err := c.State.Partitions(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get the partitions that are in use")
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
 	wg.Add(len(jobs))
 
 	for _, job := range jobs {
-		go func(job string) {
+		go func(job partitions.Slot) {
 			defer wg.Done()
 
-			val := client.Get(r.Context(), _lib.C(job)).Val()
+			val, _ := c.State.GetViewers(r.Context(), job)
 			if val != "" {
 				return
 			}
 
-			val = client.Get(r.Context(), _lib.N(job)).Val()
+			val, _ = c.State.GetPartition(r.Context(), job)
 			if val == "" {
-				partition, err := strconv.Atoi(job)
-				msg := fmt.Sprintf(
-					"job : %s\tredis-value : %s\tthe job cannot be found with n- partitions",
-					job,
-					val,
-				)
-				if err == nil {
-					log.Warn().Msg(msg)
-					_lib.Free(r.Context(), client, e.PartitionManagerKey, partition)
-					return
-				}
-
-				log.Error().Msg(msg)
+				log.Warn().
+					Msgf(
+						"job : %s\tredis-value : %s\tthe job cannot be found with n- partitions",
+						job,
+						val,
+					)
+				_lib.Free(r.Context(), c.Partitions, job)
 				return
 			}
 
@@ -69,24 +70,8 @@ func checkJob(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections
 					)
 				return
 			}
-			partition, err := strconv.Atoi(job)
-			if err != nil {
-				log.Error().Err(err).
-					Msgf(
-						"job : %s\tredis-value : %s\tfailed to convert the job to integer",
-						job,
-						val,
-					)
-				return
-			}
-
-			pipe := client.Pipeline()
-
-			pipe.Del(r.Context(), _lib.N(job))
-			pipe.Del(r.Context(), _lib.L(job))
-			pipe.Del(r.Context(), _lib.C(job))
 
-			_, err = pipe.Exec(r.Context())
+			err = c.State.ClearPartition(r.Context(), job)
 			if err != nil {
 				log.Error().Err(err).
 					Msgf(
@@ -100,7 +85,7 @@ func checkJob(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections
 				e,
 				c,
 				N[_lib.NBookingID],
-				partition,
+				job,
 				int64(startOffset),
 			)
 		}(job)
@@ -25,6 +25,17 @@ func Router(e *env.Env, c *connections.C) http.Handler {
 		r.Delete("/{booking_id}", h(end, e, c))
 	})
 
+	r.Route("/partitions", func(r chi.Router) {
+		r.Use(m(middlewares.IsAdminOrIsSuperAdmin, e, c))
+		r.Get("/", h(partitionStats, e, c))
+	})
+
+	r.Route("/topics", func(r chi.Router) {
+		r.Use(m(middlewares.IsSuperAdmin, e, c))
+		r.Get("/", h(topics, e, c))
+		r.With(middlewares.IsContentJSON).Post("/", h(addTopic, e, c))
+	})
+
 	r.Route("/reset", func(r chi.Router) {
 		r.Use(m(middlewares.IsSuperAdmin, e, c))
 		r.Delete("/", h(reset, e, c))
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"net/http"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/rs/zerolog/log"
+)
+
+// partitionStats is a route that is used to get the allocation statistics of the partition allocator
+func partitionStats(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	stats, err := c.Partitions.Stats(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get the slots of the partition pool")
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+
+	lib.JSONResponseWInterface(w, http.StatusOK, stats)
+}
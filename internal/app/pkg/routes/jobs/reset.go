@@ -12,10 +12,10 @@ import (
 )
 
 func reset(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
-	err := c.R.DB.FlushDB(r.Context()).Err()
+	err := c.State.Flush(r.Context())
 	if err != nil {
 		log.Error().Err(err).Msg("failed to flush the database")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
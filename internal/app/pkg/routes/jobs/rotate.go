@@ -7,6 +7,7 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"github.com/rs/zerolog/log"
 )
@@ -21,7 +22,7 @@ type payload struct {
 	Items []item `json:"items"`
 }
 
-func rotate(w http.ResponseWriter, _ *http.Request, e *env.Env, _ *connections.C) {
+func rotate(w http.ResponseWriter, r *http.Request, e *env.Env, _ *connections.C) {
 	config := services.NewEdgeConfig(e.EdgeConfig, e.EdgeConfigReadToken, e.VercelToken)
 	err := config.Update([]services.EdgeConfigItem{
 		{
@@ -32,7 +33,7 @@ func rotate(w http.ResponseWriter, _ *http.Request, e *env.Env, _ *connections.C
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("failed to generate the secret")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
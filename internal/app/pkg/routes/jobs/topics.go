@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	ers "errors"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/rs/zerolog/log"
+)
+
+// topics is a route that is used to get the topics in the partition pool along with their number of partitions
+func topics(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	payload, err := c.State.Topics(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get the topics of the partition pool")
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+	payload[e.Topic] = e.TotalPartitions
+
+	lib.JSONResponseWInterface(w, http.StatusOK, payload)
+}
+
+// addTopic is a route that is used to add every partition of an existing topic of the location bus
+// to the partition pool
+func addTopic(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	const (
+		maxRequestBodySize = 1 << 9
+	)
+
+	type body struct {
+		// kafka topic names can only contain alphanumerics, periods, underscores and hyphens
+		Topic string `json:"topic" validate:"required,max=249,excludesall=:/ "`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	defer r.Body.Close()
+
+	var reqBody body
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read the request body")
+		lib.ErrorResponse(w, r, errors.ErrUnsuportedMedia)
+		return
+	}
+	if err := lib.NewValidator().Struct(reqBody); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"topic : %s\tvalidation error, invalid data is provided",
+				reqBody.Topic,
+			)
+		lib.ErrorResponse(w, r, lib.ValidationError(err))
+		return
+	}
+	if reqBody.Topic == e.Topic {
+		log.Error().
+			Msgf(
+				"topic : %s\tthe primary topic can not be added as an extra topic",
+				reqBody.Topic,
+			)
+		lib.ErrorResponse(w, r, errors.ErrPrimaryTopic)
+		return
+	}
+
+	count, err := c.Bus.TopicPartitions(r.Context(), reqBody.Topic)
+	if err != nil {
+		if ers.Is(err, connections.ErrUnknownTopic) {
+			lib.ErrorResponse(w, r, errors.ErrUnknownTopic.WithDetails(map[string]any{
+				"topic": reqBody.Topic,
+			}))
+			return
+		}
+
+		log.Error().Err(err).
+			Msgf(
+				"topic : %s\tfailed to get the partitions of the topic",
+				reqBody.Topic,
+			)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+
+	err = c.State.AddTopic(r.Context(), reqBody.Topic, count)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"topic : %s\tpartitions : %d\tfailed to add the topic to the partition pool",
+				reqBody.Topic,
+				count,
+			)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+
+	// the new slots can be handed out to the reservations that are waiting right away
+	c.Partitions.Admit(r.Context())
+
+	log.Info().
+		Msgf(
+			"topic : %s\tpartitions : %d\tadded the topic to the partition pool",
+			reqBody.Topic,
+			count,
+		)
+	lib.JSONResponseWInterface(w, http.StatusOK, map[string]any{
+		"topic":      reqBody.Topic,
+		"partitions": count,
+	})
+}
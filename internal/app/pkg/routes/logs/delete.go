@@ -1,6 +1,7 @@
 package logs
 
 import (
+	ers "errors"
 	"fmt"
 	"net/http"
 
@@ -12,32 +13,31 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func delete(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+func delete(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 	bookingID := chi.URLParam(r, "booking_id")
 	if bookingID == "" {
-		lib.JSONResponse(w, http.StatusBadRequest, errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, errors.ErrBookingIDNotValid)
 		return
 	}
 
-	err := c.InitStorage(e)
+	err := c.Archive.Delete(r.Context(), bookingID)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to initialize the google cloud storage client")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
-		return
-	}
-	defer c.S.Close()
+		if !ers.Is(err, connections.ErrArchiveNotFound) {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to delete the archive",
+					bookingID,
+				)
+			lib.ErrorResponse(w, r, errors.ErrServer)
+			return
+		}
 
-	bucket := c.S.Bucket(e.BucketName)
-	object := bucket.Object(bookingID)
-
-	err = object.Delete(r.Context())
-	if err != nil {
 		log.Error().Err(err).
 			Msgf(
 				"booking_id : %s\tfailed to delete the object possibly the booking id is not valid",
 				bookingID,
 			)
-		lib.JSONResponse(w, http.StatusBadRequest, errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, errors.ErrBookingIDNotValid)
 		return
 	}
 
@@ -1,9 +1,7 @@
 package logs
 
 import (
-	"database/sql"
 	"errors"
-	"io"
 	"net/http"
 
 	"github.com/bytedance/sonic"
@@ -16,51 +14,30 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-type bookingDetails struct {
-	DriverName     *string
-	VehicleModal   *string
-	VehicleRegNo   *string
-	BookPickUpAddr *string
-	BookDropAddr   *string
-}
-
 func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	bookingID := chi.URLParam(r, "booking_id")
 	if bookingID == "" {
-		lib.JSONResponse(w, http.StatusBadRequest, _errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
 	}
 
-	err := c.InitStorage(e)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to initialize the storage client")
-		lib.JSONResponse(w, http.StatusInternalServerError, _errors.ErrServer.Error())
-		return
-	}
-	defer c.S.Close()
-
-	bucket := c.S.Bucket(e.BucketName)
-	object := bucket.Object(bookingID)
-
-	reader, err := object.NewReader(r.Context())
+	data, err := c.Archive.Get(r.Context(), bookingID)
 	if err != nil {
-		log.Error().Err(err).
-			Msgf(
-				"booking_id : %s\tfailed to initialize the reader, either because the booking id is not valid or some autentication error",
-				bookingID,
-			)
-		lib.JSONResponse(w, http.StatusBadRequest, _errors.ErrBookingIDNotValid.Error())
-		return
-	}
-	defer reader.Close()
+		if errors.Is(err, connections.ErrArchiveNotFound) {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tthere is no archive for the given booking id",
+					bookingID,
+				)
+			lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
+			return
+		}
 
-	data, err := io.ReadAll(reader)
-	if err != nil {
 		log.Error().Err(err).Msg("failed to read the data")
-		lib.JSONResponse(w, http.StatusInternalServerError, _errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, _errors.ErrServer)
 		return
 	}
 	if data == nil {
-		lib.JSONResponse(w, http.StatusBadRequest, _errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
 		return
 	}
 
@@ -72,67 +49,68 @@ func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C)
 				"payload : %v\tfailed to marshal the data from the payload",
 				payload,
 			)
-		lib.JSONResponse(w, http.StatusInternalServerError, _errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, _errors.ErrServer)
 		return
 	}
 
-	var bd bookingDetails
-
-	query := `
-SELECT
-	DriverName,
-	VehicleModal,
-	VehicleRegNo,
-	BookPickUpAddr,
-	BookDropAddr
-FROM
-	Tbl_BookingDetails
-WHERE
-  BookRefNo = @BookRefNo
-`
-
-	err = c.DB.QueryRow(query, sql.Named("BookRefNo", bookingID)).Scan(
-		&bd.DriverName,
-		&bd.VehicleModal,
-		&bd.VehicleRegNo,
-		&bd.BookPickUpAddr,
-		&bd.BookDropAddr,
-	)
+	booking, err := c.Bookings.GetBooking(r.Context(), bookingID)
 	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
+		if !errors.Is(err, connections.ErrBookingNotFound) {
 			log.Error().Err(err).Msg("failed to get the query from the database")
-			lib.JSONResponse(w, http.StatusInternalServerError, _errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, _errors.ErrServer)
 			return
 		}
 	}
-	DriverName := ""
-	VehicleModal := ""
-	VehicleRegNo := ""
-
-	if bd.DriverName != nil {
-		DriverName = *bd.DriverName
-	}
-	if bd.VehicleModal != nil {
-		VehicleModal = *bd.VehicleModal
-	}
-	if bd.VehicleRegNo != nil {
-		VehicleRegNo = *bd.VehicleRegNo
-	}
 
 	pickups := []services.Geo{}
-	if bd.BookPickUpAddr != nil {
-		pickups = append(pickups, services.Geocode(r.Context(), e, c, true, lib.Seperator(*bd.BookPickUpAddr, "|"))...)
+	if booking.PickupAddr != "" {
+		pickups, err = services.Geocode(r.Context(), c, lib.Seperator(booking.PickupAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the pickup addresses",
+					bookingID,
+				)
+		}
 	}
 
 	dropoffs := []services.Geo{}
-	if bd.BookDropAddr != nil {
-		dropoffs = append(dropoffs, services.Geocode(r.Context(), e, c, true, lib.Seperator(*bd.BookDropAddr, "|"))...)
+	if booking.DropAddr != "" {
+		dropoffs, err = services.Geocode(r.Context(), c, lib.Seperator(booking.DropAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the dropoff addresses",
+					bookingID,
+				)
+		}
+	}
+
+	// the locations where the job status changed carry the transition and the locations where the driver left
+	// or returned to the expected route carry the deviation
+	timeline := []any{}
+	deviations := []any{}
+	if locations, ok := payload.([]any); ok {
+		for _, location := range locations {
+			location, ok := location.(map[string]any)
+			if !ok {
+				continue
+			}
+			if transition, ok := location["transition"]; ok {
+				timeline = append(timeline, transition)
+			}
+			if deviation, ok := location["deviation"]; ok {
+				deviations = append(deviations, deviation)
+			}
+		}
 	}
 
 	lib.JSONResponseWInterface(w, http.StatusOK, map[string]interface{}{
-		"driver_name":             DriverName,
-		"vehicle_modal":           VehicleModal,
-		"vehicle_registration_no": VehicleRegNo,
+		"timeline":                timeline,
+		"deviations":              deviations,
+		"driver_name":             booking.Driver.Name,
+		"vehicle_modal":           booking.Vehicle.Model,
+		"vehicle_registration_no": booking.Vehicle.RegNo,
 		"cordinates":              payload,
 		"pickups":                 pickups,
 		"dropoffs":                dropoffs,
@@ -4,42 +4,43 @@ import (
 	"context"
 	"io"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/bytedance/sonic"
-	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
-	"github.com/redis/go-redis/v9"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
-	"github.com/segmentio/kafka-go"
 )
 
 // add is a route that is used to add data to the stream
-func addV2(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
-	const maxRequestBodySize = 1 << 8
-	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+func addV2(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLocationBodySize)
 	defer r.Body.Close()
 
 	var (
 		reqData struct {
 			Location types.LocationUpdate `json:"location" validate:"required"`
 		}
-		payload string
-		err     error
+		err error
 	)
 
-	err = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&reqData)
+	if middlewares.IsProtobuf(r) {
+		err = readProto(r.Body, reqData.Location.UnmarshalProto)
+	} else {
+		err = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&reqData)
+	}
 	if err != nil {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error().Err(err).
 				Msg("failed to read the request body")
-			lib.JSONResponse(w, http.StatusRequestEntityTooLarge, errors.ErrBadRequest.Error())
+			lib.ErrorResponse(w, r, errors.ErrRequestTooLarge)
 			return
 		}
 		log.Error().Err(err).
@@ -48,7 +49,7 @@ func addV2(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C)
 				string(body),
 			)
 
-		lib.JSONResponse(w, http.StatusUnsupportedMediaType, errors.ErrUnsuportedMedia.Error())
+		lib.ErrorResponse(w, r, errors.ErrUnsuportedMedia)
 		return
 	}
 
@@ -58,45 +59,71 @@ func addV2(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C)
 				"body : %v\tfailed to validate the request body",
 				reqData,
 			)
-		lib.JSONResponse(w, http.StatusBadRequest, errors.ErrBadRequest.Error())
+		lib.ErrorResponse(w, r, lib.ValidationError(err))
 		return
 	}
+	receivedAt := time.Now()
+	skew, maxAge := e.Settings().ClockSkewWindow()
+	if !reqData.Location.CheckRecordedAt(receivedAt, skew, maxAge) {
+		log.Error().
+			Msgf(
+				"recorded_at : %v\treceived_at : %v\tthe recorded time of the location is outside of the clock skew window",
+				reqData.Location.RecordedAt,
+				receivedAt,
+			)
+		lib.ErrorResponse(w, r, errors.ErrRecordedAtOutOfRange)
+		return
+	}
+
 	driverID := r.Context().Value(middlewares.DriverID).(int)
-	partitionNo := r.Context().Value(middlewares.PartitionNo).(int)
+	slot := r.Context().Value(middlewares.Slot).(partitions.Slot)
 
-	payload, err = sonic.MarshalString(reqData.Location.GetBlob())
+	ingested, err := services.IngestLocations(
+		r.Context(),
+		e,
+		c,
+		e.Settings(),
+		slot,
+		driverID,
+		[]types.LocationUpdate{reqData.Location},
+		receivedAt,
+		true,
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal the payload")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, err)
+		return
+	}
+	if ingested.Duplicates > 0 {
+		lib.JSONResponse(w, http.StatusOK, "duplicate")
+		return
+	}
+	if ingested.Rejected > 0 {
+		lib.JSONResponse(w, http.StatusOK, "rejected")
 		return
 	}
-
-	go func(payload string) {
-		err = c.R.DB.Set(r.Context(), _lib.L(partitionNo), payload, redis.KeepTTL).Err()
-		if err != nil {
-			log.Error().Err(err).
-				Msgf(
-					"payload : %s\tfailed to set the live location",
-					payload,
-				)
-		}
-	}(payload)
-
-	writer := c.K.B
-	writer.Balancer = kafka.BalancerFunc(func(m kafka.Message, i ...int) int {
-		return partitionNo
-	})
-	writer.Async = true
-	writer.WriteMessages(context.Background(), kafka.Message{
-		Key:   []byte(strconv.Itoa(int(driverID))),
-		Value: []byte(payload),
-	})
 
 	log.Info().
 		Msgf(
-			"partition : %d\tdriver_id : %d\trecorded the live location ... ",
-			partitionNo,
+			"slot : %s\tdriver_id : %d\trecorded the live location ... ",
+			slot,
 			driverID,
 		)
+
+	if ingested.Ends {
+		bookingID := r.Context().Value(middlewares.BookingID).(string)
+		// the stream is ended right after the clear status is published, so the viewers receive it before they are disconnected
+		if _, err = services.EndBooking(context.Background(), e, c, bookingID); err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tslot : %s\tfailed to end the session after the job is cleared",
+					bookingID,
+					slot,
+				)
+			lib.ErrorResponse(w, r, errors.ErrServer)
+			return
+		}
+		lib.JSONResponse(w, http.StatusOK, "ended the session")
+		return
+	}
 	lib.JSONResponse(w, http.StatusOK, "added")
 }
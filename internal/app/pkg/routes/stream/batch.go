@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+// maxBatchLocations is the largest number of locations in a single batch
+const maxBatchLocations = 500
+
+// addBatch is a route that is used to add the locations that are buffered by the driver while being offline
+// to the stream, the locations are published in the order of their location index and the locations that
+// are already published are skipped
+func addBatch(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	const maxRequestBodySize = maxLocationBodySize * maxBatchLocations
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	defer r.Body.Close()
+
+	var (
+		reqData struct {
+			// the largest number of locations is maxBatchLocations
+			Locations []types.LocationUpdate `json:"locations" validate:"required,min=1,max=500,dive"`
+		}
+		err error
+	)
+
+	if middlewares.IsProtobuf(r) {
+		err = readProto(r.Body, func(b []byte) error {
+			reqData.Locations, err = types.UnmarshalLocationBatch(b)
+			return err
+		})
+	} else {
+		err = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&reqData)
+	}
+	if err != nil {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error().Err(err).
+				Msg("failed to read the request body")
+			lib.ErrorResponse(w, r, errors.ErrRequestTooLarge)
+			return
+		}
+		log.Error().Err(err).
+			Msgf(
+				"raw_body : %s\tfailed to read the request body",
+				string(body),
+			)
+
+		lib.ErrorResponse(w, r, errors.ErrUnsuportedMedia)
+		return
+	}
+
+	if err = v.Struct(reqData); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"locations : %d\tfailed to validate the request body",
+				len(reqData.Locations),
+			)
+		lib.ErrorResponse(w, r, lib.ValidationError(err))
+		return
+	}
+	receivedAt := time.Now()
+	for i, location := range reqData.Locations {
+		// the location index is what orders and deduplicates the buffered locations
+		if location.LocationIndex == nil {
+			lib.ErrorResponse(w, r, errors.ErrBadRequest.WithDetails(map[string]any{
+				"fields": map[string]string{
+					fmt.Sprintf("locations[%d].location_index", i): "required",
+				},
+			}))
+			return
+		}
+	}
+	if i, ok := services.CheckRecordedAt(e.Settings(), reqData.Locations, receivedAt); !ok {
+		log.Error().
+			Msgf(
+				"recorded_at : %v\treceived_at : %v\tthe recorded time of the location is outside of the clock skew window",
+				reqData.Locations[i].RecordedAt,
+				receivedAt,
+			)
+		lib.ErrorResponse(w, r, errors.ErrRecordedAtOutOfRange.WithDetails(map[string]any{
+			"field": fmt.Sprintf("locations[%d].recorded_at", i),
+		}))
+		return
+	}
+
+	driverID := r.Context().Value(middlewares.DriverID).(int)
+	slot := r.Context().Value(middlewares.Slot).(partitions.Slot)
+
+	ingested, err := services.IngestLocations(r.Context(), e, c, e.Settings(), slot, driverID, reqData.Locations, receivedAt, true)
+	if err != nil {
+		lib.ErrorResponse(w, r, err)
+		return
+	}
+
+	log.Info().
+		Msgf(
+			"slot : %s\tdriver_id : %d\tpublished : %d\tduplicates : %d\trejected : %d\trecorded the buffered locations ... ",
+			slot,
+			driverID,
+			ingested.Published,
+			ingested.Duplicates,
+			ingested.Rejected,
+		)
+
+	if ingested.Ends {
+		bookingID := r.Context().Value(middlewares.BookingID).(string)
+		if _, err = services.EndBooking(context.Background(), e, c, bookingID); err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tslot : %s\tfailed to end the session after the job is cleared",
+					bookingID,
+					slot,
+				)
+			lib.ErrorResponse(w, r, errors.ErrServer)
+			return
+		}
+	}
+
+	lib.JSONResponseWInterface(w, http.StatusOK, map[string]any{
+		"published":  ingested.Published,
+		"duplicates": ingested.Duplicates,
+		"rejected":   ingested.Rejected,
+		"ended":      ingested.Ends,
+	})
+}
@@ -2,7 +2,6 @@ package stream
 
 import (
 	"context"
-	"database/sql"
 	ers "errors"
 	"fmt"
 	"io"
@@ -18,34 +17,29 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
-	"github.com/go-playground/validator/v10"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
 // Create is a route that is used to create a new stream for the given booking id
 func create(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	const (
-		// Below variables are used to lock the partition manager until a proper partition is chosen
-		// for the job creation
-		//
-		// The time for which the partition manager will be locked
-		lockDuration = 2 * time.Second
-		// The maximum time that another request will be wating till the partition manager is freed
-		// before timeout
-		timeout = 3 * time.Second
-
-		maxRequestBodySize = 1 << 6
+		maxRequestBodySize = 1 << 16
 	)
 
 	type body struct {
-		BookingID string `json:"booking_id" validate:"required,min=1"`
+		BookingID     string `json:"booking_id" validate:"required,min=1"`
+		ReservationID string `json:"reservation_id" validate:"omitempty,uuid"`
+		// Route is the encoded polyline of the route that the driver is expected to take, the route is
+		// fetched from the route estimator when it is not provided
+		Route string `json:"route"`
 	}
 
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 	defer r.Body.Close()
 
 	var reqBody body
-	v := validator.New()
+	v := lib.NewValidator()
 
 	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
@@ -53,7 +47,7 @@ func create(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C
 		if err != nil {
 			log.Error().Err(err).
 				Msg("failed to read the request body")
-			lib.JSONResponse(w, http.StatusRequestEntityTooLarge, errors.ErrBookingIDNotValid.Error())
+			lib.ErrorResponse(w, r, errors.ErrRequestTooLarge)
 			return
 		}
 
@@ -62,52 +56,74 @@ func create(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C
 				"raw_body : %s\tfailed to read the request body",
 				string(body),
 			)
-		lib.JSONResponse(w, http.StatusUnsupportedMediaType, errors.ErrUnsuportedMedia.Error())
+		lib.ErrorResponse(w, r, errors.ErrUnsuportedMedia)
 		return
 	}
 
 	if err := v.Struct(reqBody); err != nil {
 		log.Error().Err(err).Msg("validation error, invalid data is provided")
-		lib.JSONResponse(w, http.StatusBadRequest, errors.ErrBookingIDNotValid.Error())
+		lib.ErrorResponse(w, r, errors.ErrBookingIDNotValid)
 		return
 	}
 
-	var driverID *int
-	var bookPickupAddr *string
-
-	query := `
-SELECT
-	DriverPk,
-  BookPickUpAddr
-FROM
-	Tbl_BookingDetails
-WHERE
-	BookRefNo = @BookRefNo;
-`
-
-	err = c.DB.QueryRow(query, sql.Named("BookRefNo", reqBody.BookingID)).Scan(&driverID, &bookPickupAddr)
-	if err != nil || driverID == nil {
+	var route []services.Geo
+	if reqBody.Route != "" {
+		route, err = connections.DecodeRoute(reqBody.Route)
+		if err != nil || len(route) == 0 {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to decode the expected route",
+					reqBody.BookingID,
+				)
+			lib.ErrorResponse(w, r, errors.ErrBadRequest.WithDetails(map[string]any{
+				"field": "route",
+			}))
+			return
+		}
+	}
+
+	booking, err := c.Bookings.GetBooking(r.Context(), reqBody.BookingID)
+	if err != nil || booking.Driver.ID == nil {
 		log.Error().Err(err).
 			Msgf(
 				"booking_id : %s\tfailed to get the driver ID from the booking ID",
 				reqBody.BookingID,
 			)
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
+	driverID := booking.Driver.ID
+
 	pickups := []services.Geo{}
-	if bookPickupAddr != nil {
-		pickups = append(pickups, services.Geocode(r.Context(), e, c, true, lib.Seperator(*bookPickupAddr, "|"))...)
+	if booking.PickupAddr != "" {
+		pickups, err = services.Geocode(r.Context(), c, lib.Seperator(booking.PickupAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the pickup addresses",
+					reqBody.BookingID,
+				)
+		}
 	}
 	if len(pickups) == 0 {
 		log.Error().Msg("cannot find the pickup address for the given location")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
-	client := c.R.DB
+	dropoffs := []services.Geo{}
+	if booking.DropAddr != "" {
+		dropoffs, err = services.Geocode(r.Context(), c, lib.Seperator(booking.DropAddr, "|"))
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the dropoff addresses",
+					reqBody.BookingID,
+				)
+		}
+	}
 
-	if val := client.Get(r.Context(), fmt.Sprint(*driverID)).Val(); val != "" {
+	if val, _ := c.State.GetDriver(r.Context(), *driverID); val != "" {
 		DriverID := _lib.NewDriverID()
 		err = sonic.UnmarshalString(val, &DriverID)
 		if err != nil {
@@ -118,22 +134,21 @@ WHERE
 					val,
 					reqBody.BookingID,
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 
 		bookingID := DriverID[_lib.DriverIDBookingID]
-		partition, err := strconv.Atoi(DriverID[_lib.DriverIDPartitionNo])
+		slot, err := _lib.GetDriverIDSlot(DriverID, e.Topic)
 		if err != nil {
 			log.Error().Err(err).
 				Msgf(
-					"driver_id : %d\tredis_value : %s\tbooking_id : %s\tpartition : %s\tfailed to unmarshal",
+					"driver_id : %d\tredis_value : %s\tbooking_id : %s\tfailed to parse the slot",
 					*driverID,
 					val,
 					reqBody.BookingID,
-					DriverID[_lib.DriverIDPartitionNo],
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 
@@ -143,7 +158,7 @@ WHERE
 				e,
 				c,
 				*driverID,
-				partition,
+				slot,
 				reqBody.BookingID,
 			)
 			if err != nil {
@@ -153,7 +168,7 @@ WHERE
 						bookingID,
 						*driverID,
 					)
-				lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+				lib.ErrorResponse(w, r, errors.ErrServer)
 				return
 			}
 
@@ -172,7 +187,7 @@ WHERE
 			return
 		}
 
-		val = client.Get(r.Context(), _lib.N(partition)).Val()
+		val, err = c.State.GetPartition(r.Context(), slot)
 		if val == "" {
 			log.Error().Err(err).
 				Msgf(
@@ -180,7 +195,7 @@ WHERE
 					bookingID,
 					*driverID,
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 		N := _lib.NewN()
@@ -192,7 +207,7 @@ WHERE
 					bookingID,
 					*driverID,
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 		offset, err := strconv.Atoi(N[_lib.NLastOffset])
@@ -204,11 +219,11 @@ WHERE
 					*driverID,
 					val,
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 
-		err = _lib.DelBooking(r.Context(), client, *driverID, bookingID, partition)
+		_, err = c.State.DelBooking(r.Context(), *driverID, bookingID, slot)
 		if err != nil {
 			log.Error().Err(err).
 				Msgf(
@@ -216,7 +231,7 @@ WHERE
 					bookingID,
 					*driverID,
 				)
-			lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+			lib.ErrorResponse(w, r, errors.ErrServer)
 			return
 		}
 
@@ -224,99 +239,100 @@ WHERE
 			e,
 			c,
 			bookingID,
-			partition,
+			slot,
 			int64(offset),
 		)
 	}
 
-	var available []int
-
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
-	defer cancel()
-
-	serverBusyErr := fmt.Errorf("server is busy right now, no partitions are currently available")
-
-	err = func() error {
-		acquired, err := c.R.AcquireLock(ctx, client, e.PartitionManagerKey, lockDuration, 100*time.Millisecond)
-		if err != nil {
-			return err
-		}
-		if !acquired {
-			return serverBusyErr
-		}
-		defer c.R.ReleaseLock(client, e.PartitionManagerKey)
-
-		payload := client.SMembers(r.Context(), e.PartitionManagerKey).Val()
-		jobs := make(map[int]struct{})
-		for _, data := range payload {
-			job, err := strconv.Atoi(data)
-			if err != nil {
-				client.SRem(r.Context(), e.PartitionManagerKey, data)
-				return err
-			}
-			jobs[job] = struct{}{}
-		}
-		partitions := make([]int, e.TotalPartitions)
-		for i := range partitions {
-			partitions[i] = i
-		}
-		for _, partition := range partitions {
-			_, found := jobs[partition]
-			if !found {
-				available = append(available, partition)
-			}
-		}
-
-		if len(available) == 0 {
-			return serverBusyErr
-		}
-
-		err = client.SAdd(r.Context(), e.PartitionManagerKey, available[0]).Err()
-		if err != nil {
-			return err
-		}
-
-		return nil
-	}()
-	if err != nil {
-		if ers.Is(err, serverBusyErr) {
-			lib.JSONResponse(w, http.StatusConflict, errors.ErrBusy.Error())
+	var (
+		slot        partitions.Slot
+		reservation *partitions.Reservation
+	)
+	if reqBody.ReservationID != "" {
+		slot, reservation, err = c.Queue.Claim(r.Context(), reqBody.ReservationID, reqBody.BookingID)
+	} else {
+		slot, reservation, err = c.Queue.Acquire(r.Context(), reqBody.BookingID)
+	}
+	if err != nil && !ers.Is(err, partitions.ErrReservationPending) {
+		if ers.Is(err, partitions.ErrReservationNotFound) {
+			lib.ErrorResponse(w, r, errors.ErrReservationNotFound)
 			return
 		}
 
-		log.Error().Err(err)
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\treservation_id : %s\tfailed to allocate a partition",
+				reqBody.BookingID,
+				reqBody.ReservationID,
+			)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+	if reservation != nil {
+		// every slot is in use, the driver has to wait for the reservation to be ready
+		// and create the stream again with the reservation ID
+		lib.JSONResponseWInterface(w, http.StatusAccepted, reservation)
 		return
 	}
 
-	partition := available[0]
+	// the slot is given back when the booking can not be created, otherwise it is in use until a manual cleanup
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// the keys of the slot that are written before the failure are removed as well, so that the next booking
+		// of the slot does not start with them
+		if err := c.State.ClearPartition(ctx, slot); err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"slot : %s	failed to clear the slot",
+					slot,
+				)
+		}
+		_lib.Free(ctx, c.Partitions, slot)
+	}
 
-	lastOffset, err := c.GetLastOffset(r.Context(), e, e.Topic, partition)
+	lastOffset, err := c.Bus.LastOffset(r.Context(), slot)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get the lastoffset")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		release()
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
-	newOffset := int(lastOffset) + 1
+	// the last offset is the offset of the next message, which is the first location of the booking
+	newOffset := int(lastOffset)
+
+	if route == nil {
+		route = services.ExpectedRoute(r.Context(), c, pickups, dropoffs)
+	}
 
 	bt := tokens.NewBookingToken(e, c)
 
 	token, err := bt.Create(r.Context(), tokens.BookingTokenOpts{
-		DriverID:         *driverID,
-		BookingID:        reqBody.BookingID,
-		Partition:        partition,
-		NewOffset:        newOffset,
-		PickupCordinates: pickups[0],
+		DriverID:  *driverID,
+		BookingID: reqBody.BookingID,
+		Slot:      slot,
+		NewOffset: newOffset,
+		Pickups:   pickups,
+		Dropoffs:  dropoffs,
+		Route:     route,
 	})
 	if err != nil {
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s	driver_id : %d	failed to create the booking token",
+				reqBody.BookingID,
+				*driverID,
+			)
+		release()
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
 	duration, err := time.ParseDuration(fmt.Sprintf("%ds", e.BookingTokenExpires))
 	if err != nil {
 		log.Error().Err(err).Msg("failed to convert the booking token expires to seconds")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
@@ -338,13 +354,14 @@ func renewBookingToken(
 	ctx context.Context,
 	e *env.Env,
 	c *connections.C,
-	driverID,
-	partition int,
+	driverID int,
+	slot partitions.Slot,
 	bookingID string,
 ) (token string, ttl time.Duration, err error) {
-	client := c.R.DB
-
-	ttl = client.TTL(ctx, fmt.Sprint(driverID)).Val()
+	ttl, err = c.State.DriverTTL(ctx, driverID)
+	if err != nil {
+		return "", 0, err
+	}
 	if ttl <= 0 {
 		return "", 0, fmt.Errorf("the ttl the previous booking token is smaller than 0")
 	}
@@ -353,7 +370,7 @@ func renewBookingToken(
 
 	id, token, err := bt.Createtoken(
 		driverID,
-		partition,
+		slot,
 		bookingID,
 		ttl,
 	)
@@ -361,12 +378,15 @@ func renewBookingToken(
 		return "", 0, err
 	}
 
-	driverDetails, err := sonic.MarshalString(_lib.SetDriverID(id.String(), bookingID, partition))
+	driverDetails, err := sonic.MarshalString(_lib.SetDriverID(id.String(), bookingID, slot))
 	if err != nil {
 		return "", 0, err
 	}
 
-	client.Set(ctx, fmt.Sprint(driverID), driverDetails, ttl)
+	err = c.State.SetDriver(ctx, driverID, driverDetails, ttl)
+	if err != nil {
+		return "", 0, err
+	}
 
 	return token, 0, nil
 }
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// testBookings is a booking repository with a single booking that is assigned to the driver 7
+type testBookings struct{}
+
+// GetBooking is used to get the booking with its driver and vehicle by the booking ID
+func (testBookings) GetBooking(_ context.Context, bookingID string) (connections.Booking, error) {
+	if bookingID != "B1" {
+		return connections.Booking{}, connections.ErrBookingNotFound
+	}
+
+	driverID := 7
+	return connections.Booking{
+		Driver:     connections.Driver{ID: &driverID},
+		PickupAddr: "Colombo Fort",
+		DropAddr:   "Galle Face",
+	}, nil
+}
+
+// failingOffset is a location bus that fails to get the last offset
+type failingOffset struct {
+	connections.LocationBus
+}
+
+// LastOffset is used to get the offset of the next message of the given slot, it always fails
+func (failingOffset) LastOffset(context.Context, partitions.Slot) (int64, error) {
+	return 0, errors.New("broker is not available")
+}
+
+// failingCreate is a state store that writes the backup of the booking before it fails
+type failingCreate struct {
+	connections.StateStore
+}
+
+// CreateBooking is used to store the keys of a newly created booking, only the backup is stored before it fails
+func (s failingCreate) CreateBooking(ctx context.Context, state connections.BookingState) error {
+	state.Booking = ""
+	state.Driver = ""
+	if err := s.StateStore.CreateBooking(ctx, state); err != nil {
+		return err
+	}
+
+	return errors.New("connection reset")
+}
+
+// testCreate is used to get the settings and the connections of the stream creation with a single slot
+func testCreate(bus connections.LocationBus, state connections.StateStore) (*env.Env, *connections.C) {
+	e := &env.Env{
+		Topic:               "locations",
+		TotalPartitions:     1,
+		ReservationTimeout:  60,
+		BookingTokenExpires: 3600,
+		BookingTokenSecret:  "secret",
+	}
+	c := &connections.C{
+		State:    state,
+		Bus:      bus,
+		Bookings: testBookings{},
+		Geo: connections.NewStaticGeocoder(map[string]connections.Geo{
+			"Colombo Fort": {Lat: 6.9344, Lon: 79.8428},
+			"Galle Face":   {Lat: 6.9271, Lon: 79.8450},
+		}),
+	}
+	c.InitPartitions(e)
+
+	return e, c
+}
+
+func TestCreateReleasesSlot(t *testing.T) {
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+
+	tests := map[string]struct {
+		bus   connections.LocationBus
+		state connections.StateStore
+	}{
+		"last offset": {
+			bus:   failingOffset{connections.NewMemoryBus(1)},
+			state: connections.NewMemoryState(),
+		},
+		"booking token": {
+			bus:   connections.NewMemoryBus(1),
+			state: failingCreate{connections.NewMemoryState()},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			e, c := testCreate(test.bus, test.state)
+
+			// the route is given so that the route estimator is not needed
+			body := `{"booking_id":"B1","route":"_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@"}`
+			w := httptest.NewRecorder()
+			create(w, httptest.NewRequest(http.MethodPost, "/stream", strings.NewReader(body)), e, c)
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("got the status %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+
+			// the only slot is given back along with the keys that are written before the failure
+			got, reservation, err := c.Queue.Acquire(ctx, "B2")
+			if err != nil || reservation != nil || got != slot {
+				t.Errorf("got %s, %+v, %v, want the slot to be released", got, reservation, err)
+			}
+			if val, _ := c.State.GetPartition(ctx, slot); val != "" {
+				t.Errorf("got the backup %q, want the slot to be cleared", val)
+			}
+		})
+	}
+}
+
+func TestCreateSeedsJobState(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	e, c := testCreate(connections.NewMemoryBus(1), connections.NewMemoryState())
+
+	body := `{"booking_id":"B1","route":"_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@"}`
+	w := httptest.NewRecorder()
+	create(w, httptest.NewRequest(http.MethodPost, "/stream", strings.NewReader(body)), e, c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got the status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// the job starts with the default status, so that the geofences can advance it without a status from the driver
+	job := services.GetJobState(ctx, c, slot)
+	if job.Status == nil || *job.Status != _lib.DefaultStatus || !job.Assumed {
+		t.Errorf("got %+v, want the default status to be assumed", job)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/bytedance/sonic"
-	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
@@ -18,27 +16,16 @@ import (
 // End is a route that is used to end a given stream
 func end(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 	bookingID := r.Context().Value(middlewares.BookingID).(string)
-	partitionNo := r.Context().Value(middlewares.PartitionNo).(int)
-	driverID := r.Context().Value(middlewares.DriverID).(int)
 
-	client := c.R.DB
-
-	BookingID := _lib.NewBookingID()
-	err := sonic.UnmarshalString(c.R.DB.Get(r.Context(), bookingID).Val(), &BookingID)
+	// the stream may have already ended with the clear status, in which case there is nothing left to end
+	_, err := services.EndBooking(r.Context(), e, c, bookingID)
 	if err != nil {
 		log.Error().Err(err).
 			Msgf(
-				"payload : %v\tfailed to backup the job",
-				BookingID,
+				"booking_id : %s\tfailed to end the session",
+				bookingID,
 			)
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
-		return
-	}
-
-	err = _lib.DelBooking(r.Context(), client, driverID, bookingID, partitionNo)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to end the session")
-		lib.JSONResponse(w, http.StatusInternalServerError, errors.ErrServer.Error())
+		lib.ErrorResponse(w, r, errors.ErrServer)
 		return
 	}
 
@@ -50,12 +37,4 @@ func end(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
 		Expires:  time.Now().Add(-time.Hour * 24),
 	})
 	lib.JSONResponse(w, http.StatusOK, "ended the session")
-
-	go services.GenerateLog(
-		e,
-		c,
-		bookingID,
-		BookingID[_lib.BookingIDPartitionNo],
-		int64(BookingID[_lib.BookingIDLastOffset]),
-	)
 }
@@ -0,0 +1,420 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/go-chi/chi/v5"
+	"github.com/lesismal/nbio"
+	"github.com/rs/zerolog/log"
+)
+
+// retryAfter is the time that the clients wait before connecting to the events of a stream again
+const retryAfter = 3 * time.Second
+
+// noEventID is used to send an event that does not change the last event id of the client
+const noEventID int64 = -1
+
+// eventsConn is a hijacked connection that the events are sent on, the data that the client sends is discarded and
+// gone is closed once the client goes away
+type eventsConn struct {
+	net.Conn
+	gone chan struct{}
+	once sync.Once
+}
+
+// newEventsConn is a function that is used to watch the given hijacked connection for the client going away
+//
+// The connections of nbio are non blocking so they can not be read by a goroutine of their own, the connection
+// is read by nbio and the eventsConn becomes its session instead, the other connections are read by a goroutine
+func newEventsConn(conn net.Conn) *eventsConn {
+	ec := &eventsConn{
+		Conn: conn,
+		gone: make(chan struct{}),
+	}
+
+	if nbc, ok := conn.(*nbio.Conn); ok {
+		nbc.SetSession(ec)
+		return ec
+	}
+
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		ec.CloseAndClean(nil)
+	}()
+
+	return ec
+}
+
+// UnderlayerConn is used to get the hijacked connection
+func (ec *eventsConn) UnderlayerConn() net.Conn {
+	return ec.Conn
+}
+
+// Parse is used to discard the data that the client sends
+func (ec *eventsConn) Parse(_ []byte) error {
+	return nil
+}
+
+// CloseAndClean is used to report that the client has gone away
+func (ec *eventsConn) CloseAndClean(_ error) {
+	ec.once.Do(func() {
+		close(ec.gone)
+	})
+}
+
+// events is a route that is used to view the stream of a booking as server sent events, the offsets of the location
+// bus are used as the ids of the events so that the clients that reconnect with the Last-Event-ID header are sent
+// the messages that they have missed
+func events(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	// the settings are kept for the lifetime of the connection even if they are reloaded
+	settings := e.Settings()
+
+	bookingID := chi.URLParam(r, "booking_id")
+	if bookingID == "" {
+		lib.ErrorResponse(w, r, errors.ErrBookingIDNotValid)
+		return
+	}
+
+	request, err := services.ParseTrailRequest(r.URL.Query())
+	if err != nil {
+		lib.ErrorResponse(w, r, err)
+		return
+	}
+
+	stream, err := services.JoinStream(r.Context(), e, c, bookingID)
+	if err != nil {
+		lib.ErrorResponse(w, r, err)
+		return
+	}
+
+	// the responses are only sent once the handler returns, so the events are written to the connection directly
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		log.Error().Msg("the response writer does not support hijacking the connection")
+		services.LeaveStream(c, stream.Slot)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to hijack the connection")
+		services.LeaveStream(c, stream.Slot)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+	// the connection is kept open for as long as the stream lasts
+	_ = conn.SetReadDeadline(time.Time{})
+
+	header := w.Header().Clone()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// the length of the events is not known, so the body is sent in chunks
+	header.Set("Transfer-Encoding", "chunked")
+	// the proxies must not buffer the events
+	header.Set("X-Accel-Buffering", "no")
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 200 OK\r\n")
+	_ = header.Write(&buf)
+	buf.WriteString("\r\n")
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		log.Error().Err(err).Msg("failed to write the headers of the events")
+		services.LeaveStream(c, stream.Slot)
+		conn.Close()
+		return
+	}
+
+	ec := newEventsConn(conn)
+	// every write to the body is sent as a single chunk
+	body := httputil.NewChunkedWriter(conn)
+	if _, err := fmt.Fprintf(body, "retry: %d\n\n", retryAfter.Milliseconds()); err != nil {
+		log.Error().Err(err).Msg("failed to write the retry time of the events")
+		services.LeaveStream(c, stream.Slot)
+		conn.Close()
+		return
+	}
+
+	log.Info().
+		Msgf("addr : %s\tevents opened", conn.RemoteAddr().String())
+
+	go sendEvents(ec, body, c, settings, bookingID, stream, request, r.Header.Get("Last-Event-ID"))
+}
+
+// sendEvents is a function that is used to send the events of the given stream to the given body of the connection
+// until the stream ends or the client goes away, the clients that resume with the last event id are not sent the
+// path so far again
+func sendEvents(
+	conn *eventsConn,
+	body io.WriteCloser,
+	c *connections.C,
+	settings *env.Settings,
+	bookingID string,
+	stream *services.Stream,
+	request *services.TrailRequest,
+	lastEventID string,
+) {
+	slot := stream.Slot
+
+	// the viewers of the same booking share a single subscription of the location bus, the viewer joins before the
+	// missed messages are read so that no message is lost in between
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	viewer, err := c.Hub.Join(ctx, slot)
+	cancel()
+	ticker := time.NewTicker(time.Duration(settings.Heartbeat) * time.Second)
+	eta := time.NewTicker(time.Duration(settings.ETAInterval) * time.Second)
+	// the last known location is sent again when there are no new locations for a while
+	pending := time.NewTicker(time.Duration(settings.Pending) * time.Second)
+
+	defer func() {
+		if viewer != nil {
+			c.Hub.Leave(viewer)
+		}
+		ticker.Stop()
+		eta.Stop()
+		pending.Stop()
+		// the last chunk ends the body, so that the client knows that the events have ended
+		if err := body.Close(); err == nil {
+			_, _ = io.WriteString(conn, "\r\n")
+		}
+		conn.Close()
+		services.LeaveStream(c, slot)
+
+		log.Info().
+			Msgf("addr : %s\tevents closed", conn.RemoteAddr().String())
+	}()
+
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tfailed to join the viewers of the booking",
+				bookingID,
+			)
+		return
+	}
+
+	feed := services.NewFeed(bookingID, stream.Location)
+
+	// the messages that are published before next are already sent to the client
+	next := connections.LatestOffset
+	if offset, err := strconv.ParseInt(lastEventID, 10, 64); err == nil && offset >= 0 {
+		missed, end, err := readMissed(c, slot, max(offset+1, stream.Offset))
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\tlast_event_id : %s\tfailed to read the missed messages",
+					bookingID,
+					lastEventID,
+				)
+		} else {
+			next = end
+			// the client already has a location, so only the locations that are newer than the missed ones are sent
+			feed = services.NewFeed(bookingID, "")
+		}
+
+		for _, message := range missed {
+			kind, ok := feed.Next(message.Value)
+			if !ok {
+				continue
+			}
+			if err := writeEvent(body, message.Offset, kind, message.Value); err != nil {
+				return
+			}
+			if feed.Ended() {
+				return
+			}
+		}
+	} else {
+		if request != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			locations, end, err := feed.Replay(ctx, c, settings, stream, request)
+			cancel()
+			if err != nil {
+				log.Error().Err(err).
+					Msgf(
+						"booking_id : %s\tfailed to read the path of the booking",
+						bookingID,
+					)
+			} else {
+				next = end
+				// the client that reconnects after the path resumes from the last message of the path
+				if err := writeTrail(body, end-1, locations); err != nil {
+					return
+				}
+			}
+		}
+
+		if err := writeEvent(body, noEventID, services.LocationMessage, feed.Location); err != nil {
+			return
+		}
+	}
+
+	// the viewers that connect while the signal is not live are told so right away
+	if current := services.CurrentSignal(context.Background(), c, settings, slot); current != nil {
+		if payload, err := sonic.Marshal(current); err == nil {
+			if err := writeEvent(body, noEventID, current.Type, payload); err != nil {
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-conn.gone:
+			return
+		case <-ticker.C:
+			log.Debug().Msg("heartbeat ... ")
+			if _, err := io.WriteString(body, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-eta.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(settings.ETAInterval)*time.Second)
+			payload := feed.ETA(ctx, c, time.Now())
+			cancel()
+			if payload == nil {
+				continue
+			}
+
+			if err := writeEvent(body, noEventID, "eta", payload); err != nil {
+				return
+			}
+		case <-pending.C:
+			if len(feed.Location) == 0 {
+				continue
+			}
+
+			if err := writeEvent(body, noEventID, services.LocationMessage, feed.Location); err != nil {
+				return
+			}
+		case message, ok := <-viewer.Messages():
+			if !ok {
+				log.Warn().Err(viewer.Err()).
+					Msgf(
+						"booking_id : %s\tthe viewer is removed from the hub, closing the events",
+						bookingID,
+					)
+				return
+			}
+			// the messages that are read with the missed messages or read again after the hub subscribes again are
+			// not sent twice
+			if message.Offset < next {
+				continue
+			}
+			next = message.Offset + 1
+			pending.Reset(time.Duration(settings.Pending) * time.Second)
+
+			kind, ok := feed.Next(message.Value)
+			if !ok {
+				continue
+			}
+			if err := writeEvent(body, message.Offset, kind, message.Value); err != nil {
+				return
+			}
+
+			// the stream ends once the job is cleared, so the client is disconnected after it receives the clear status
+			if feed.Ended() {
+				log.Info().
+					Msgf(
+						"booking_id : %s\tthe job is cleared, closing the events",
+						bookingID,
+					)
+				return
+			}
+		}
+	}
+}
+
+// readMissed is a function that is used to read the messages of the given slot that are published from the given
+// offset until now, the offset of the next message that is published is returned along with them
+func readMissed(c *connections.C, slot partitions.Slot, from int64) ([]connections.Message, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	end, err := c.Bus.LastOffset(ctx, slot)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from >= end {
+		return nil, end, nil
+	}
+
+	messages, err := c.Bus.ReadRange(ctx, slot, from, end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, end, nil
+}
+
+// writeTrail is a function that is used to write the given path of the stream so far as a single event, the
+// locations of the path are sent in JSON
+func writeTrail(w io.Writer, id int64, locations [][]byte) error {
+	trail := make([]json.RawMessage, 0, len(locations))
+	for _, value := range locations {
+		location, err := types.LocationJSON(value)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to decode the location from the location bus")
+			continue
+		}
+		trail = append(trail, location)
+	}
+
+	payload, err := sonic.Marshal(trail)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal the path of the stream")
+		return nil
+	}
+
+	return writeEvent(w, id, services.TrailMessage, payload)
+}
+
+// writeEvent is a function that is used to write a single event to the given writer, the id is left out when it is
+// noEventID
+func writeEvent(w io.Writer, id int64, event string, data []byte) error {
+	// the events are text, so the locations are always sent in JSON
+	if event == services.LocationMessage {
+		location, err := types.LocationJSON(data)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to decode the location from the location bus")
+			return nil
+		}
+		data = location
+	}
+
+	var buf bytes.Buffer
+	if id != noEventID {
+		fmt.Fprintf(&buf, "id: %d\n", id)
+	}
+	fmt.Fprintf(&buf, "event: %s\n", event)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		log.Error().Err(err).Msg("error sending the event to the client")
+	}
+
+	return err
+}
@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httputil"
+	"testing"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+)
+
+func TestEventsBody(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	ec := newEventsConn(server)
+	body := httputil.NewChunkedWriter(ec)
+
+	go func() {
+		_ = writeEvent(body, 7, services.SignalMessage, []byte("{\"status\":\"stale\"}"))
+		_ = body.Close()
+		_, _ = io.WriteString(ec, "\r\n")
+	}()
+
+	// the events are read back through the chunked encoding like the clients do
+	got, err := io.ReadAll(httputil.NewChunkedReader(bufio.NewReader(client)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 7\nevent: signal\ndata: {\"status\":\"stale\"}\n\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEventsConnGone(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+
+	ec := newEventsConn(server)
+
+	// the data that the client sends does not end the events
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+	}()
+	select {
+	case <-ec.gone:
+		t.Fatal("want the client to be connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client.Close()
+	select {
+	case <-ec.gone:
+	case <-time.After(time.Second):
+		t.Fatal("want the client that goes away to be detected")
+	}
+}
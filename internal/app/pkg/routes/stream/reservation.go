@@ -0,0 +1,59 @@
+package stream
+
+import (
+	ers "errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// reservation is a route that is used to get the state of a reservation in the waiting queue
+//
+// The wait query parameter is the number of seconds to long poll until a partition is assigned
+// to the reservation, the reservation is returned right away when it is not provided
+func reservation(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	// The maximum time that a request can long poll for a reservation
+	const maxWait = 30 * time.Second
+
+	reservationID := chi.URLParam(r, "reservation_id")
+	if reservationID == "" {
+		lib.ErrorResponse(w, r, errors.ErrReservationNotValid)
+		return
+	}
+
+	wait := time.Duration(0)
+	if val := r.URL.Query().Get("wait"); val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds < 0 {
+			lib.ErrorResponse(w, r, errors.ErrBadRequest)
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, maxWait)
+	}
+
+	payload, err := c.Queue.Wait(r.Context(), reservationID, wait)
+	if err != nil {
+		if ers.Is(err, partitions.ErrReservationNotFound) {
+			lib.ErrorResponse(w, r, errors.ErrReservationNotFound)
+			return
+		}
+
+		log.Error().Err(err).
+			Msgf(
+				"reservation_id : %s\tfailed to get the reservation",
+				reservationID,
+			)
+		lib.ErrorResponse(w, r, errors.ErrServer)
+		return
+	}
+
+	lib.JSONResponseWInterface(w, http.StatusOK, payload)
+}
@@ -2,20 +2,21 @@
 package stream
 
 import (
+	"io"
 	"net/http"
 
-	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
 )
 
 var (
-	v = validator.New()
-	h = lib.WrapHandler
-	m = lib.WrapMiddleware
+	v = lib.NewValidator()
+	h = _lib.WrapHandler
+	m = _lib.WrapMiddleware
 )
 
 // Router a route group that contains all the routes that are related to stream
@@ -28,15 +29,26 @@ func Router(e *env.Env, c *connections.C) http.Handler {
 		r.Post("/", h(create, e, c))
 	})
 
+	r.Route("/reservation", func(r chi.Router) {
+		r.Use(m(middlewares.IsDriver, e, c))
+		r.Get("/{reservation_id}", h(reservation, e, c))
+	})
+
 	r.Route("/add", func(r chi.Router) {
-		r.Use(middlewares.IsContentJSON)
+		r.Use(middlewares.IsContentJSONOrProtobuf)
 		r.Use(m(func(h http.Handler, e *env.Env, c *connections.C) http.Handler {
 			return middlewares.IsBookingTokenValid(h, e, c, true)
 		}, e, c))
 		r.Post("/", h(add, e, c))
 		r.Post("/v2", h(addV2, e, c))
+		r.Post("/batch", h(addBatch, e, c))
 	})
 
+	// the events are written to the hijacked connection in chunks, which only works because nbio serves HTTP/1.1
+	// alone, a connection of HTTP/2 can not be hijacked so the route must move to a flushing response writer if the
+	// server ever serves HTTP/2
+	r.Get("/view/{booking_id}/events", h(events, e, c))
+
 	r.Route("/end", func(r chi.Router) {
 		r.Use(m(middlewares.ValidateDriverOrBookingToken, e, c))
 		r.Delete("/", h(end, e, c))
@@ -44,3 +56,14 @@ func Router(e *env.Env, c *connections.C) http.Handler {
 
 	return r
 }
+
+// readProto is a function that is used to read the whole body of a request that is encoded in protocol buffers
+// with the given function
+func readProto(body io.Reader, unmarshal func(b []byte) error) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(b)
+}
@@ -2,44 +2,106 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"sort"
+	"time"
 
 	"github.com/bytedance/sonic"
 	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/rs/zerolog/log"
 )
 
+// EndBooking is a function that is used to end the given booking by removing it from the state store and by
+// writing its locations to the archive, it returns false without doing anything when the booking has already ended
+// so that the booking is only ended once when it is ended from more than one place at the same time
+//
+// The booking is removed last, so that the booking is left as it is when one of the earlier steps fails and it can
+// be ended again, once it is removed the slot is always freed by GenerateLog
+func EndBooking(ctx context.Context, e *env.Env, c *connections.C, bookingID string) (bool, error) {
+	val, err := c.State.GetBooking(ctx, bookingID)
+	if err != nil {
+		return false, err
+	}
+	if val == "" {
+		return false, nil
+	}
+
+	BookingID := _lib.NewBookingID()
+	err = sonic.UnmarshalString(val, &BookingID)
+	if err != nil {
+		return false, err
+	}
+	slot, lastOffset, driverID, err := _lib.GetBookingID(BookingID, e.Topic)
+	if err != nil {
+		return false, err
+	}
+
+	ended, err := c.State.DelBooking(ctx, driverID, bookingID, slot)
+	if err != nil {
+		return false, err
+	}
+	// the booking is ended by another caller in the meantime
+	if !ended {
+		return false, nil
+	}
+
+	// the viewers are told that the booking has ended before the slot is freed for the next booking
+	payload, err := sonic.Marshal(Ended{
+		Type: EndedMessage,
+		At:   time.Now().UnixMilli(),
+	})
+	if err == nil {
+		err = c.Bus.Publish(ctx, slot, nil, payload)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tfailed to publish the ended message",
+				bookingID,
+			)
+	}
+
+	go GenerateLog(
+		e,
+		c,
+		bookingID,
+		slot,
+		lastOffset,
+	)
+	return true, nil
+}
+
 // GenerateLog is a function that is used to save the booking history of a particular booking
 func GenerateLog(
 	e *env.Env,
 	c *connections.C,
 	bookingID string,
-	partition int,
+	slot partitions.Slot,
 	startOffset int64,
 ) {
 	ctx := context.Background()
-	defer _lib.Free(ctx, c.R.DB, e.PartitionManagerKey, partition)
+	defer _lib.Free(ctx, c.Partitions, slot)
 
 	Revalidate(e, []Paths{
 		Dashboard,
 	})
 
-	endOffset, err := c.GetLastOffset(ctx, e, e.Topic, partition)
-
-	if startOffset >= endOffset {
-		log.Warn().Msg("no messages in the given partition")
-		return
-	}
+	endOffset, err := c.Bus.LastOffset(ctx, slot)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get the last offset")
-		log.Warn().Msgf("partition : %d", partition)
+		log.Warn().Msgf("slot : %s", slot)
+		return
+	}
+	if startOffset >= endOffset {
+		log.Warn().Msg("no messages in the given slot")
 		return
 	}
 
 	defer func() {
-		log.Warn().
+		log.Debug().
 			Msgf(
 				"start : %d\tend : %d",
 				int(startOffset),
@@ -47,39 +109,71 @@ func GenerateLog(
 			)
 	}()
 
-	messages, err := c.GetLastNMessages(ctx, e, startOffset, endOffset, e.Topic, partition)
+	messages, err := c.Bus.ReadRange(ctx, slot, startOffset, endOffset)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get the last messages")
 		return
 	}
-	data, err := sonic.MarshalString(messages)
+
+	type entry struct {
+		location any
+		stored   types.StoredLocation
+	}
+
+	entries := []entry{}
+	for _, message := range messages {
+		// the archive only contains the locations of the booking
+		if types.MessageType(message.Value) != "" {
+			continue
+		}
+
+		var location any
+		value, err := types.LocationJSON(message.Value)
+		if err == nil {
+			err = sonic.Unmarshal(value, &location)
+		}
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("value", string(message.Value)).
+				Msg("failed to unmarshal the payload receiving from the location bus")
+			continue
+		}
+
+		stored, _ := types.ReadStoredLocation(value)
+
+		entries = append(entries, entry{
+			location: location,
+			stored:   stored,
+		})
+	}
+
+	// the locations are published in the order they are received, buffered locations are received late so the
+	// archive is ordered by the device time instead
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].stored.Before(entries[j].stored)
+	})
+
+	payload := make([]any, 0, len(entries))
+	for _, entry := range entries {
+		payload = append(payload, entry.location)
+	}
+
+	data, err := sonic.Marshal(payload)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to marshal the messages")
 		return
 	}
 
-	err = c.InitStorage(e)
+	err = c.Archive.Put(ctx, bookingID, data)
 	if err != nil {
-		log.Error().Err(err).
+		log.Error().
+			Err(err).
 			Msgf(
-				"start : %d\tend : %d\tfailed to initialize the storage client",
+				"start : %d\tend : %d\tfailed to write the messages to the archive",
 				int(startOffset),
 				int(endOffset),
 			)
 		return
 	}
-	defer c.S.Close()
-
-	bucket := c.S.Bucket(e.BucketName)
-	object := bucket.Object(bookingID)
-
-	w := object.NewWriter(ctx)
-	defer w.Close()
-	_, err = fmt.Fprint(w, data)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("failed to write the messages to the google cloud storage")
-		return
-	}
 }
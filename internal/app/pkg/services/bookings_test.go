@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// failingDelete is a state store that fails to remove the bookings
+type failingDelete struct {
+	connections.StateStore
+}
+
+// DelBooking is used to fail to remove the given booking
+func (failingDelete) DelBooking(_ context.Context, _ int, _ string, _ partitions.Slot) (bool, error) {
+	return false, fmt.Errorf("the state store is not reachable")
+}
+
+func TestEndBookingFailure(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	e := &env.Env{Topic: "locations"}
+
+	// the booking can not be read so it is left for the next attempt
+	c, _ := testIngest(t, slot)
+	if ended, err := EndBooking(ctx, e, c, "B1"); err == nil || ended {
+		t.Fatalf("got %t, %v, want the booking not to be ended", ended, err)
+	}
+	if val, _ := c.State.GetBooking(ctx, "B1"); val == "" {
+		t.Error("the booking that is not ended is removed")
+	}
+
+	// the booking is left for the next attempt when it can not be removed
+	payload, err := sonic.MarshalString(_lib.SetBookingID(slot, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.State.CreateBooking(ctx, connections.BookingState{
+		BookingID: "B2",
+		Booking:   payload,
+		Slot:      slot,
+		DriverID:  7,
+		TTL:       time.Hour,
+		BackupTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := c.State
+	c.State = failingDelete{store}
+	if ended, err := EndBooking(ctx, e, c, "B2"); err == nil || ended {
+		t.Fatalf("got %t, %v, want the booking not to be ended", ended, err)
+	}
+	if val, _ := store.GetBooking(ctx, "B2"); val != payload {
+		t.Errorf("got %q, want the booking %q to be kept", val, payload)
+	}
+
+	// a booking that has already ended is not ended again
+	c.State = store
+	if ended, err := EndBooking(ctx, e, c, "missing"); err != nil || ended {
+		t.Errorf("got %t, %v, want the missing booking to be skipped", ended, err)
+	}
+}
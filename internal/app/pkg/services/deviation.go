@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/deviation"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+// routeTimeout is the deadline to get the expected route of a booking while the stream is created
+const routeTimeout = 5 * time.Second
+
+// ExpectedRoute is a function that is used to get the route that the driver is expected to take through the given
+// pickups and then the given dropoffs, nil is returned when the route can not be found so that the stream is
+// created without checking the route deviation
+func ExpectedRoute(ctx context.Context, c *connections.C, pickups, dropoffs []Geo) []Geo {
+	stops := make([]Geo, 0, len(pickups)+len(dropoffs))
+	stops = append(stops, pickups...)
+	stops = append(stops, dropoffs...)
+	if len(stops) < 2 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, routeTimeout)
+	defer cancel()
+
+	route, err := c.Router.Route(ctx, stops)
+	if err != nil {
+		log.Warn().Err(err).
+			Msgf(
+				"stops : %d\tfailed to get the expected route",
+				len(stops),
+			)
+		return nil
+	}
+
+	return route
+}
+
+// NewDeviation is a function that is used to create the state of the route deviation with the given expected route
+func NewDeviation(route []Geo) deviation.State {
+	points := make([]deviation.Point, 0, len(route))
+	for _, point := range route {
+		points = append(points, deviation.Point{
+			Lat: point.Lat,
+			Lon: point.Lon,
+		})
+	}
+
+	return deviation.State{
+		Route:  points,
+		Events: []deviation.Event{},
+	}
+}
+
+// GetDeviation is a function that is used to get the expected route and the deviations of the given slot
+func GetDeviation(ctx context.Context, c *connections.C, slot partitions.Slot) deviation.State {
+	state := NewDeviation(nil)
+	loadState(ctx, slot, "deviation", c.State.GetDeviation, &state)
+
+	return state
+}
+
+// AlertDeviations is a function that is used to refresh the driver alerts of the dashboard when the driver
+// deviates from or returns to the expected route at any of the given locations
+func AlertDeviations(e *env.Env, locations ...types.LocationUpdate) {
+	for _, location := range locations {
+		if location.Deviation != nil {
+			Revalidate(e, []Paths{
+				Alerts,
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/rs/zerolog/log"
+)
+
+// arrivalRadius is the distance in meters from a stop that the driver has to be within to reach the stop
+const arrivalRadius = 100
+
+// StopKind is used to classify the stops of a booking
+type StopKind string
+
+const (
+	// Pickup is the stop where the passenger is picked up
+	Pickup StopKind = "pickup"
+	// Dropoff is the stop where the passenger is dropped off
+	Dropoff StopKind = "dropoff"
+)
+
+// ETA is the message that is sent to the viewers with the time of arrival to the next stop
+type ETA struct {
+	Type string   `json:"type"`
+	Stop StopKind `json:"stop"`
+	Text string   `json:"text"`
+	// Index is the index of the stop within the pickups or the dropoffs
+	Index int `json:"index"`
+	// Distance is the remaining distance in meters
+	Distance int64 `json:"distance"`
+	// Duration is the remaining time in seconds
+	Duration int64 `json:"duration"`
+	// ArrivesAt is the time of arrival in unix milliseconds
+	ArrivesAt int64   `json:"arrives_at"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+}
+
+// Journey is used to follow the stops of a booking for a single viewer
+type Journey struct {
+	Pickups  []Geo
+	Dropoffs []Geo
+	// pickup and dropoff are the indexes of the next pickup and the next dropoff
+	pickup  int
+	dropoff int
+}
+
+// NewJourney is a function that is used to get the geocoded stops of the given booking, the stops that can not be
+// geocoded are left out of the journey, an error is returned when none of the stops can be geocoded
+func NewJourney(ctx context.Context, c *connections.C, bookingID string) (*Journey, error) {
+	booking, err := c.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pickupErr, dropoffErr error
+	journey := &Journey{}
+	if booking.PickupAddr != "" {
+		journey.Pickups, pickupErr = Geocode(ctx, c, lib.Seperator(booking.PickupAddr, "|"))
+		if pickupErr != nil {
+			log.Warn().Err(pickupErr).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the pickup addresses",
+					bookingID,
+				)
+		}
+	}
+	if booking.DropAddr != "" {
+		journey.Dropoffs, dropoffErr = Geocode(ctx, c, lib.Seperator(booking.DropAddr, "|"))
+		if dropoffErr != nil {
+			log.Warn().Err(dropoffErr).
+				Msgf(
+					"booking_id : %s\tfailed to geocode some of the dropoff addresses",
+					bookingID,
+				)
+		}
+	}
+
+	// a journey without any stops is not kept, so that the stops are geocoded again once the geocoder recovers
+	if len(journey.Pickups) == 0 && len(journey.Dropoffs) == 0 && (pickupErr != nil || dropoffErr != nil) {
+		return nil, errors.Join(pickupErr, dropoffErr)
+	}
+
+	return journey, nil
+}
+
+// next is used to get the next stop of the journey from the given location, the stops that the driver has
+// reached are passed except the last stop of the pickups and the dropoffs
+func (j *Journey) next(location types.StoredLocation) (StopKind, int, Geo, bool) {
+	kind, stops, index := Pickup, j.Pickups, &j.pickup
+	if location.JobStatus() >= _lib.PassengerOnBoard {
+		kind, stops, index = Dropoff, j.Dropoffs, &j.dropoff
+	}
+	if len(stops) == 0 {
+		return kind, 0, Geo{}, false
+	}
+
+	for *index < len(stops)-1 {
+		stop := stops[*index]
+		if geo.Distance(location.Lat, location.Lon, stop.Lat, stop.Lon) > arrivalRadius {
+			break
+		}
+		*index++
+	}
+
+	return kind, *index, stops[*index], true
+}
+
+// Estimate is used to get the time of arrival to the next stop of the journey from the given location, nil is
+// returned when there is no stop to arrive at
+func (j *Journey) Estimate(
+	ctx context.Context,
+	c *connections.C,
+	location types.StoredLocation,
+	now time.Time,
+) (*ETA, error) {
+	if location.JobStatus() == _lib.Clear {
+		return nil, nil
+	}
+
+	kind, index, stop, ok := j.next(location)
+	if !ok {
+		return nil, nil
+	}
+
+	estimate, err := c.Router.Estimate(ctx, Geo{Lat: location.Lat, Lon: location.Lon}, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ETA{
+		Type:      "eta",
+		Stop:      kind,
+		Index:     index,
+		Lat:       stop.Lat,
+		Lon:       stop.Lon,
+		Distance:  int64(math.Round(estimate.Distance)),
+		Duration:  int64(estimate.Duration.Round(time.Second).Seconds()),
+		ArrivesAt: now.Add(estimate.Duration).UnixMilli(),
+		Text:      ArrivalText(estimate.Duration),
+	}, nil
+}
+
+// ArrivalText is a function that is used to get the human readable time of arrival
+func ArrivalText(duration time.Duration) string {
+	minutes := int(math.Ceil(duration.Minutes()))
+	switch {
+	case duration < 30*time.Second:
+		return "arriving now"
+	case minutes == 1:
+		return "arriving in 1 min"
+	case minutes < 60:
+		return fmt.Sprintf("arriving in %d min", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("arriving in %d h", minutes/60)
+	default:
+		return fmt.Sprintf("arriving in %d h %d min", minutes/60, minutes%60)
+	}
+}
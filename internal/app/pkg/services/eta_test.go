@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+)
+
+// testBookings is a booking repository that fails until it is told to recover
+type testBookings struct {
+	failing bool
+	calls   int
+}
+
+func (b *testBookings) GetBooking(_ context.Context, _ string) (connections.Booking, error) {
+	b.calls++
+	if b.failing {
+		return connections.Booking{}, errors.New("the booking database is down")
+	}
+
+	return connections.Booking{
+		PickupAddr: "Heathrow Airport",
+		DropAddr:   "Kings Cross",
+	}, nil
+}
+
+func TestFeedJourneyRetry(t *testing.T) {
+	ctx := context.Background()
+	bookings := &testBookings{failing: true}
+	c := &connections.C{
+		Bookings: bookings,
+		Geo: connections.NewStaticGeocoder(map[string]connections.Geo{
+			"Heathrow Airport": {Lat: 51.47, Lon: -0.4543},
+			"Kings Cross":      {Lat: 51.5308, Lon: -0.1238},
+		}),
+	}
+	f := NewFeed("B1", "{}")
+	now := time.Now()
+
+	// a failure is not kept, the stops are read again once the delay has passed
+	if journey := f.loadJourney(ctx, c, now); journey != nil {
+		t.Fatalf("got %+v, want no journey while the booking can not be read", journey)
+	}
+	if journey := f.loadJourney(ctx, c, now.Add(journeyRetry/2)); journey != nil || bookings.calls != 1 {
+		t.Fatalf("got %d reads, want the booking not to be read again before the delay", bookings.calls)
+	}
+
+	// the delay doubles with every failure
+	now = now.Add(journeyRetry)
+	f.loadJourney(ctx, c, now)
+	f.loadJourney(ctx, c, now.Add(journeyRetry))
+	if bookings.calls != 2 {
+		t.Fatalf("got %d reads, want the second delay to be longer than the first", bookings.calls)
+	}
+
+	bookings.failing = false
+	now = now.Add(2 * journeyRetry)
+	journey := f.loadJourney(ctx, c, now)
+	if journey == nil || len(journey.Pickups) != 1 || len(journey.Dropoffs) != 1 {
+		t.Fatalf("got %+v, want the stops once the booking can be read", journey)
+	}
+
+	// a journey that is read successfully is kept
+	if again := f.loadJourney(ctx, c, now); again != journey || bookings.calls != 3 {
+		t.Errorf("got %d reads, want the journey to be kept", bookings.calls)
+	}
+}
+
+func TestNewJourneyWithoutStops(t *testing.T) {
+	c := &connections.C{
+		Bookings: &testBookings{},
+		Geo:      connections.NewStaticGeocoder(nil),
+	}
+
+	// a journey where none of the stops can be geocoded is an error so that it is not kept
+	if journey, err := NewJourney(context.Background(), c, "B1"); err == nil {
+		t.Errorf("got %+v, want an error when none of the stops can be geocoded", journey)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// LocationMessage is the type of the messages of the location bus that are locations
+	LocationMessage = "location"
+	// EndedMessage is the type of the message of the location bus that is published once the booking ends
+	EndedMessage = "ended"
+)
+
+// Ended is the message that is published to the location bus once the booking ends, so that its viewers are
+// disconnected
+type Ended struct {
+	Type string `json:"type"`
+	// At is the time that the booking ended in unix milliseconds
+	At int64 `json:"at"`
+}
+
+// DriverInfo contains the details of the driver and the vehicle of a booking that are sent to its viewers
+type DriverInfo struct {
+	Name    string `json:"driver_name"`
+	Contact string `json:"driver_contact"`
+	RegNo   string `json:"vehicle_registration_no"`
+	Model   string `json:"vehicle_modal"`
+	Color   string `json:"vehicle_color"`
+}
+
+// GetDriverInfo is a function that is used to get the details of the driver and the vehicle of the given booking
+func GetDriverInfo(ctx context.Context, c *connections.C, bookingID string) (*DriverInfo, error) {
+	booking, err := c.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DriverInfo{
+		Name:    booking.Driver.Name,
+		Contact: booking.Driver.Contact,
+		RegNo:   booking.Vehicle.RegNo,
+		Model:   booking.Vehicle.Model,
+		Color:   booking.Vehicle.Color,
+	}, nil
+}
+
+// Stream contains the details of an active booking that a viewer has joined
+type Stream struct {
+	// Location is the payload of the last known location of the booking
+	Location string
+	Slot     partitions.Slot
+	// Offset is the offset of the location bus that the booking started at
+	Offset int64
+}
+
+// JoinStream is a function that is used to add a viewer to the stream of the given booking while making sure that
+// the stream does not exceed the maximum number of viewers, LeaveStream must be called when the viewer leaves
+func JoinStream(ctx context.Context, e *env.Env, c *connections.C, bookingID string) (*Stream, error) {
+	val, _ := c.State.GetBooking(ctx, bookingID)
+	if val == "" {
+		return nil, errors.ErrBookingIDNotValid
+	}
+	BookingID := _lib.NewBookingID()
+	err := sonic.UnmarshalString(val, &BookingID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal the value from Redis")
+		return nil, errors.ErrServer
+	}
+	slot, offset, _, err := _lib.GetBookingID(BookingID, e.Topic)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tredis_value : %s\tfailed to parse the booking",
+				bookingID,
+				val,
+			)
+		return nil, errors.ErrServer
+	}
+
+	val, _ = c.State.GetViewers(ctx, slot)
+	if val == "" {
+		log.Warn().Msg("number of connections is not present in the redis db")
+		return nil, errors.ErrServer
+	}
+	viewers, err := strconv.Atoi(val)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to convert the maximum connections to int")
+		return nil, errors.ErrServer
+	}
+	if viewers >= e.Settings().MaxConnections {
+		log.Warn().
+			Msgf(
+				"booking_id : %s\tconnections : %d\tmaximum number of connections reached for the booking",
+				bookingID,
+				viewers,
+			)
+		return nil, errors.ErrTooManyViewers
+	}
+
+	location, _ := c.State.GetLastLocation(ctx, slot)
+	if location == "" {
+		log.Error().Msg("failed to get the last location from redis")
+		return nil, errors.ErrServer
+	}
+
+	if err = c.State.IncrViewers(ctx, slot); err != nil {
+		log.Error().Err(err).Msg("failed to increment the number of connections")
+		return nil, errors.ErrServer
+	}
+
+	return &Stream{
+		Location: location,
+		Slot:     slot,
+		Offset:   offset,
+	}, nil
+}
+
+// LeaveStream is a function that is used to remove a viewer from the stream of the given slot
+func LeaveStream(c *connections.C, slot partitions.Slot) {
+	err := c.State.DecrViewers(context.Background(), slot)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to decrement the number of connections")
+	}
+}
+
+const (
+	// journeyRetry is the delay before the stops of a booking are read again after they fail to be read, the
+	// delay doubles with every failure up to maxJourneyRetry
+	journeyRetry = 5 * time.Second
+	// maxJourneyRetry is the longest delay before the stops of a booking are read again
+	maxJourneyRetry = 2 * time.Minute
+)
+
+// Feed is used to follow the messages of the location bus of a booking for a single viewer
+type Feed struct {
+	journey *Journey
+	// retryAt is the time that the stops of the booking are read again after they fail to be read
+	retryAt time.Time
+	// retries is the number of times that the stops of the booking fail to be read in a row
+	retries   int
+	bookingID string
+	// Location is the payload of the last location that is sent to the viewer in either of the schemas of the
+	// location bus
+	Location []byte
+	// latest is the last location that is sent to the viewer, the viewer is only sent the locations that are
+	// recorded after it so that the buffered locations that arrive late do not move the driver backwards
+	latest types.StoredLocation
+	ended  bool
+}
+
+// NewFeed is a function that is used to create the feed of a viewer that has been sent the given location
+func NewFeed(bookingID string, location string) *Feed {
+	f := &Feed{
+		bookingID: bookingID,
+		Location:  []byte(location),
+	}
+	_ = sonic.UnmarshalString(location, &f.latest)
+
+	return f
+}
+
+// Next is used to check wether the given message of the location bus is sent to the viewer, the type of the
+// message is returned along with it
+func (f *Feed) Next(value []byte) (string, bool) {
+	// the messages that are not locations are sent as they are
+	if kind := types.MessageType(value); kind != "" {
+		if kind == EndedMessage {
+			f.ended = true
+		}
+		return kind, true
+	}
+
+	if stored, err := types.ReadStoredLocation(value); err == nil {
+		if stored.Before(f.latest) {
+			return "", false
+		}
+		f.latest = stored
+	}
+
+	f.Location = value
+	return LocationMessage, true
+}
+
+// Ended is used to check wether the booking has ended or the job is cleared at the last location that is sent to
+// the viewer, the stream ends once the job is cleared
+func (f *Feed) Ended() bool {
+	return f.ended || f.latest.JobStatus() == _lib.Clear
+}
+
+// Transition is used to get the change of the job status at the last location that is sent to the viewer, nil is
+// returned when the job status does not change at the location
+func (f *Feed) Transition() *types.Transition {
+	return f.latest.Transition
+}
+
+// loadJourney is used to get the stops of the booking, the stops are geocoded on the first estimate so that the
+// first locations are not delayed, only the stops that are read successfully are kept and the stops that fail to be
+// read are read again after a delay that grows with every failure, nil is returned until the stops are read
+func (f *Feed) loadJourney(ctx context.Context, c *connections.C, now time.Time) *Journey {
+	if f.journey != nil {
+		return f.journey
+	}
+	if now.Before(f.retryAt) {
+		return nil
+	}
+
+	journey, err := NewJourney(ctx, c, f.bookingID)
+	if err != nil {
+		delay := min(journeyRetry<<f.retries, maxJourneyRetry)
+		f.retries++
+		f.retryAt = now.Add(delay)
+
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tretry_in : %s\tfailed to get the stops of the booking",
+				f.bookingID,
+				delay,
+			)
+		return nil
+	}
+
+	f.journey = journey
+	return journey
+}
+
+// ETA is used to get the time of arrival to the next stop from the last location that is sent to the viewer,
+// nil is returned when there is nothing to send
+func (f *Feed) ETA(ctx context.Context, c *connections.C, now time.Time) []byte {
+	journey := f.loadJourney(ctx, c, now)
+	if journey == nil {
+		return nil
+	}
+
+	arrival, err := journey.Estimate(ctx, c, f.latest, now)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"booking_id : %s\tfailed to estimate the time of arrival",
+				f.bookingID,
+			)
+		return nil
+	}
+	if arrival == nil {
+		return nil
+	}
+
+	payload, err := sonic.Marshal(arrival)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal the time of arrival")
+		return nil
+	}
+
+	return payload
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geofence"
+)
+
+// NewGeofence is a function that is used to create the state of the geofences around the given stops of a booking
+func NewGeofence(pickups, dropoffs []Geo) geofence.State {
+	fences := make([]geofence.Fence, 0, len(pickups)+len(dropoffs))
+	for i, stop := range pickups {
+		fences = append(fences, geofence.Fence{
+			Kind:  geofence.Pickup,
+			Index: i,
+			Lat:   stop.Lat,
+			Lon:   stop.Lon,
+		})
+	}
+	for i, stop := range dropoffs {
+		fences = append(fences, geofence.Fence{
+			Kind:  geofence.Dropoff,
+			Index: i,
+			Lat:   stop.Lat,
+			Lon:   stop.Lon,
+		})
+	}
+
+	return geofence.NewState(fences)
+}
+
+// advance is used to get the job status that the given event moves the job to, false is returned when the
+// event does not move the job
+func advance(event *geofence.Event) (_lib.JobStatus, bool) {
+	if event.Kind != geofence.Pickup {
+		return 0, false
+	}
+
+	switch event.Type {
+	case geofence.Arrival:
+		return _lib.PickupPoint, true
+	case geofence.Departure:
+		return _lib.PassengerOnBoard, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// slotLockTTL is the longest time that the lock of a slot is held for while its locations are ingested, so that
+	// an instance that stops while holding the lock does not block the slot
+	slotLockTTL = 10 * time.Second
+	// slotLockWait is the longest time to wait for the lock of a slot
+	slotLockWait = 5 * time.Second
+)
+
+// Ingested contains the outcome of the locations that are sent by the driver
+type Ingested struct {
+	// Published is the number of locations that are published to the location bus
+	Published int
+	// Duplicates is the number of locations whose location index is already published
+	Duplicates int
+	// Rejected is the number of locations that are rejected by the filter
+	Rejected int
+	// Ends is set when one of the published locations clears the job, the booking must be ended by the caller
+	Ends bool
+}
+
+// CheckRecordedAt is a function that is used to make sure that the device time of every given location is within the
+// clock skew window of the given settings around the given received time, the locations without a device time are
+// always within it, the index of the first location that is outside of the window is returned along with false
+func CheckRecordedAt(settings *env.Settings, locations []types.LocationUpdate, receivedAt time.Time) (int, bool) {
+	skew, maxAge := settings.ClockSkewWindow()
+	for i := range locations {
+		if !locations[i].CheckRecordedAt(receivedAt, skew, maxAge) {
+			return i, false
+		}
+	}
+
+	return -1, true
+}
+
+// IngestLocations is a function that is used to publish the given locations of the driver to the stream of the
+// given slot, every path that receives locations from the driver goes through it
+//  1. the locations are ordered by their location index and the indexes that are already published are skipped
+//  2. the locations are passed through ProcessLocations
+//  3. the accepted locations are published to the location bus in a single batch
+//  4. the last known location is moved forward to the last published location when saveLast is set
+//
+// The lock of the slot is held from the second stage to the end, so that the locations that are received by
+// different instances at the same time are processed and published one request after another
+//
+// The locations without a location index can not be deduplicated so they are always published, the location
+// indexes are forgotten again when none of the locations are published so that the driver can send them again
+func IngestLocations(
+	ctx context.Context,
+	e *env.Env,
+	c *connections.C,
+	settings *env.Settings,
+	slot partitions.Slot,
+	driverID int,
+	locations []types.LocationUpdate,
+	receivedAt time.Time,
+	saveLast bool,
+) (Ingested, error) {
+	sort.SliceStable(locations, func(i, j int) bool {
+		if locations[i].LocationIndex == nil || locations[j].LocationIndex == nil {
+			return false
+		}
+		return *locations[i].LocationIndex < *locations[j].LocationIndex
+	})
+
+	var (
+		unique  []types.LocationUpdate
+		indexes []int
+		indexed []int
+	)
+	for i, location := range locations {
+		if location.LocationIndex == nil {
+			unique = append(unique, location)
+			continue
+		}
+		if i > 0 && locations[i-1].LocationIndex != nil && *location.LocationIndex == *locations[i-1].LocationIndex {
+			continue
+		}
+
+		indexed = append(indexed, len(unique))
+		unique = append(unique, location)
+		indexes = append(indexes, *location.LocationIndex)
+	}
+
+	fresh := make([]bool, len(unique))
+	for i := range fresh {
+		fresh[i] = true
+	}
+	if len(indexes) > 0 {
+		marks, err := c.State.MarkLocations(ctx, slot, indexes)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"slot : %s\tdriver_id : %d\tfailed to record the location indexes",
+					slot,
+					driverID,
+				)
+			return Ingested{}, errors.ErrServer
+		}
+		for i, j := range indexed {
+			fresh[j] = marks[i]
+		}
+	}
+
+	var (
+		marked  []int
+		pending []types.LocationUpdate
+	)
+	for i, location := range unique {
+		if !fresh[i] {
+			continue
+		}
+		if location.LocationIndex != nil {
+			marked = append(marked, *location.LocationIndex)
+		}
+		pending = append(pending, location)
+	}
+	ingested := Ingested{
+		Duplicates: len(locations) - len(pending),
+	}
+
+	// the location indexes are forgotten when none of the locations are published so they can be sent again
+	unmark := func() {
+		if len(marked) == 0 {
+			return
+		}
+		if err := c.State.UnmarkLocations(context.Background(), slot, marked); err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"slot : %s\tfailed to forget the location indexes",
+					slot,
+				)
+		}
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, slotLockWait)
+	unlock, err := c.State.LockSlot(lockCtx, slot, slotLockTTL)
+	cancel()
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tdriver_id : %d\tfailed to hold the lock of the slot",
+				slot,
+				driverID,
+			)
+		unmark()
+		return Ingested{}, errors.ErrServer
+	}
+	defer unlock()
+
+	// the rejected locations stay recorded so that sending them again does not feed them to the filter again
+	accepted, err := ProcessLocations(ctx, c, settings, slot, pending, receivedAt)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tdriver_id : %d\tthe job can not move to one of the given statuses",
+				slot,
+				driverID,
+			)
+		unmark()
+		return Ingested{}, err
+	}
+
+	var (
+		published []types.LocationUpdate
+		values    [][]byte
+		last      string
+	)
+	encoding := enums.Encoding(settings.BusEncoding)
+	for i, location := range pending {
+		if !accepted[i] {
+			continue
+		}
+
+		blob := location.GetBlob(receivedAt)
+		payload, err := sonic.Marshal(blob)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to marshal the payload")
+			unmark()
+			return Ingested{}, errors.ErrServer
+		}
+
+		if location.Ends() {
+			ingested.Ends = true
+		}
+		published = append(published, location)
+		values = append(values, blob.Encode(encoding, payload))
+		// the last known location is always kept in JSON
+		last = string(payload)
+	}
+	ingested.Published = len(values)
+	ingested.Rejected = len(pending) - len(values)
+	if len(values) == 0 {
+		return ingested, nil
+	}
+
+	err = c.Bus.PublishBatch(context.Background(), slot, []byte(strconv.Itoa(driverID)), values)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tdriver_id : %d\tlocations : %d\tfailed to publish the locations",
+				slot,
+				driverID,
+				len(values),
+			)
+		unmark()
+		return Ingested{}, errors.ErrServer
+	}
+
+	go AlertDeviations(e, published...)
+
+	// the last location of a stream that ends is removed along with the booking
+	if saveLast && !ingested.Ends {
+		saveLastLocation(c, slot, published[len(published)-1], last)
+	}
+
+	return ingested, nil
+}
+
+// saveLastLocation is used to move the last known location of the given slot forward to the given location, an
+// older location never replaces it, a location without a location index can not be ordered so it always replaces it
+func saveLastLocation(c *connections.C, slot partitions.Slot, location types.LocationUpdate, payload string) {
+	var err error
+	if location.LocationIndex != nil {
+		_, err = c.State.AdvanceLastLocation(context.Background(), slot, *location.LocationIndex, payload)
+	} else {
+		err = c.State.SetLastLocation(context.Background(), slot, payload)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"payload : %s\tfailed to set the live location",
+				payload,
+			)
+	}
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// testIngest is used to get the connections and the settings that the locations of the given slot are ingested with
+func testIngest(t *testing.T, slot partitions.Slot) (*connections.C, *env.Settings) {
+	t.Helper()
+
+	c := &connections.C{
+		State: connections.NewMemoryState(),
+		Bus:   connections.NewMemoryBus(1),
+	}
+	err := c.State.CreateBooking(context.Background(), connections.BookingState{
+		BookingID: "B1",
+		Booking:   "{}",
+		Driver:    "{}",
+		Location:  `{"lat":51.5,"lon":-0.12}`,
+		Backup:    "B1",
+		Slot:      slot,
+		DriverID:  7,
+		TTL:       time.Hour,
+		BackupTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, &env.Settings{
+		Filter:         "none",
+		MaxSpeed:       70,
+		GeofenceRadius: 100,
+		StaleAfter:     30,
+		LostAfter:      120,
+		BusEncoding:    "json",
+	}
+}
+
+// testLocation is used to get a location with the given location index, nil leaves the location index out
+func testLocation(index *int) types.LocationUpdate {
+	return types.LocationUpdate{
+		Lat:           51.5,
+		Lon:           -0.12,
+		LocationIndex: index,
+	}
+}
+
+// lastIndex is used to get the location index of the last known location of the given slot
+func lastIndex(t *testing.T, c *connections.C, slot partitions.Slot) int {
+	t.Helper()
+
+	val, err := c.State.GetLastLocation(context.Background(), slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var location types.Location
+	if err := sonic.UnmarshalString(val, &location); err != nil {
+		t.Fatal(err)
+	}
+
+	return location.LocationIndex
+}
+
+func TestIngestLocations(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	e := &env.Env{}
+
+	ingest := func(saveLast bool, locations ...types.LocationUpdate) Ingested {
+		t.Helper()
+
+		ingested, err := IngestLocations(ctx, e, c, settings, slot, 7, locations, time.Now(), saveLast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ingested
+	}
+	index := func(i int) *int {
+		return &i
+	}
+
+	// the batch is published in the order of the location indexes without the repeated indexes
+	ingested := ingest(true, testLocation(index(3)), testLocation(index(2)), testLocation(index(3)))
+	if ingested.Published != 2 || ingested.Duplicates != 1 {
+		t.Fatalf("got %+v, want 2 published and 1 duplicate", ingested)
+	}
+	messages, err := c.Bus.ReadRange(ctx, slot, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{2, 3} {
+		var location types.Location
+		if err := sonic.Unmarshal(messages[i].Value, &location); err != nil || location.LocationIndex != want {
+			t.Errorf("message %d: got the location index %d, %v, want %d", i, location.LocationIndex, err, want)
+		}
+	}
+	if got := lastIndex(t, c, slot); got != 3 {
+		t.Errorf("got the last location index %d, want 3", got)
+	}
+
+	// a single location that is already published is skipped like the locations of a batch
+	if ingested = ingest(true, testLocation(index(2))); ingested.Published != 0 || ingested.Duplicates != 1 {
+		t.Errorf("got %+v, want the location to be a duplicate", ingested)
+	}
+
+	// an older location is published but it does not replace the last known location
+	if ingested = ingest(true, testLocation(index(1))); ingested.Published != 1 {
+		t.Errorf("got %+v, want the location to be published", ingested)
+	}
+	if got := lastIndex(t, c, slot); got != 3 {
+		t.Errorf("got the last location index %d, want the last location to stay at 3", got)
+	}
+
+	// the last known location is left alone when it is not asked to be saved
+	if ingested = ingest(false, testLocation(index(4))); ingested.Published != 1 {
+		t.Errorf("got %+v, want the location to be published", ingested)
+	}
+	if got := lastIndex(t, c, slot); got != 3 {
+		t.Errorf("got the last location index %d, want the last location to stay at 3", got)
+	}
+
+	// the locations without a location index can not be deduplicated
+	for i := 0; i < 2; i++ {
+		if ingested = ingest(true, testLocation(nil)); ingested.Published != 1 {
+			t.Errorf("got %+v, want every location without an index to be published", ingested)
+		}
+	}
+	if last, _ := c.Bus.LastOffset(ctx, slot); last != 6 {
+		t.Errorf("got %d messages in the location bus, want 6", last)
+	}
+}
+
+// slowTrack is a state store that takes a while to read the track like a state store over the network does, so
+// that the requests that are not serialized read the same track
+type slowTrack struct {
+	connections.StateStore
+}
+
+// GetTrack is used to get the state of the track of the given slot, the state is returned after a short delay
+func (s slowTrack) GetTrack(ctx context.Context, slot partitions.Slot) (string, error) {
+	val, err := s.StateStore.GetTrack(ctx, slot)
+	time.Sleep(time.Millisecond)
+
+	return val, err
+}
+
+func TestIngestLocationsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	c.State = slowTrack{c.State}
+	e := &env.Env{}
+	receivedAt := time.Now()
+
+	// every request moves the driver further north, the requests read and write the same track state
+	const requests = 20
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			location := testLocation(&i)
+			location.Lat += float64(i) * 0.001
+			if _, err := IngestLocations(ctx, e, c, settings, slot, 7, []types.LocationUpdate{location}, receivedAt, true); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	messages, err := c.Bus.ReadRange(ctx, slot, 0, requests)
+	if err != nil || len(messages) != requests {
+		t.Fatalf("got %d messages, %v, want %d", len(messages), err, requests)
+	}
+
+	// the distance only grows in the order of the location bus when no request overwrites the track of another
+	previous := -1.0
+	for _, message := range messages {
+		var location types.Location
+		if err := sonic.Unmarshal(message.Value, &location); err != nil {
+			t.Fatal(err)
+		}
+		distance := 0.0
+		if location.Distance != nil {
+			distance = *location.Distance
+		}
+		if distance <= previous {
+			t.Errorf("location %d: got a distance of %f after %f, want the distance to grow", location.LocationIndex, distance, previous)
+		}
+		previous = distance
+	}
+}
+
+// failingPublish is a location bus whose first publish fails, like a broker that does not acknowledge the write
+type failingPublish struct {
+	connections.LocationBus
+	failed bool
+}
+
+// PublishBatch is used to publish the given values to the given slot, the first batch fails
+func (b *failingPublish) PublishBatch(ctx context.Context, slot partitions.Slot, key []byte, values [][]byte) error {
+	if !b.failed {
+		b.failed = true
+		return errors.New("not enough replicas")
+	}
+
+	return b.LocationBus.PublishBatch(ctx, slot, key, values)
+}
+
+func TestIngestLocationsPublishFailure(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	c.Bus = &failingPublish{LocationBus: c.Bus}
+	e := &env.Env{}
+
+	index := 1
+	locations := []types.LocationUpdate{testLocation(&index)}
+	if _, err := IngestLocations(ctx, e, c, settings, slot, 7, locations, time.Now(), true); err == nil {
+		t.Fatal("want the failed publish to be reported")
+	}
+
+	// the location that fails to be published is not a duplicate when it is sent again
+	ingested, err := IngestLocations(ctx, e, c, settings, slot, 7, locations, time.Now(), true)
+	if err != nil || ingested.Published != 1 || ingested.Duplicates != 0 {
+		t.Errorf("got %+v, %v, want the location to be published", ingested, err)
+	}
+	if last, _ := c.Bus.LastOffset(ctx, slot); last != 1 {
+		t.Errorf("got %d messages in the location bus, want 1", last)
+	}
+}
+
+func TestCheckRecordedAt(t *testing.T) {
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	_, settings := testIngest(t, slot)
+	settings.ClockSkew = 60
+	settings.MaxLocationAge = 3600
+
+	receivedAt := time.Now()
+	at := func(offset time.Duration) types.LocationUpdate {
+		location := testLocation(nil)
+		recordedAt := receivedAt.Add(offset)
+		location.RecordedAt = &recordedAt
+		return location
+	}
+	tests := []struct {
+		name      string
+		locations []types.LocationUpdate
+		index     int
+		ok        bool
+	}{
+		{"missing", []types.LocationUpdate{testLocation(nil)}, -1, true},
+		{"received", []types.LocationUpdate{at(0)}, -1, true},
+		{"within skew", []types.LocationUpdate{at(30 * time.Second)}, -1, true},
+		{"at skew", []types.LocationUpdate{at(time.Minute)}, -1, true},
+		{"future", []types.LocationUpdate{at(2 * time.Minute)}, 0, false},
+		{"within age", []types.LocationUpdate{at(-30 * time.Minute)}, -1, true},
+		{"stale", []types.LocationUpdate{at(-2 * time.Hour)}, 0, false},
+		{"batch", []types.LocationUpdate{at(0), at(-2 * time.Hour), at(2 * time.Minute)}, 1, false},
+		{"batch missing", []types.LocationUpdate{testLocation(nil), at(-time.Minute), testLocation(nil)}, -1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, ok := CheckRecordedAt(settings, test.locations, receivedAt)
+			if index != test.index || ok != test.ok {
+				t.Errorf("got %d, %v, want %d, %v", index, ok, test.index, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/deviation"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/filter"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geofence"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/track"
+	"github.com/rs/zerolog/log"
+)
+
+// FilterConfig is a function that is used to get the configuration of the location filter from the given settings
+func FilterConfig(settings *env.Settings) filter.Config {
+	return filter.Config{
+		Mode:        filter.Mode(settings.Filter),
+		MaxSpeed:    float64(settings.MaxSpeed),
+		MaxAccuracy: float64(settings.MaxAccuracy),
+	}
+}
+
+// GeofenceConfig is a function that is used to get the configuration of the geofences from the given settings
+func GeofenceConfig(settings *env.Settings) geofence.Config {
+	return geofence.Config{
+		Radius: float64(settings.GeofenceRadius),
+		Dwell:  time.Duration(settings.GeofenceDwell) * time.Second,
+	}
+}
+
+// DeviationConfig is a function that is used to get the configuration of the route deviation from the given settings
+func DeviationConfig(settings *env.Settings) deviation.Config {
+	return deviation.Config{
+		Threshold: float64(settings.DeviationThreshold),
+	}
+}
+
+// GetJobState is a function that is used to get the job status and the timeline of the given slot
+func GetJobState(ctx context.Context, c *connections.C, slot partitions.Slot) types.JobState {
+	var job types.JobState
+	loadState(ctx, slot, "job", c.State.GetJobState, &job)
+
+	return job
+}
+
+// ProcessLocations is a function that is used to prepare the given locations of the given slot to be published,
+// every location is passed through the following stages in order
+//  1. the job status that the driver sends is checked against the current job status
+//  2. the location is filtered
+//  3. the speed, bearing and the distance travelled are derived
+//  4. the arrivals at and the departures from the stops are detected, they advance the job status when
+//     settings.AutoStatus is set
+//  5. the distance from the expected route is measured to detect when the driver leaves or returns to it
+//
+// The returned slice reports wether each location is accepted, the rejected locations must not be published.
+// A location that changes the job status is always accepted so that the timeline does not lose the transition.
+// An error is returned without changing any of the states when the driver sends a status that the job can not
+// move to
+// NOTE: the caller must hold the lock of the slot since the states are read and written back separately
+func ProcessLocations(
+	ctx context.Context,
+	c *connections.C,
+	settings *env.Settings,
+	slot partitions.Slot,
+	locations []types.LocationUpdate,
+	receivedAt time.Time,
+) ([]bool, error) {
+	job := GetJobState(ctx, c, slot)
+
+	check := job
+	for _, location := range locations {
+		if location.Status == nil {
+			continue
+		}
+
+		status := _lib.JobStatus(*location.Status)
+		if err := check.Check(status); err != nil {
+			return nil, err
+		}
+		check.Status = &status
+		check.Assumed = false
+	}
+
+	var (
+		filterState    filter.State
+		trackState     track.State
+		geofenceState  geofence.State
+		deviationState deviation.State
+		reported       bool
+	)
+	loadState(ctx, slot, "filter", c.State.GetFilter, &filterState)
+	loadState(ctx, slot, "track", c.State.GetTrack, &trackState)
+	fenced := loadState(ctx, slot, "geofence", c.State.GetGeofence, &geofenceState)
+	routed := loadState(ctx, slot, "deviation", c.State.GetDeviation, &deviationState)
+
+	filterConfig := FilterConfig(settings)
+	geofenceConfig := GeofenceConfig(settings)
+	deviationConfig := DeviationConfig(settings)
+
+	accepted := make([]bool, len(locations))
+	for i := range locations {
+		location := &locations[i]
+		at := location.Time(receivedAt)
+		accepted[i] = true
+
+		if location.Status != nil {
+			location.Transition = job.Move(_lib.JobStatus(*location.Status), types.Driver, location, at.UnixMilli())
+		}
+
+		point := filter.Point{
+			Time: at,
+			Lat:  location.Lat,
+			Lon:  location.Lon,
+		}
+		if location.Accuracy != nil {
+			point.Accuracy = *location.Accuracy
+		}
+		if !filterState.Apply(filterConfig, &point) {
+			log.Warn().
+				Msgf(
+					"slot : %s\tlat : %f\tlon : %f\taccuracy : %f\trejected : %d\trejected the location",
+					slot,
+					location.Lat,
+					location.Lon,
+					point.Accuracy,
+					filterState.Rejected,
+				)
+
+			accepted[i] = location.Transition != nil
+			setStatus(location, job)
+			continue
+		}
+		location.Lat = point.Lat
+		location.Lon = point.Lon
+
+		if motion, ok := trackState.Apply(location.Lat, location.Lon, at); ok {
+			location.Motion = &motion
+		}
+
+		if fenced {
+			location.Event = geofenceState.Apply(geofenceConfig, location.Lat, location.Lon, at)
+		}
+		if location.Event != nil {
+			log.Info().
+				Msgf(
+					"slot : %s\tevent : %s\tkind : %s\tindex : %d\tgeofence event ... ",
+					slot,
+					location.Event.Type,
+					location.Event.Kind,
+					location.Event.Index,
+				)
+
+			status, ok := advance(location.Event)
+			if settings.AutoStatus && ok && job.Status != nil && job.Status.CanTransition(status) {
+				location.Transition = job.Move(status, types.Geofence, location, at.UnixMilli())
+			}
+		}
+
+		if routed {
+			location.Deviation = deviationState.Apply(deviationConfig, location.Lat, location.Lon, at)
+		}
+		if location.Deviation != nil {
+			log.Warn().
+				Msgf(
+					"slot : %s\tevent : %s\tdistance : %f\troute deviation event ... ",
+					slot,
+					location.Deviation.Type,
+					location.Deviation.Distance,
+				)
+			reported = true
+		}
+
+		setStatus(location, job)
+	}
+
+	saveState(ctx, slot, "job", c.State.SetJobState, job)
+	saveState(ctx, slot, "filter", c.State.SetFilter, filterState)
+	saveState(ctx, slot, "track", c.State.SetTrack, trackState)
+	if fenced {
+		saveState(ctx, slot, "geofence", c.State.SetGeofence, geofenceState)
+	}
+	// the driver is online even when every location is rejected
+	if err := c.State.SetLastUpdate(ctx, slot, receivedAt.UnixMilli()); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to record the time of the last location",
+				slot,
+			)
+	}
+	// the route does not change, so the state is only written when the driver leaves or returns to it
+	if reported {
+		saveState(ctx, slot, "deviation", c.State.SetDeviation, deviationState)
+	}
+
+	return accepted, nil
+}
+
+// setStatus is used to set the current job status to the location, so that every published location carries
+// the status of the job
+func setStatus(location *types.LocationUpdate, job types.JobState) {
+	if job.Status == nil {
+		return
+	}
+
+	status := int64(*job.Status)
+	location.Status = &status
+}
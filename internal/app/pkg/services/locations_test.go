@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+func TestProcessLocationsAutoStatus(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	settings.AutoStatus = true
+
+	// the booking is created with the geofences around its stops and the default job status
+	pickup := Geo{Lat: 51.5, Lon: -0.12}
+	geofenceState, err := sonic.MarshalString(NewGeofence([]Geo{pickup}, []Geo{{Lat: 51.6, Lon: -0.2}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobState, err := sonic.MarshalString(types.NewJobState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.State.SetGeofence(ctx, slot, geofenceState); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.State.SetJobState(ctx, slot, jobState); err != nil {
+		t.Fatal(err)
+	}
+
+	// the driver only sends the coordinates, at the pickup and then away from it
+	at := time.Now()
+	process := func(lat, lon float64) types.LocationUpdate {
+		t.Helper()
+
+		locations := []types.LocationUpdate{{Lat: lat, Lon: lon}}
+		accepted, err := ProcessLocations(ctx, c, settings, slot, locations, at)
+		if err != nil || !accepted[0] {
+			t.Fatalf("got %v, %v, want the location to be accepted", accepted, err)
+		}
+		at = at.Add(time.Minute)
+		return locations[0]
+	}
+
+	for _, step := range []struct {
+		lat, lon float64
+		want     _lib.JobStatus
+	}{
+		{lat: pickup.Lat, lon: pickup.Lon, want: _lib.PickupPoint},
+		{lat: 51.55, lon: -0.15, want: _lib.PassengerOnBoard},
+	} {
+		location := process(step.lat, step.lon)
+		if location.Transition == nil || location.Transition.Status != step.want || location.Transition.Source != types.Geofence {
+			t.Errorf("got the transition %+v, want the geofence to move the job to %d", location.Transition, step.want)
+		}
+		if location.Status == nil || _lib.JobStatus(*location.Status) != step.want {
+			t.Errorf("got the status %v, want %d", location.Status, step.want)
+		}
+	}
+
+	job := GetJobState(ctx, c, slot)
+	if job.Status == nil || *job.Status != _lib.PassengerOnBoard || len(job.Timeline) != 2 || job.Assumed {
+		t.Errorf("got %+v, want the passenger to be on board after two transitions", job)
+	}
+}
+
+func TestProcessLocationsAssumedStatus(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+
+	jobState, err := sonic.MarshalString(types.NewJobState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.State.SetJobState(ctx, slot, jobState); err != nil {
+		t.Fatal(err)
+	}
+
+	process := func(status int64) error {
+		location := testLocation(nil)
+		location.Status = &status
+		_, err := ProcessLocations(ctx, c, settings, slot, []types.LocationUpdate{location}, time.Now())
+		return err
+	}
+
+	// the default status that the job is created with does not keep the driver from sending an earlier status,
+	// but the statuses that the driver sends can only move forward
+	if err := process(int64(_lib.Accepted)); err != nil {
+		t.Errorf("got %v, want the driver to accept the job", err)
+	}
+	if err := process(int64(_lib.NotAccepted)); err == nil {
+		t.Error("want the job not to move back")
+	}
+}
@@ -2,48 +2,38 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
-	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
-	"googlemaps.github.io/maps"
 )
 
 // Geo lat-lon direction mapping
-type Geo struct {
-	Lat float64 `json:"lat"`
-	Lon float64 `json:"lon"`
-}
+type Geo = connections.Geo
 
 // Geocode convert the string paths to lat-lon paths
+//
+// Addresses that cannot be geocoded are skipped and reported in the returned error as
+// connections.GeocodeError values, so the caller always gets the addresses that were resolved
 func Geocode(
 	ctx context.Context,
-	e *env.Env,
 	c *connections.C,
-	init bool,
 	paths []string,
-) []Geo {
-	if init {
-		c.InitMap(e)
-	}
-
+) ([]Geo, error) {
 	payload := []Geo{}
+	errs := []error{}
 
 	for _, path := range paths {
-		route, err := c.M.Geocode(ctx, &maps.GeocodingRequest{
-			Address: path,
-		})
+		geo, err := c.Geo.Geocode(ctx, path)
 		if err != nil {
-			return []Geo{}
-		}
-		if len(route) == 0 {
+			errs = append(errs, &connections.GeocodeError{
+				Address: path,
+				Err:     err,
+			})
 			continue
 		}
 
-		payload = append(payload, Geo{
-			Lat: route[0].Geometry.Location.Lat,
-			Lon: route[0].Geometry.Location.Lng,
-		})
+		payload = append(payload, geo)
 	}
 
-	return payload
+	return payload, errors.Join(errs...)
 }
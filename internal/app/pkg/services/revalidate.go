@@ -21,6 +21,10 @@ const (
 
 // Revalidate is a service that is used to revalidate the path of a cache depending on the backend state
 func Revalidate(e *env.Env, paths []Paths) {
+	if !e.HasEdgeConfig() {
+		return
+	}
+
 	config := NewEdgeConfig(e.EdgeConfig, e.EdgeConfigReadToken, e.VercelToken)
 	val, err := config.Read("secret")
 	if err != nil {
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/signal"
+	"github.com/rs/zerolog/log"
+)
+
+// signalInterval is the frequency to check the signal status of the active bookings
+const signalInterval = 5 * time.Second
+
+// SignalMessage is the type of the messages of the location bus that report the signal status of the driver
+const SignalMessage = "signal"
+
+// SignalConfig is a function that is used to get the thresholds of the signal status from the given settings
+func SignalConfig(settings *env.Settings) signal.Config {
+	return signal.Config{
+		Stale: time.Duration(settings.StaleAfter) * time.Second,
+		Lost:  time.Duration(settings.LostAfter) * time.Second,
+	}
+}
+
+// Signal is the message that is published to the viewers when the signal status of the driver changes
+type Signal struct {
+	Type   string        `json:"type"`
+	Status signal.Status `json:"status"`
+	// LastUpdate is the time that the driver last sent a location in unix milliseconds
+	LastUpdate int64 `json:"last_update"`
+	// At is the time that the signal status changed in unix milliseconds
+	At int64 `json:"at"`
+}
+
+// WatchSignals is a function that is used to keep checking the time that the driver of every active booking last
+// sent a location until the given context is done, the viewers of the booking are sent a signal message and the
+// driver alerts of the dashboard are refreshed when the signal status of the driver changes
+//
+// NOTE: The signal status is swapped in the state store, so only one of the servers reports each change
+func WatchSignals(ctx context.Context, e *env.Env, c *connections.C) {
+	ticker := time.NewTicker(signalInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			slots, err := c.State.Partitions(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to get the partitions that are in use")
+				continue
+			}
+
+			config := SignalConfig(e.Settings())
+			changed := false
+			for _, slot := range slots {
+				if checkSignal(ctx, c, config, slot, now) {
+					changed = true
+				}
+			}
+
+			if changed {
+				go Revalidate(e, []Paths{
+					Alerts,
+				})
+			}
+		}
+	}
+}
+
+// CurrentSignal is a function that is used to get the signal message of the current signal status of the given
+// slot, nil is returned while the signal is live
+func CurrentSignal(ctx context.Context, c *connections.C, settings *env.Settings, slot partitions.Slot) *Signal {
+	val, _ := c.State.GetLastUpdate(ctx, slot)
+	lastUpdate, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	status := SignalConfig(settings).Classify(time.UnixMilli(lastUpdate), now)
+	if status == signal.Live {
+		return nil
+	}
+
+	return &Signal{
+		Type:       SignalMessage,
+		Status:     status,
+		LastUpdate: lastUpdate,
+		At:         now.UnixMilli(),
+	}
+}
+
+// checkSignal is used to update the signal status of the given slot, true is returned when the signal status
+// changes
+func checkSignal(ctx context.Context, c *connections.C, config signal.Config, slot partitions.Slot, now time.Time) bool {
+	val, err := c.State.GetLastUpdate(ctx, slot)
+	if err != nil || val == "" {
+		// the slot does not have an active booking
+		return false
+	}
+	lastUpdate, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tlast_update : %s\tfailed to parse the time of the last location",
+				slot,
+				val,
+			)
+		return false
+	}
+
+	status := config.Classify(time.UnixMilli(lastUpdate), now)
+	previous, err := c.State.SwapSignal(ctx, slot, string(status))
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to swap the signal status",
+				slot,
+			)
+		return false
+	}
+	// the signal of a booking starts as live, so that it is not reported until it goes stale
+	if previous == string(status) || (previous == "" && status == signal.Live) {
+		return false
+	}
+
+	log.Warn().
+		Msgf(
+			"slot : %s\tprevious : %s\tstatus : %s\tlast_update : %d\tthe signal status of the driver has changed",
+			slot,
+			previous,
+			status,
+			lastUpdate,
+		)
+
+	payload, err := sonic.Marshal(Signal{
+		Type:       SignalMessage,
+		Status:     status,
+		LastUpdate: lastUpdate,
+		At:         now.UnixMilli(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal the signal message")
+		return true
+	}
+
+	if err = c.Bus.Publish(ctx, slot, nil, payload); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to publish the signal message",
+				slot,
+			)
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/signal"
+)
+
+func TestCheckSignal(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	config := SignalConfig(settings)
+
+	lastUpdate := time.UnixMilli(1700000000000)
+	if err := c.State.SetLastUpdate(ctx, slot, lastUpdate.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+
+	// every check is one of the servers checking the slot at the given number of seconds after the last location
+	steps := []struct {
+		seconds int
+		want    signal.Status
+		changed bool
+	}{
+		// the signal of a booking starts as live so it is not reported
+		{5, signal.Live, false},
+		{30, signal.Stale, true},
+		// the change is only reported by the first server that sees it
+		{31, signal.Stale, false},
+		{120, signal.Lost, true},
+		{125, signal.Lost, false},
+	}
+
+	offset := int64(0)
+	for _, step := range steps {
+		now := lastUpdate.Add(time.Duration(step.seconds) * time.Second)
+		if changed := checkSignal(ctx, c, config, slot, now); changed != step.changed {
+			t.Fatalf("%d seconds: got %t, want %t", step.seconds, changed, step.changed)
+		}
+		if !step.changed {
+			continue
+		}
+
+		messages, err := c.Bus.ReadRange(ctx, slot, offset, offset+1)
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("%d seconds: got %d messages, %v, want the signal message", step.seconds, len(messages), err)
+		}
+		offset++
+
+		var message Signal
+		if err := sonic.Unmarshal(messages[0].Value, &message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Type != SignalMessage || message.Status != step.want || message.LastUpdate != lastUpdate.UnixMilli() || message.At != now.UnixMilli() {
+			t.Errorf("%d seconds: got %+v, want the %s signal", step.seconds, message, step.want)
+		}
+	}
+	if last, _ := c.Bus.LastOffset(ctx, slot); last != offset {
+		t.Errorf("got %d messages in the location bus, want %d", last, offset)
+	}
+
+	// a new location brings the signal back to live, which is reported as well
+	now := lastUpdate.Add(130 * time.Second)
+	if err := c.State.SetLastUpdate(ctx, slot, now.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if !checkSignal(ctx, c, config, slot, now) {
+		t.Error("want the live signal to be reported")
+	}
+}
+
+func TestCheckSignalWithoutBooking(t *testing.T) {
+	slot := partitions.Slot{Topic: "locations", Partition: 3}
+	c, settings := testIngest(t, partitions.Slot{Topic: "locations", Partition: 0})
+
+	// a slot that never received a location does not have a signal
+	if checkSignal(context.Background(), c, SignalConfig(settings), slot, time.Now()) {
+		t.Error("want the slot without a booking to be skipped")
+	}
+}
+
+func TestCurrentSignal(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+
+	if current := CurrentSignal(ctx, c, settings, partitions.Slot{Topic: "locations", Partition: 3}); current != nil {
+		t.Errorf("got %+v, want no signal for the slot without a booking", current)
+	}
+
+	now := time.Now()
+	if err := c.State.SetLastUpdate(ctx, slot, now.UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if current := CurrentSignal(ctx, c, settings, slot); current != nil {
+		t.Errorf("got %+v, want no signal while it is live", current)
+	}
+
+	lastUpdate := now.Add(-time.Duration(settings.StaleAfter+1) * time.Second).UnixMilli()
+	if err := c.State.SetLastUpdate(ctx, slot, lastUpdate); err != nil {
+		t.Fatal(err)
+	}
+	current := CurrentSignal(ctx, c, settings, slot)
+	if current == nil || current.Status != signal.Stale || current.LastUpdate != lastUpdate {
+		t.Errorf("got %+v, want the stale signal since %d", current, lastUpdate)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+// loadState is used to read the state of the given slot with the given getter into v, false is returned when the
+// slot does not have the state or when it can not be read
+func loadState(
+	ctx context.Context,
+	slot partitions.Slot,
+	name string,
+	get func(ctx context.Context, slot partitions.Slot) (string, error),
+	v any,
+) bool {
+	val, err := get(ctx, slot)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to get the %s state",
+				slot,
+				name,
+			)
+		return false
+	}
+	if val == "" {
+		return false
+	}
+
+	if err = sonic.UnmarshalString(val, v); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tstate : %s\tfailed to parse the %s state",
+				slot,
+				val,
+				name,
+			)
+		return false
+	}
+
+	return true
+}
+
+// saveState is used to write v as the state of the given slot with the given setter
+func saveState(
+	ctx context.Context,
+	slot partitions.Slot,
+	name string,
+	set func(ctx context.Context, slot partitions.Slot, payload string) error,
+	v any,
+) {
+	payload, err := sonic.MarshalString(v)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to marshal the %s state",
+				slot,
+				name,
+			)
+		return
+	}
+
+	if err = set(ctx, slot, payload); err != nil {
+		log.Error().Err(err).
+			Msgf(
+				"slot : %s\tfailed to save the %s state",
+				slot,
+				name,
+			)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/trail"
+	"github.com/rs/zerolog/log"
+)
+
+// TrailMessage is the type of the message that contains the path of a booking so far
+const TrailMessage = "trail"
+
+// TrailConfig is a function that is used to get the configuration of the simplification of the path so far from
+// the given settings
+func TrailConfig(settings *env.Settings) trail.Config {
+	return trail.Config{
+		Tolerance: float64(settings.TrailTolerance),
+		MaxPoints: settings.TrailMaxPoints,
+	}
+}
+
+// TrailRequest is the part of the path of a booking that a viewer asks to be sent when it connects
+type TrailRequest struct {
+	// Since is the device time in unix milliseconds that the path starts at, zero starts at the start of the booking
+	Since int64
+}
+
+// ParseTrailRequest is a function that is used to get the part of the path that a viewer asks for with the
+// history=full or the since=<unix milliseconds> query parameters, nil is returned when the viewer does not ask for it
+func ParseTrailRequest(query url.Values) (*TrailRequest, error) {
+	if val := query.Get("since"); val != "" {
+		since, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || since < 0 {
+			return nil, errors.ErrBadRequest
+		}
+		return &TrailRequest{Since: since}, nil
+	}
+
+	switch query.Get("history") {
+	case "":
+		return nil, nil
+	case "full":
+		return &TrailRequest{}, nil
+	default:
+		return nil, errors.ErrBadRequest
+	}
+}
+
+// Replay is used to read the path of the given stream so far from the location bus since the booking started, the
+// locations of the path are ordered by their device time and simplified before they are returned in either of the
+// schemas of the location bus, the offset that the live messages start at is returned along with them so that the
+// viewer is sent every message once
+func (f *Feed) Replay(
+	ctx context.Context,
+	c *connections.C,
+	settings *env.Settings,
+	stream *Stream,
+	request *TrailRequest,
+) ([][]byte, int64, error) {
+	end, err := c.Bus.LastOffset(ctx, stream.Slot)
+	if err != nil {
+		return nil, 0, err
+	}
+	if stream.Offset >= end {
+		return nil, end, nil
+	}
+
+	messages, err := c.Bus.ReadRange(ctx, stream.Slot, stream.Offset, end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	type entry struct {
+		value  []byte
+		stored types.StoredLocation
+	}
+
+	entries := []entry{}
+	for _, message := range messages {
+		// the path only contains the locations of the booking
+		if types.MessageType(message.Value) != "" {
+			continue
+		}
+
+		stored, err := types.ReadStoredLocation(message.Value)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"booking_id : %s\toffset : %d\tfailed to read the location from the location bus",
+					f.bookingID,
+					message.Offset,
+				)
+			continue
+		}
+
+		// the viewer is only sent the live locations that are recorded after the path
+		if !stored.Before(f.latest) {
+			f.latest = stored
+			f.Location = message.Value
+		}
+		if stored.Time() < request.Since {
+			continue
+		}
+
+		entries = append(entries, entry{
+			value:  message.Value,
+			stored: stored,
+		})
+	}
+
+	// the buffered locations are received late so the path is ordered by the device time instead
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].stored.Before(entries[j].stored)
+	})
+
+	points := make([]trail.Point, 0, len(entries))
+	for _, entry := range entries {
+		points = append(points, trail.Point{
+			Lat: entry.stored.Lat,
+			Lon: entry.stored.Lon,
+		})
+	}
+
+	indexes := trail.Simplify(points, TrailConfig(settings))
+	locations := make([][]byte, 0, len(indexes))
+	for _, i := range indexes {
+		locations = append(locations, entries[i].value)
+	}
+
+	return locations, end, nil
+}
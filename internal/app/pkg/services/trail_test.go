@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+func TestParseTrailRequest(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *TrailRequest
+		err   bool
+	}{
+		{query: "", want: nil},
+		{query: "history=full", want: &TrailRequest{}},
+		{query: "since=1700000000000", want: &TrailRequest{Since: 1700000000000}},
+		{query: "since=1000&history=full", want: &TrailRequest{Since: 1000}},
+		{query: "history=some", err: true},
+		{query: "since=-1", err: true},
+		{query: "since=yesterday", err: true},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ParseTrailRequest(query)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: got %+v, want an error", test.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: got the error %v", test.query, err)
+			continue
+		}
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	c, settings := testIngest(t, slot)
+	settings.TrailTolerance = 10
+	settings.TrailMaxPoints = 500
+
+	location := func(lat, lon float64, recordedAt int64, index int) string {
+		return fmt.Sprintf(`{"lat":%v,"lon":%v,"recorded_at":%d,"location_index":%d}`, lat, lon, recordedAt, index)
+	}
+	var (
+		previous = location(48.85, 2.35, 500, 1)
+		first    = location(51.5, -0.12, 1000, 1)
+		middle   = location(51.5, -0.115, 1500, 2)
+		second   = location(51.5, -0.11, 2000, 3)
+		third    = location(51.51, -0.11, 3000, 4)
+	)
+
+	// the second location is received late, and the middle location is on the straight line between the first
+	// and the second locations
+	for _, value := range []string{previous, second, first, `{"type":"signal_lost"}`, middle, third} {
+		if err := c.Bus.Publish(ctx, slot, []byte("B1"), []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := &Stream{Slot: slot, Offset: 1}
+
+	check := func(request *TrailRequest, want ...string) {
+		t.Helper()
+
+		feed := NewFeed("B1", "")
+		locations, end, err := feed.Replay(ctx, c, settings, stream, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end != 6 {
+			t.Errorf("got the live messages to start at %d, want 6", end)
+		}
+		if len(locations) != len(want) {
+			t.Fatalf("got %d locations, want %d", len(locations), len(want))
+		}
+		for i := range want {
+			if string(locations[i]) != want[i] {
+				t.Errorf("location %d: got %s, want %s", i, locations[i], want[i])
+			}
+		}
+		// the live locations are only sent when they are newer than the last location of the path
+		if string(feed.Location) != third {
+			t.Errorf("got the last location %s, want %s", feed.Location, third)
+		}
+	}
+
+	// the path is ordered by the device time without the locations of the previous booking, the messages that are
+	// not locations and the location that the simplification leaves out
+	check(&TrailRequest{}, first, second, third)
+	check(&TrailRequest{Since: 2000}, second, third)
+
+	// a booking without any location yet starts the live messages at the next message
+	feed := NewFeed("B1", "")
+	locations, end, err := feed.Replay(ctx, c, settings, &Stream{Slot: slot, Offset: 6}, &TrailRequest{})
+	if err != nil || len(locations) != 0 || end != 6 {
+		t.Errorf("got %d locations, %d, %v, want no locations and 6", len(locations), end, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -23,11 +24,16 @@ type BookingToken struct {
 
 // BookingTokenOpts contains the details that are required to create a booking token
 type BookingTokenOpts struct {
-	BookingID        string
-	DriverID         int
-	Partition        int
-	NewOffset        int
-	PickupCordinates services.Geo
+	BookingID string
+	// Pickups contains the geocoded pickups, the first pickup is the initial location of the stream
+	Pickups []services.Geo
+	// Dropoffs contains the geocoded dropoffs
+	Dropoffs []services.Geo
+	// Route is the route that the driver is expected to take, the route deviation is not checked when it is empty
+	Route     []services.Geo
+	Slot      partitions.Slot
+	DriverID  int
+	NewOffset int
 }
 
 // NewBookingToken is a function that is used to create a new booking token instance
@@ -40,8 +46,8 @@ func NewBookingToken(e *env.Env, c *connections.C) *BookingToken {
 
 // Createtoken is a function that is used to only create the booking token without manipulating the redis state
 func (bt *BookingToken) Createtoken(
-	driverID,
-	partitionNo int,
+	driverID int,
+	slot partitions.Slot,
 	bookingID string,
 	duration time.Duration,
 ) (id uuid.UUID, token string, err error) {
@@ -60,7 +66,8 @@ func (bt *BookingToken) Createtoken(
 	claims["nbf"] = now.Unix()
 	claims["driver_id"] = driverID
 	claims["booking_id"] = bookingID
-	claims["partition_no"] = partitionNo
+	claims["partition_no"] = slot.Partition
+	claims["topic"] = slot.Topic
 
 	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(bt.E.BookingTokenSecret))
 	if err != nil {
@@ -85,36 +92,57 @@ func (bt *BookingToken) Create(
 		return "", err
 	}
 
-	id, token, err := bt.Createtoken(opts.DriverID, opts.Partition, opts.BookingID, duration)
+	id, token, err := bt.Createtoken(opts.DriverID, opts.Slot, opts.BookingID, duration)
 	if err != nil {
 		return "", err
 	}
 
 	pickup := types.LocationUpdate{
-		Lat: opts.PickupCordinates.Lat,
-		Lon: opts.PickupCordinates.Lon,
+		Lat: opts.Pickups[0].Lat,
+		Lon: opts.Pickups[0].Lon,
 	}
-	pickupStr, err := sonic.MarshalString(pickup.GetBlob())
+	pickupStr, err := sonic.MarshalString(pickup.GetBlob(time.Now()))
 	if err != nil {
 		return "", err
 	}
-	driverDetails, err := sonic.MarshalString(_lib.SetDriverID(id.String(), opts.BookingID, opts.Partition))
+	geofenceState, err := sonic.MarshalString(services.NewGeofence(opts.Pickups, opts.Dropoffs))
 	if err != nil {
 		return "", err
 	}
-	bookingDetails, err := sonic.MarshalString(_lib.SetBookingID(opts.Partition, opts.NewOffset, opts.DriverID))
+	deviationState, err := sonic.MarshalString(services.NewDeviation(opts.Route))
+	if err != nil {
+		return "", err
+	}
+	// the job starts with the default status, so that the geofences advance it even when the driver only sends
+	// the coordinates
+	jobState, err := sonic.MarshalString(types.NewJobState())
+	if err != nil {
+		return "", err
+	}
+	driverDetails, err := sonic.MarshalString(_lib.SetDriverID(id.String(), opts.BookingID, opts.Slot))
+	if err != nil {
+		return "", err
+	}
+	bookingDetails, err := sonic.MarshalString(_lib.SetBookingID(opts.Slot, opts.NewOffset, opts.DriverID))
 	if err != nil {
 		return "", err
 	}
 
-	pipe := bt.C.R.DB.Pipeline()
-	pipe.SetNX(ctx, fmt.Sprint(opts.DriverID), driverDetails, duration)
-	pipe.SetNX(ctx, opts.BookingID, bookingDetails, duration)
-	pipe.SetNX(ctx, _lib.L(opts.Partition), pickupStr, duration)
-	pipe.SetNX(ctx, _lib.C(opts.Partition), 0, duration)
-	pipe.SetNX(ctx, _lib.N(opts.Partition), nPayload, duration+12*time.Hour)
-
-	_, err = pipe.Exec(ctx)
+	err = bt.C.State.CreateBooking(ctx, connections.BookingState{
+		BookingID:  opts.BookingID,
+		DriverID:   opts.DriverID,
+		Slot:       opts.Slot,
+		Booking:    bookingDetails,
+		Driver:     driverDetails,
+		Location:   pickupStr,
+		Geofence:   geofenceState,
+		Deviation:  deviationState,
+		Job:        jobState,
+		LastUpdate: time.Now().UnixMilli(),
+		Backup:     nPayload,
+		TTL:        duration,
+		BackupTTL:  duration + 12*time.Hour,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -160,9 +188,7 @@ func (bt *BookingToken) Validate(
 	}
 
 	if validateRemote {
-		client := bt.C.R.DB
-
-		val := client.Get(ctx, fmt.Sprint(int(driverID))).Val()
+		val, _ := bt.C.State.GetDriver(ctx, int(driverID))
 		if val == "" {
 			return false, nil
 		}
@@ -182,16 +208,23 @@ func (bt *BookingToken) Validate(
 // Get is a function that is used to get the details from the booking token
 func (bt *BookingToken) Get(
 	token *jwt.Token,
-) (id string, driverID int, bookingID string, partitionNo int, err error) {
+) (id string, driverID int, bookingID string, slot partitions.Slot, err error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", 0, "", 0, fmt.Errorf("failed to map the token to claims")
+		return "", 0, "", partitions.Slot{}, fmt.Errorf("failed to map the token to claims")
+	}
+	topic, ok := claims["topic"].(string)
+	if !ok {
+		return "", 0, "", partitions.Slot{}, fmt.Errorf("the token does not contain the topic")
 	}
 
 	id = claims["sub"].(string)
 	driverID = int(claims["driver_id"].(float64))
 	bookingID = claims["booking_id"].(string)
-	partitionNo = int(claims["partition_no"].(float64))
+	slot = partitions.Slot{
+		Topic:     topic,
+		Partition: int(claims["partition_no"].(float64)),
+	}
 
-	return id, driverID, bookingID, partitionNo, nil
+	return id, driverID, bookingID, slot, nil
 }
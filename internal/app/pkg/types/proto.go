@@ -0,0 +1,296 @@
+package types
+
+import (
+	"time"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/deviation"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geofence"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/streampb"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// Schema is the version of the schema of a message in the location bus
+type Schema byte
+
+const (
+	// SchemaJSON is the schema of the JSON messages, they are the first messages of the location bus so they do not
+	// carry the version byte and start with the opening brace of the JSON object instead, the locations carry the
+	// version in their schema field
+	SchemaJSON Schema = 1
+	// SchemaProto is the schema of the protocol buffers locations, they start with the version byte
+	SchemaProto Schema = 2
+)
+
+// MessageSchema is a function that is used to get the schema of a message in the location bus
+func MessageSchema(value []byte) Schema {
+	if len(value) > 0 && value[0] == byte(SchemaProto) {
+		return SchemaProto
+	}
+
+	return SchemaJSON
+}
+
+// Encode is used to encode the location for the location bus with the given encoding, the given JSON encoding of
+// the location is used as it is for the JSON encoding so that the location is not encoded twice
+func (location Location) Encode(encoding enums.Encoding, encoded []byte) []byte {
+	if encoding != enums.Protobuf {
+		return encoded
+	}
+
+	b, err := proto.MarshalOptions{}.MarshalAppend([]byte{byte(SchemaProto)}, location.Proto())
+	if err != nil {
+		// the readers of the location bus read either of the schemas, so the location is not lost
+		log.Error().Err(err).Msg("failed to encode the location in protocol buffers, falling back to JSON")
+		return encoded
+	}
+
+	return b
+}
+
+// DecodeLocation is a function that is used to decode a location of the location bus in either of the schemas
+func DecodeLocation(value []byte) (Location, error) {
+	if MessageSchema(value) == SchemaProto {
+		var m streampb.Location
+		if err := proto.Unmarshal(value[1:], &m); err != nil {
+			return Location{}, err
+		}
+		return LocationFromProto(&m), nil
+	}
+
+	var location Location
+	err := sonic.Unmarshal(value, &location)
+	return location, err
+}
+
+// LocationJSON is a function that is used to get the JSON encoding of a location of the location bus, the JSON
+// locations are returned as they are
+func LocationJSON(value []byte) ([]byte, error) {
+	if MessageSchema(value) == SchemaJSON {
+		return value, nil
+	}
+
+	location, err := DecodeLocation(value)
+	if err != nil {
+		return nil, err
+	}
+	location.Schema = SchemaJSON
+	return sonic.Marshal(location)
+}
+
+// LocationProto is a function that is used to get a location of the location bus as a protocol buffers message
+func LocationProto(value []byte) (*streampb.Location, error) {
+	if MessageSchema(value) == SchemaProto {
+		var m streampb.Location
+		if err := proto.Unmarshal(value[1:], &m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+
+	location, err := DecodeLocation(value)
+	if err != nil {
+		return nil, err
+	}
+	return location.Proto(), nil
+}
+
+// ReadStoredLocation is a function that is used to read the fields of a location of the location bus that are read
+// by the server in either of the schemas
+func ReadStoredLocation(value []byte) (StoredLocation, error) {
+	if MessageSchema(value) == SchemaProto {
+		location, err := DecodeLocation(value)
+		if err != nil {
+			return StoredLocation{}, err
+		}
+		return location.Stored(), nil
+	}
+
+	var stored StoredLocation
+	err := sonic.Unmarshal(value, &stored)
+	return stored, err
+}
+
+// MessageProto is a function that is used to encode a message to the viewers in protocol buffers, the data is the
+// JSON encoding of the messages that are not locations
+func MessageProto(version int, kind string, location *streampb.Location, data []byte) ([]byte, error) {
+	return proto.Marshal(&streampb.Message{
+		V:        uint32(version),
+		Type:     kind,
+		Location: location,
+		Data:     data,
+	})
+}
+
+// TrailProto is a function that is used to encode the path of a stream so far to the viewers in protocol buffers,
+// the locations are the locations of the path in either of the schemas of the location bus
+func TrailProto(version int, kind string, locations [][]byte) ([]byte, error) {
+	trail := make([]*streampb.Location, 0, len(locations))
+	for _, value := range locations {
+		location, err := LocationProto(value)
+		if err != nil {
+			return nil, err
+		}
+		trail = append(trail, location)
+	}
+
+	return proto.Marshal(&streampb.Message{
+		V:     uint32(version),
+		Type:  kind,
+		Trail: trail,
+	})
+}
+
+// Proto is used to get the location as a protocol buffers message
+func (location Location) Proto() *streampb.Location {
+	m := &streampb.Location{
+		Lat:           location.Lat,
+		Lon:           location.Lon,
+		Heading:       location.Heading,
+		Accuracy:      location.Accuracy,
+		LocationIndex: int32(location.LocationIndex),
+		Status:        int32(location.Status),
+		Timestamp:     location.Timestamp,
+		RecordedAt:    location.RecordedAt,
+		ReceivedAt:    location.ReceivedAt,
+		Speed:         location.Speed,
+		Bearing:       location.Bearing,
+		Distance:      location.Distance,
+	}
+
+	if event := location.Geofence; event != nil {
+		m.Geofence = &streampb.GeofenceEvent{
+			Type:  string(event.Type),
+			Kind:  string(event.Kind),
+			Index: int32(event.Index),
+			At:    event.At,
+		}
+	}
+	if event := location.Deviation; event != nil {
+		m.Deviation = &streampb.DeviationEvent{
+			Type:     string(event.Type),
+			Distance: event.Distance,
+			At:       event.At,
+			Lat:      event.Lat,
+			Lon:      event.Lon,
+		}
+	}
+	if transition := location.Transition; transition != nil {
+		m.Transition = &streampb.Transition{
+			Status: int32(transition.Status),
+			Source: string(transition.Source),
+			At:     transition.At,
+			Lat:    transition.Lat,
+			Lon:    transition.Lon,
+		}
+		if transition.From != nil {
+			from := int32(*transition.From)
+			m.Transition.From = &from
+		}
+	}
+
+	return m
+}
+
+// LocationFromProto is a function that is used to get the location from its protocol buffers message
+func LocationFromProto(m *streampb.Location) Location {
+	location := Location{
+		Lat:           m.GetLat(),
+		Lon:           m.GetLon(),
+		Heading:       m.GetHeading(),
+		Accuracy:      m.GetAccuracy(),
+		LocationIndex: int(m.GetLocationIndex()),
+		Status:        int(m.GetStatus()),
+		Timestamp:     m.GetTimestamp(),
+		RecordedAt:    m.GetRecordedAt(),
+		ReceivedAt:    m.GetReceivedAt(),
+		Speed:         m.Speed,
+		Bearing:       m.Bearing,
+		Distance:      m.Distance,
+	}
+
+	if event := m.GetGeofence(); event != nil {
+		location.Geofence = &geofence.Event{
+			Type:  geofence.EventType(event.GetType()),
+			Kind:  geofence.Kind(event.GetKind()),
+			Index: int(event.GetIndex()),
+			At:    event.GetAt(),
+		}
+	}
+	if event := m.GetDeviation(); event != nil {
+		location.Deviation = &deviation.Event{
+			Type:     deviation.EventType(event.GetType()),
+			Distance: event.GetDistance(),
+			At:       event.GetAt(),
+			Lat:      event.GetLat(),
+			Lon:      event.GetLon(),
+		}
+	}
+	if transition := m.GetTransition(); transition != nil {
+		location.Transition = &Transition{
+			Status: _lib.JobStatus(transition.GetStatus()),
+			Source: TransitionSource(transition.GetSource()),
+			At:     transition.GetAt(),
+			Lat:    transition.GetLat(),
+			Lon:    transition.GetLon(),
+		}
+		if transition.From != nil {
+			from := _lib.JobStatus(transition.GetFrom())
+			location.Transition.From = &from
+		}
+	}
+
+	return location
+}
+
+// UnmarshalProto is used to decode the location update that is sent by the driver from its protocol buffers
+// encoding, the recorded time is in unix milliseconds
+func (location *LocationUpdate) UnmarshalProto(b []byte) error {
+	var m streampb.LocationUpdate
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	*location = LocationUpdateFromProto(&m)
+	return nil
+}
+
+// LocationUpdateFromProto is a function that is used to get the location update from its protocol buffers message
+func LocationUpdateFromProto(m *streampb.LocationUpdate) LocationUpdate {
+	location := LocationUpdate{
+		Lat:      m.GetLat(),
+		Lon:      m.GetLon(),
+		Accuracy: m.Accuracy,
+		Heading:  m.Heading,
+		Status:   m.Status,
+	}
+	if m.LocationIndex != nil {
+		index := int(m.GetLocationIndex())
+		location.LocationIndex = &index
+	}
+	if m.RecordedAt != nil {
+		at := time.UnixMilli(m.GetRecordedAt())
+		location.RecordedAt = &at
+	}
+
+	return location
+}
+
+// UnmarshalLocationBatch is a function that is used to decode the locations that are buffered by the driver from
+// the protocol buffers encoding of the batch
+func UnmarshalLocationBatch(b []byte) ([]LocationUpdate, error) {
+	var m streampb.LocationBatch
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	var locations []LocationUpdate
+	for _, location := range m.GetLocations() {
+		locations = append(locations, LocationUpdateFromProto(location))
+	}
+
+	return locations, nil
+}
@@ -0,0 +1,353 @@
+package types
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/deviation"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geofence"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/streampb"
+	"google.golang.org/protobuf/proto"
+)
+
+// legacyLocation is the protocol buffers encoding of testLocation by the encoder that was written by hand before
+// the code is generated, the locations that are already in the location bus must still be read
+const legacyLocation = "090000000000c0494011b81e85eb51b8bebf190000000000805640210000000000001440282a30033880e2cfaa064080d095ffbc3148f4d395ffbc315100000000000029405900000000008056406100000000007097406a1a0a076172726976616c12067069636b757018012080d095ffbc31722c0a086465766961746564110000000000c062401880d095ffbc31210000000000c0494029b81e85eb51b8bebf7a27080210031a0867656f66656e63652080d095ffbc31290000000000c0494031b81e85eb51b8bebf"
+
+// testLocation is used to get a location with every field set
+func testLocation() Location {
+	speed, bearing, distance := 12.5, 90.0, 1500.0
+	from := _lib.OnTheWay
+
+	return Location{
+		Lat:           51.5,
+		Lon:           -0.12,
+		Heading:       90,
+		Accuracy:      5,
+		LocationIndex: 42,
+		Status:        3,
+		Timestamp:     1700000000,
+		RecordedAt:    1700000000000,
+		ReceivedAt:    1700000000500,
+		Speed:         &speed,
+		Bearing:       &bearing,
+		Distance:      &distance,
+		Geofence: &geofence.Event{
+			Type:  geofence.Arrival,
+			Kind:  geofence.Pickup,
+			Index: 1,
+			At:    1700000000000,
+		},
+		Deviation: &deviation.Event{
+			Type:     deviation.Deviated,
+			Distance: 150,
+			At:       1700000000000,
+			Lat:      51.5,
+			Lon:      -0.12,
+		},
+		Transition: &Transition{
+			From:   &from,
+			Status: _lib.PickupPoint,
+			Source: Geofence,
+			At:     1700000000000,
+			Lat:    51.5,
+			Lon:    -0.12,
+		},
+	}
+}
+
+func TestLocationRoundTrip(t *testing.T) {
+	cases := map[string]Location{
+		"every field": testLocation(),
+		// the zero values are left out of the encoding, the optional motion is kept apart from nil
+		"zero values": {Speed: new(float64), Bearing: new(float64), Distance: new(float64)},
+		"first status": {
+			Lat:        1,
+			Lon:        1,
+			Transition: &Transition{Status: _lib.Accepted, Source: Driver},
+		},
+	}
+	for name, location := range cases {
+		t.Run(name, func(t *testing.T) {
+			value := location.Encode(enums.Protobuf, nil)
+			if MessageSchema(value) != SchemaProto {
+				t.Fatalf("got the schema %d, want %d", MessageSchema(value), SchemaProto)
+			}
+
+			got, err := DecodeLocation(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, location) {
+				t.Errorf("got %+v, want %+v", got, location)
+			}
+		})
+	}
+}
+
+func TestLocationLegacyProto(t *testing.T) {
+	b, err := hex.DecodeString(legacyLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeLocation(append([]byte{byte(SchemaProto)}, b...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testLocation(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLocationJSON(t *testing.T) {
+	location := testLocation()
+	location.Schema = SchemaJSON
+	encoded, err := sonic.Marshal(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the JSON locations are kept as they are
+	value := location.Encode(enums.JSON, encoded)
+	if MessageSchema(value) != SchemaJSON {
+		t.Fatalf("got the schema %d, want %d", MessageSchema(value), SchemaJSON)
+	}
+	if got, err := LocationJSON(value); err != nil || string(got) != string(encoded) {
+		t.Errorf("got %s, %v, want the location as it is", got, err)
+	}
+
+	// the protocol buffers locations are sent in JSON with the schema of the JSON locations
+	got, err := LocationJSON(location.Encode(enums.Protobuf, encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Location
+	if err := sonic.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, location) {
+		t.Errorf("got %+v, want %+v", decoded, location)
+	}
+
+	// every new location carries the schema version
+	var blob struct {
+		Schema *Schema `json:"schema"`
+	}
+	update := LocationUpdate{Lat: 51.5, Lon: -0.12}
+	payload, _ := sonic.Marshal(update.GetBlob(time.Now()))
+	if err := sonic.Unmarshal(payload, &blob); err != nil || blob.Schema == nil || *blob.Schema != SchemaJSON {
+		t.Errorf("got %s, want the schema version %d", payload, SchemaJSON)
+	}
+}
+
+func TestLocationUpdateRoundTrip(t *testing.T) {
+	accuracy, heading, status := 5.0, 90.0, int64(2)
+	index := int32(7)
+	recordedAt := int64(1700000000123)
+
+	b, err := proto.Marshal(&streampb.LocationUpdate{
+		Lat:           51.5,
+		Lon:           -0.12,
+		Accuracy:      &accuracy,
+		Heading:       &heading,
+		Status:        &status,
+		LocationIndex: &index,
+		RecordedAt:    &recordedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var location LocationUpdate
+	if err := location.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if location.Lat != 51.5 || location.Lon != -0.12 || *location.Accuracy != accuracy || *location.Heading != heading ||
+		*location.Status != status || *location.LocationIndex != 7 || location.RecordedAt.UnixMilli() != recordedAt {
+		t.Errorf("got %+v, want every field to be decoded", location)
+	}
+
+	// the fields that are not sent stay nil
+	b, _ = proto.Marshal(&streampb.LocationUpdate{Lat: 1, Lon: 1})
+	location = LocationUpdate{}
+	if err := location.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if location.Accuracy != nil || location.Heading != nil || location.Status != nil || location.LocationIndex != nil || location.RecordedAt != nil {
+		t.Errorf("got %+v, want the fields that are not sent to be nil", location)
+	}
+}
+
+func TestLocationBatchRoundTrip(t *testing.T) {
+	batch := &streampb.LocationBatch{}
+	for i := int32(0); i < 3; i++ {
+		index := i
+		batch.Locations = append(batch.Locations, &streampb.LocationUpdate{
+			Lat:           51.5 + float64(i),
+			Lon:           -0.12,
+			LocationIndex: &index,
+		})
+	}
+	b, err := proto.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations, err := UnmarshalLocationBatch(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 3 {
+		t.Fatalf("got %d locations, want 3", len(locations))
+	}
+	for i, location := range locations {
+		if location.Lat != 51.5+float64(i) || location.LocationIndex == nil || *location.LocationIndex != i {
+			t.Errorf("location %d: got %+v, want the location in the order it is sent", i, location)
+		}
+	}
+}
+
+func TestMessageProto(t *testing.T) {
+	location := testLocation()
+	encoded, _ := sonic.Marshal(location)
+	values := [][]byte{encoded, location.Encode(enums.Protobuf, encoded)}
+
+	// the location messages carry the location in protocol buffers
+	value, err := LocationProto(values[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MessageProto(1, "location", value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message streampb.Message
+	if err := proto.Unmarshal(b, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.GetV() != 1 || message.GetType() != "location" || !reflect.DeepEqual(LocationFromProto(message.GetLocation()), location) {
+		t.Errorf("got %v, want the location message", &message)
+	}
+
+	// the other messages carry their JSON encoding
+	b, err = MessageProto(1, "eta", nil, []byte(`{"minutes":5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message.Reset()
+	if err := proto.Unmarshal(b, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.GetType() != "eta" || string(message.GetData()) != `{"minutes":5}` || message.GetLocation() != nil {
+		t.Errorf("got %v, want the eta message", &message)
+	}
+
+	// the path carries the locations of either of the schemas in order
+	b, err = TrailProto(1, "trail", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message.Reset()
+	if err := proto.Unmarshal(b, &message); err != nil {
+		t.Fatal(err)
+	}
+	if len(message.GetTrail()) != len(values) {
+		t.Fatalf("got %d locations, want %d", len(message.GetTrail()), len(values))
+	}
+	for i, m := range message.GetTrail() {
+		if got := LocationFromProto(m); !reflect.DeepEqual(got, location) {
+			t.Errorf("location %d: got %+v, want %+v", i, got, location)
+		}
+	}
+}
+
+func FuzzDecodeLocation(f *testing.F) {
+	legacy, _ := hex.DecodeString(legacyLocation)
+	location := testLocation()
+	encoded, _ := sonic.Marshal(location)
+	f.Add(append([]byte{byte(SchemaProto)}, legacy...))
+	f.Add(location.Encode(enums.Protobuf, nil))
+	f.Add(encoded)
+	f.Add([]byte{byte(SchemaProto)})
+
+	f.Fuzz(func(t *testing.T, value []byte) {
+		location, err := DecodeLocation(value)
+		if err != nil || MessageSchema(value) != SchemaProto {
+			return
+		}
+
+		// a location that is read from the location bus is encoded the same way again
+		again, err := DecodeLocation(location.Encode(enums.Protobuf, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, location) {
+			t.Errorf("got %+v after encoding it again, want %+v", again, location)
+		}
+	})
+}
+
+func FuzzUnmarshalLocationBatch(f *testing.F) {
+	index := int32(3)
+	batch, _ := proto.Marshal(&streampb.LocationBatch{
+		Locations: []*streampb.LocationUpdate{{Lat: 51.5, Lon: -0.12, LocationIndex: &index}},
+	})
+	f.Add(batch)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		locations, err := UnmarshalLocationBatch(b)
+		if err != nil {
+			return
+		}
+
+		// every location of a batch is decoded the same way as a single location
+		var m streampb.LocationBatch
+		if err := proto.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if len(locations) != len(m.GetLocations()) {
+			t.Fatalf("got %d locations, want %d", len(locations), len(m.GetLocations()))
+		}
+		for i, location := range m.GetLocations() {
+			single, err := proto.Marshal(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want LocationUpdate
+			if err := want.UnmarshalProto(single); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(locations[i], want) {
+				t.Errorf("location %d: got %+v, want %+v", i, locations[i], want)
+			}
+		}
+	})
+}
+
+func FuzzMessageProto(f *testing.F) {
+	f.Add("location", []byte(nil))
+	f.Add("eta", []byte(`{"minutes":5}`))
+
+	f.Fuzz(func(t *testing.T, kind string, data []byte) {
+		b, err := MessageProto(1, kind, testLocation().Proto(), data)
+		if err != nil {
+			// only the types that are not valid UTF-8 can not be encoded
+			return
+		}
+
+		var message streampb.Message
+		if err := proto.Unmarshal(b, &message); err != nil {
+			t.Fatal(err)
+		}
+		if message.GetType() != kind || string(message.GetData()) != string(data) {
+			t.Errorf("got %v, want the type %q and the data %q", &message, kind, data)
+		}
+	})
+}
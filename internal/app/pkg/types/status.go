@@ -0,0 +1,86 @@
+package types
+
+import (
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+)
+
+// TransitionSource is used to classify what moved the job to a status
+type TransitionSource string
+
+const (
+	// Driver is when the driver sends the status
+	Driver TransitionSource = "driver"
+	// Geofence is when the status is advanced by a geofence event
+	Geofence TransitionSource = "geofence"
+)
+
+// Transition is a change of the job status of a booking
+type Transition struct {
+	// From is nil for the first status of the job
+	From   *_lib.JobStatus  `json:"from"`
+	Status _lib.JobStatus   `json:"status"`
+	Source TransitionSource `json:"source"`
+	// At is the time of the transition in unix milliseconds
+	At  int64   `json:"at"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// JobState contains the job status of a booking along with every transition of it, it is serialized and kept
+// in the state store while the booking is active
+type JobState struct {
+	// Status is nil until the first status of the job is known
+	Status *_lib.JobStatus `json:"status"`
+	// Assumed is set while the status is the default status that the job is created with, the driver can send any
+	// status until then
+	Assumed  bool         `json:"assumed,omitempty"`
+	Timeline []Transition `json:"timeline"`
+}
+
+// NewJobState is a function that is used to create the job state of a new booking, the job starts with the default
+// status so that the geofences can advance it for the drivers that never send a status
+func NewJobState() JobState {
+	status := _lib.DefaultStatus
+	return JobState{
+		Status:  &status,
+		Assumed: true,
+	}
+}
+
+// Check is used to make sure that the job can move to the given status
+func (state *JobState) Check(to _lib.JobStatus) error {
+	if state.Status == nil || state.Assumed || *state.Status == to || state.Status.CanTransition(to) {
+		return nil
+	}
+
+	return errors.ErrInvalidTransition.WithDetails(map[string]any{
+		"from": *state.Status,
+		"to":   to,
+	})
+}
+
+// Move is used to move the job to the given status at the given location, the transition is added to the
+// timeline and nil is returned when the job already has the given status
+//
+// NOTE: the caller must Check the transition before moving the job
+func (state *JobState) Move(to _lib.JobStatus, source TransitionSource, location *LocationUpdate, at int64) *Transition {
+	if state.Status != nil && *state.Status == to {
+		state.Assumed = false
+		return nil
+	}
+
+	transition := Transition{
+		From:   state.Status,
+		Status: to,
+		Source: source,
+		At:     at,
+		Lat:    location.Lat,
+		Lon:    location.Lon,
+	}
+	state.Status = &to
+	state.Assumed = false
+	state.Timeline = append(state.Timeline, transition)
+
+	return &transition
+}
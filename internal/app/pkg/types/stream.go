@@ -3,53 +3,201 @@ package types
 import (
 	"time"
 
+	"github.com/bytedance/sonic"
 	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/deviation"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geofence"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/track"
 )
 
 // LocationUpdate represents a single location update in a stream.
 // It contains geographical coordinates, accuracy, heading, and status information.
 // The Lat and Lon fields are required and validated as latitude and longitude respectively.
 // Status, if provided, must be one of the values 0, 1, 2, 3, 4, or 5.
+// RecordedAt, if provided, is the RFC 3339 time of the device when the location is recorded.
+// Motion, Event, Deviation and Transition are derived by the server and are never read from the client.
 type LocationUpdate struct {
-	Accuracy      *float64 `json:"accuracy"`
-	Status        *int64   `json:"status" validate:"omitempty,oneof=0 1 2 3 4 5"`
-	Heading       *float64 `json:"heading"`
-	LocationIndex *int     `json:"location_index"`
-	Lat           float64  `json:"lat" validate:"required,latitude"`
-	Lon           float64  `json:"lon" validate:"required,longitude"`
+	Motion        *track.Motion    `json:"-"`
+	Event         *geofence.Event  `json:"-"`
+	Deviation     *deviation.Event `json:"-"`
+	Transition    *Transition      `json:"-"`
+	RecordedAt    *time.Time       `json:"recorded_at"`
+	Accuracy      *float64         `json:"accuracy"`
+	Status        *int64           `json:"status" validate:"omitempty,oneof=0 1 2 3 4 5"`
+	Heading       *float64         `json:"heading"`
+	LocationIndex *int             `json:"location_index"`
+	Lat           float64          `json:"lat" validate:"required,latitude"`
+	Lon           float64          `json:"lon" validate:"required,longitude"`
+}
+
+// CheckRecordedAt is used to make sure that the device time of the location is within the clock skew window
+// around the given time, a location can be at most maxAge old and at most skew ahead of the given time
+func (location *LocationUpdate) CheckRecordedAt(now time.Time, skew, maxAge time.Duration) bool {
+	if location.RecordedAt == nil {
+		return true
+	}
+
+	return !location.RecordedAt.After(now.Add(skew)) && !location.RecordedAt.Before(now.Add(-maxAge))
+}
+
+// MessageType is a function that is used to get the type of a message in the location bus, the type is empty for
+// the locations, it is read on its own since the fields of the other messages may not match the fields of a location
+func MessageType(value []byte) string {
+	// only the locations are published in protocol buffers
+	if MessageSchema(value) == SchemaProto {
+		return ""
+	}
+
+	var message struct {
+		Type string `json:"type"`
+	}
+	_ = sonic.Unmarshal(value, &message)
+
+	return message.Type
+}
+
+// Time is used to get the device time of the location, the given received time is used when the device does
+// not provide the time
+func (location *LocationUpdate) Time(receivedAt time.Time) time.Time {
+	if location.RecordedAt != nil {
+		return *location.RecordedAt
+	}
+
+	return receivedAt
+}
+
+// Ends is used to check wether the job is cleared at the location, which ends the stream of the booking
+func (location *LocationUpdate) Ends() bool {
+	return location.Transition != nil && location.Transition.Status == _lib.Clear
+}
+
+// Location is a single location of a stream as it is published to the location bus
+//
+// The recorded_at and received_at fields are unix milliseconds, the location is recorded at the given
+// received time when the device does not provide the time, timestamp is the recorded time in unix seconds
+//
+// The speed (meters per second), bearing (degrees) and distance (meters) fields are only added when the
+// motion of the location is derived, the bearing is used as the heading when the device does not provide it,
+// the geofence field is only added when the driver arrives at or departs from a stop at the location, the
+// deviation field is only added when the driver leaves or returns to the expected route at the location and the
+// transition field is only added when the job status changes at the location
+type Location struct {
+	Speed         *float64         `json:"speed,omitempty"`
+	Bearing       *float64         `json:"bearing,omitempty"`
+	Distance      *float64         `json:"distance,omitempty"`
+	Geofence      *geofence.Event  `json:"geofence,omitempty"`
+	Deviation     *deviation.Event `json:"deviation,omitempty"`
+	Transition    *Transition      `json:"transition,omitempty"`
+	Lat           float64          `json:"lat"`
+	Lon           float64          `json:"lon"`
+	Heading       float64          `json:"heading"`
+	Accuracy      float64          `json:"accuracy"`
+	LocationIndex int              `json:"location_index"`
+	Status        int              `json:"status"`
+	Timestamp     int64            `json:"timestamp"`
+	RecordedAt    int64            `json:"recorded_at"`
+	ReceivedAt    int64            `json:"received_at"`
+	// Schema is the version of the schema of the JSON location, it is missing from the locations that are published
+	// before it is added which are read as SchemaJSON
+	Schema Schema `json:"schema,omitempty"`
+}
+
+// Stored is used to get the fields of the location that are read by the server
+func (location Location) Stored() StoredLocation {
+	return StoredLocation{
+		RecordedAt:    &location.RecordedAt,
+		Status:        &location.Status,
+		Timestamp:     location.Timestamp,
+		Lat:           location.Lat,
+		Lon:           location.Lon,
+		LocationIndex: location.LocationIndex,
+		Transition:    location.Transition,
+	}
 }
 
 // GetBlob represents a function that is used replace the the LocationUpdate with
-// a map by adding default values to the fields that are not presented
-func (location *LocationUpdate) GetBlob() map[string]any {
-	return map[string]any{
-		"lat": location.Lat,
-		"lon": location.Lon,
-		"heading": func() float64 {
-			if location.Heading == nil {
-				return 0
-			}
-			return *location.Heading
-		}(),
-		"accuracy": func() float64 {
-			if location.Accuracy == nil {
-				return -1
-			}
-			return *location.Accuracy
-		}(),
-		"location_index": func() int {
-			if location.LocationIndex == nil {
-				return 0
-			}
-			return *location.LocationIndex
-		}(),
-		"status": func() int {
-			if location.Status == nil {
-				return int(_lib.DefaultStatus)
-			}
-
-			return int(*location.Status)
-		}(),
-		"timestamp": time.Now().UTC().Unix(),
+// a Location by adding default values to the fields that are not presented
+func (location *LocationUpdate) GetBlob(receivedAt time.Time) Location {
+	recordedAt := location.Time(receivedAt)
+
+	blob := Location{
+		Lat:        location.Lat,
+		Lon:        location.Lon,
+		Accuracy:   -1,
+		Status:     int(_lib.DefaultStatus),
+		Geofence:   location.Event,
+		Deviation:  location.Deviation,
+		Transition: location.Transition,
+		Timestamp:  recordedAt.UTC().Unix(),
+		RecordedAt: recordedAt.UTC().UnixMilli(),
+		ReceivedAt: receivedAt.UTC().UnixMilli(),
+		Schema:     SchemaJSON,
 	}
+	if location.Heading != nil {
+		blob.Heading = *location.Heading
+	} else if location.Motion != nil {
+		blob.Heading = location.Motion.Bearing
+	}
+	if location.Accuracy != nil {
+		blob.Accuracy = *location.Accuracy
+	}
+	if location.LocationIndex != nil {
+		blob.LocationIndex = *location.LocationIndex
+	}
+	if location.Status != nil {
+		blob.Status = int(*location.Status)
+	}
+
+	if location.Motion != nil {
+		blob.Speed = &location.Motion.Speed
+		blob.Bearing = &location.Motion.Bearing
+		blob.Distance = &location.Motion.Distance
+	}
+
+	return blob
+}
+
+// StoredLocation contains the fields of a location in the location bus that are read by the server
+//
+// Type is only set for the messages in the location bus that are not locations, such as the signal messages
+type StoredLocation struct {
+	Type       string `json:"type"`
+	RecordedAt *int64 `json:"recorded_at"`
+	Status     *int   `json:"status"`
+	Timestamp  int64  `json:"timestamp"`
+	// Transition is only set when the job status changes at the location
+	Transition    *Transition `json:"transition"`
+	Lat           float64     `json:"lat"`
+	Lon           float64     `json:"lon"`
+	LocationIndex int         `json:"location_index"`
+}
+
+// Time is used to get the device time of the location in unix milliseconds, the locations that are published
+// before the device time is recorded fall back to the time they are received
+func (location StoredLocation) Time() int64 {
+	if location.RecordedAt != nil {
+		return *location.RecordedAt
+	}
+
+	return location.Timestamp * 1000
+}
+
+// Before is used to check wether the location is recorded before the given location, the locations that are
+// recorded at the same time are ordered by their location index
+func (location StoredLocation) Before(other StoredLocation) bool {
+	if location.Time() != other.Time() {
+		return location.Time() < other.Time()
+	}
+
+	return location.LocationIndex < other.LocationIndex
+}
+
+// JobStatus is used to get the job status of the location, the default status is used when the location does not
+// contain the status
+func (location StoredLocation) JobStatus() _lib.JobStatus {
+	if location.Status == nil {
+		return _lib.DefaultStatus
+	}
+
+	return _lib.JobStatus(*location.Status)
 }
@@ -10,21 +10,25 @@ import (
 
 	"github.com/bytedance/sonic"
 	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/go-chi/chi/v5"
 	"github.com/lesismal/nbio/nbhttp/websocket"
-	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
-	"github.com/segmentio/kafka-go"
 )
 
-func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
+func add(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	// the settings are kept for the lifetime of the connection even if they are reloaded
+	settings := e.Settings()
+
 	bookingTokenID := chi.URLParam(r, "booking_token_id")
 	if bookingTokenID == "" {
-		http.Error(w, errors.ErrBadRequest.Error(), http.StatusBadRequest)
+		lib.ErrorResponse(w, r, errors.ErrBadRequest)
 		return
 	}
 	driverID, err := strconv.Atoi(chi.URLParam(r, "driver_id"))
@@ -33,7 +37,7 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 			"booking_token_id : %s\tfailed to convert the driver ID to integer",
 			bookingTokenID,
 		)
-		http.Error(w, errors.ErrBadRequest.Error(), http.StatusBadRequest)
+		lib.ErrorResponse(w, r, errors.ErrBadRequest)
 		return
 	}
 	partitionNo, err := strconv.Atoi(chi.URLParam(r, "partition"))
@@ -43,25 +47,28 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 			bookingTokenID,
 			driverID,
 		)
-		http.Error(w, errors.ErrBadRequest.Error(), http.StatusBadRequest)
+		lib.ErrorResponse(w, r, errors.ErrBadRequest)
 		return
 	}
+	slot := partitions.Slot{
+		Topic:     chi.URLParam(r, "topic"),
+		Partition: partitionNo,
+	}
 
 	basicDebugMsg := fmt.Sprintf(
-		"booking_token_id : %s\tdriver_id : %d\tpartition : %d",
+		"booking_token_id : %s\tdriver_id : %d\tslot : %s",
 		bookingTokenID,
 		driverID,
-		partitionNo,
+		slot,
 	)
 
-	client := c.R.DB
-	val := client.Get(r.Context(), fmt.Sprint(driverID)).Val()
+	val, _ := c.State.GetDriver(r.Context(), driverID)
 	if val == "" {
 		log.Error().Msgf(
 			"%s\tvalue obtained for the driver ID is empty",
 			basicDebugMsg,
 		)
-		http.Error(w, errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		lib.ErrorResponse(w, r, errors.ErrUnauthorized)
 		return
 	}
 	DriverID := _lib.NewDriverID()
@@ -71,7 +78,7 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 			"%s\tfailed to unmarshal the driver ID",
 			basicDebugMsg,
 		)
-		http.Error(w, errors.ErrBadRequest.Error(), http.StatusBadRequest)
+		lib.ErrorResponse(w, r, errors.ErrBadRequest)
 		return
 	}
 
@@ -81,42 +88,43 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 			basicDebugMsg,
 			DriverID[_lib.DriverIDDriverToken],
 		)
-		http.Error(w, errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		lib.ErrorResponse(w, r, errors.ErrUnauthorized)
 		return
 	}
 
-	if DriverID[_lib.DriverIDPartitionNo] != fmt.Sprint(partitionNo) {
-		log.Error().Msgf(
-			"%s\tpartition_number mismatch",
+	if driverSlot, err := _lib.GetDriverIDSlot(DriverID, e.Topic); err != nil || driverSlot != slot {
+		log.Error().Err(err).Msgf(
+			"%s\tslot mismatch",
 			basicDebugMsg,
 		)
-		http.Error(w, errors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		lib.ErrorResponse(w, r, errors.ErrUnauthorized)
 		return
 	}
 
+	bookingID := DriverID[_lib.DriverIDBookingID]
 	count := 1
 
-	writer := c.K.B
-	writer.Balancer = kafka.BalancerFunc(func(m kafka.Message, i ...int) int {
-		return partitionNo
-	})
-	writer.Async = true
-
 	upgrader := websocket.NewUpgrader()
+	// the drivers that ask for the protocol buffers subprotocol send every location in protocol buffers
+	upgrader.Subprotocols = []string{protoProtocol}
 	upgrader.CheckOrigin = func(r *http.Request) bool {
 		return true
 	}
 
-	upgrader.OnMessage(func(_ *websocket.Conn, _ websocket.MessageType, b []byte) {
+	upgrader.OnMessage(func(conn *websocket.Conn, _ websocket.MessageType, b []byte) {
 		var (
 			data struct {
 				Location types.LocationUpdate `json:"location" validate:"required"`
 			}
-			payload string
-			err     error
+			err error
 		)
 
-		if err = sonic.UnmarshalString(string(b), &data); err != nil {
+		if conn.Subprotocol() == protoProtocol {
+			if err = data.Location.UnmarshalProto(b); err != nil {
+				log.Error().Err(err).Msg("provide valid protobuf data")
+				return
+			}
+		} else if err = sonic.UnmarshalString(string(b), &data); err != nil {
 			log.Error().Err(err).Msg("provide valid JSON data")
 			return
 		}
@@ -129,28 +137,63 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 			return
 		}
 
-		payload, err = sonic.MarshalString(data.Location.GetBlob())
-		if err != nil {
-			log.Error().Err(err).
+		receivedAt := time.Now()
+		if _, ok := services.CheckRecordedAt(settings, []types.LocationUpdate{data.Location}, receivedAt); !ok {
+			log.Error().
 				Msgf(
-					"data : %v\tpartition : %d\tdriver_id : %d\tfailed to marshal the payload",
-					data,
-					partitionNo,
-					driverID,
+					"recorded_at : %v\treceived_at : %v\tthe recorded time of the location is outside of the clock skew window",
+					data.Location.RecordedAt,
+					receivedAt,
 				)
 			return
 		}
 
-		writer.WriteMessages(context.Background(), kafka.Message{
-			Key:   []byte(strconv.Itoa(int(driverID))),
-			Value: []byte(payload),
-		})
+		// the last known location is saved once every UpdateInterval locations
+		saveLast := count >= settings.UpdateInterval
+		ingested, err := services.IngestLocations(
+			context.Background(),
+			e,
+			c,
+			settings,
+			slot,
+			driverID,
+			[]types.LocationUpdate{data.Location},
+			receivedAt,
+			saveLast,
+		)
+		if err != nil || ingested.Published == 0 {
+			return
+		}
 
-		if count < updateinterval {
-			count++
-		} else {
-			client.Set(r.Context(), _lib.L(partitionNo), payload, redis.KeepTTL)
+		if ingested.Ends {
+			// the stream is ended right after the clear status is published, so the viewers receive it before they are disconnected
+			if _, err = services.EndBooking(context.Background(), e, c, bookingID); err != nil {
+				log.Error().Err(err).
+					Msgf(
+						"booking_id : %s\tslot : %s\tdriver_id : %d\tfailed to end the session after the job is cleared",
+						bookingID,
+						slot,
+						driverID,
+					)
+				return
+			}
+
+			log.Info().
+				Msgf(
+					"booking_id : %s\tslot : %s\tdriver_id : %d\tended the session after the job is cleared",
+					bookingID,
+					slot,
+					driverID,
+				)
+			conn.WriteClose(closeNormal, "ended the session")
+			conn.Close()
+			return
+		}
+
+		if saveLast {
 			count = 1
+		} else {
+			count++
 		}
 	})
 	upgrader.OnOpen(func(conn *websocket.Conn) {
@@ -163,7 +206,7 @@ func add(w http.ResponseWriter, r *http.Request, _ *env.Env, c *connections.C) {
 		closed := int32(0)
 
 		go func() {
-			ticker := time.NewTicker(heartbeat)
+			ticker := time.NewTicker(time.Duration(settings.Heartbeat) * time.Second)
 			defer ticker.Stop()
 
 			for {
@@ -9,6 +9,7 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 )
 
 func create(w http.ResponseWriter, r *http.Request, e *env.Env, _ *connections.C) {
@@ -18,13 +19,13 @@ func create(w http.ResponseWriter, r *http.Request, e *env.Env, _ *connections.C
 
 	bookingTokenID := r.Context().Value(middlewares.BookingTokenID).(string)
 	driverID := r.Context().Value(middlewares.DriverID).(int)
-	partition := r.Context().Value(middlewares.PartitionNo).(int)
+	slot := r.Context().Value(middlewares.Slot).(partitions.Slot)
 
 	response := res{}
 	if e.Env == string(enums.Dev) {
-		response.WebsocketURL = fmt.Sprintf("ws://%s/ws/stream/add/%s/%d/%d", e.WebsocketURL, bookingTokenID, driverID, partition)
+		response.WebsocketURL = fmt.Sprintf("ws://%s/ws/stream/add/%s/%d/%s/%d", e.WebsocketURL, bookingTokenID, driverID, slot.Topic, slot.Partition)
 	} else {
-		response.WebsocketURL = fmt.Sprintf("wss://%s/ws/stream/add/%s/%d/%d", e.WebsocketURL, bookingTokenID, driverID, partition)
+		response.WebsocketURL = fmt.Sprintf("wss://%s/ws/stream/add/%s/%d/%s/%d", e.WebsocketURL, bookingTokenID, driverID, slot.Topic, slot.Partition)
 	}
 
 	lib.JSONResponseWInterface(w, http.StatusOK, response)
@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/types"
+	"github.com/lesismal/nbio/nbhttp/websocket"
+)
+
+const (
+	// envelopeProtocol is the websocket subprotocol of the viewers that receive every message in a typed envelope,
+	// the viewers that do not ask for it keep receiving the bare messages
+	envelopeProtocol = "spotoncars.v1"
+	// protoProtocol is the websocket subprotocol of the drivers that send the locations and of the viewers that
+	// receive every message in protocol buffers, see api/stream.proto
+	protoProtocol = "spotoncars.proto.v1"
+	// envelopeVersion is the version of the envelope that is sent with every message
+	envelopeVersion = 1
+)
+
+// the types of the messages that are sent in an envelope
+const (
+	messageLocation   = "location"
+	messageTrail      = "trail"
+	messageStatus     = "status"
+	messageETA        = "eta"
+	messageEnded      = "ended"
+	messageSignalLost = "signal_lost"
+	messageDriverInfo = "driver_info"
+)
+
+// envelope is the message that is sent to the viewers that use the envelope protocol
+type envelope struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Data    any    `json:"data"`
+}
+
+// envelopeType is a function that is used to get the type of the envelope of the given message of the location
+// bus, the signal messages are only published when the signal is not live or when it is live again so they are
+// sent as signal_lost with the status of the signal
+func envelopeType(kind string) string {
+	switch kind {
+	case services.LocationMessage:
+		return messageLocation
+	case services.SignalMessage:
+		return messageSignalLost
+	default:
+		return kind
+	}
+}
+
+// sender is used to write the messages of the stream to a viewer either in an envelope or as they are depending
+// on the subprotocol that the viewer has asked for
+type sender struct {
+	conn     *websocket.Conn
+	protocol string
+}
+
+// newSender is a function that is used to create the sender of the given connection
+func newSender(conn *websocket.Conn) *sender {
+	return &sender{
+		conn:     conn,
+		protocol: conn.Subprotocol(),
+	}
+}
+
+// enveloped is used to check wether the viewer receives every message in an envelope
+func (s *sender) enveloped() bool {
+	return s.protocol == envelopeProtocol || s.protocol == protoProtocol
+}
+
+// send is used to send the given payload of the given type, the viewers without the envelope only know about the
+// locations so the other messages are not sent to them
+func (s *sender) send(kind string, payload []byte) error {
+	if kind == messageLocation {
+		return s.sendLocation(payload)
+	}
+
+	switch s.protocol {
+	case envelopeProtocol:
+		return s.write(kind, json.RawMessage(payload))
+	case protoProtocol:
+		return s.writeProto(kind, payload)
+	default:
+		return nil
+	}
+}
+
+// sendLocation is used to send the given location of the location bus in the encoding of the viewer, since the
+// location bus carries the locations in either of the schemas
+func (s *sender) sendLocation(value []byte) error {
+	if s.protocol == protoProtocol {
+		location, err := types.LocationProto(value)
+		if err != nil {
+			return err
+		}
+		message, err := types.MessageProto(envelopeVersion, messageLocation, location, nil)
+		if err != nil {
+			return err
+		}
+		return s.conn.WriteMessage(websocket.BinaryMessage, message)
+	}
+
+	location, err := types.LocationJSON(value)
+	if err != nil {
+		return err
+	}
+	if s.protocol == envelopeProtocol {
+		return s.write(messageLocation, json.RawMessage(location))
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, location)
+}
+
+// trail is used to send the given path of the stream so far, the viewers without the envelope receive the
+// locations of the path one by one
+func (s *sender) trail(locations [][]byte) error {
+	switch s.protocol {
+	case envelopeProtocol:
+		trail := make([]json.RawMessage, 0, len(locations))
+		for _, value := range locations {
+			location, err := types.LocationJSON(value)
+			if err != nil {
+				return err
+			}
+			trail = append(trail, location)
+		}
+		return s.write(messageTrail, trail)
+	case protoProtocol:
+		payload, err := types.TrailProto(envelopeVersion, messageTrail, locations)
+		if err != nil {
+			return err
+		}
+		return s.conn.WriteMessage(websocket.BinaryMessage, payload)
+	default:
+		for _, location := range locations {
+			if err := s.sendLocation(location); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// notify is used to send the given data of the given type to the viewers with the envelope only, since the viewers
+// without the envelope do not know about these messages
+func (s *sender) notify(kind string, data any) error {
+	switch s.protocol {
+	case envelopeProtocol:
+		return s.write(kind, data)
+	case protoProtocol:
+		payload, err := sonic.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return s.writeProto(kind, payload)
+	default:
+		return nil
+	}
+}
+
+// end is used to tell the viewer that the stream has ended before closing the connection
+func (s *sender) end() {
+	_ = s.notify(messageEnded, services.Ended{
+		Type: services.EndedMessage,
+		At:   time.Now().UnixMilli(),
+	})
+
+	s.conn.WriteClose(closeNormal, "the stream has ended")
+	s.conn.Close()
+}
+
+// writeProto is used to write the given JSON payload of the given type in a protocol buffers message
+func (s *sender) writeProto(kind string, payload []byte) error {
+	message, err := types.MessageProto(envelopeVersion, kind, nil, payload)
+	if err != nil {
+		return err
+	}
+
+	return s.conn.WriteMessage(websocket.BinaryMessage, message)
+}
+
+// write is used to write the given data in an envelope of the given type
+func (s *sender) write(kind string, data any) error {
+	payload, err := sonic.Marshal(envelope{
+		Version: envelopeVersion,
+		Type:    kind,
+		Data:    data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.conn.WriteMessage(websocket.TextMessage, payload)
+}
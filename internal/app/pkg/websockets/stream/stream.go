@@ -4,29 +4,22 @@ package stream
 import (
 	"net/http"
 	"sync/atomic"
-	"time"
 
-	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	_lib "github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
 	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/middlewares"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator/v10"
 )
 
-const (
-	// updateinterval is the update frequency of redis
-	updateinterval = 5
-	// heartbeat is the frequency to send a ping to the websocket client
-	heartbeat = 5 * time.Second
-	// pending is the deadline to keep wating for the kafka reader
-	pending = 2 * time.Second
-)
+// closeNormal is the close code of a websocket connection that is closed after the stream has ended
+const closeNormal = 1000
 
 var (
-	v = validator.New()
-	h = lib.WrapHandler
-	m = lib.WrapMiddleware
+	v = lib.NewValidator()
+	h = _lib.WrapHandler
+	m = _lib.WrapMiddleware
 )
 
 // WebSocket contains all the websockets that are related to the stream
@@ -42,7 +35,7 @@ func WebSocket(e *env.Env, c *connections.C) http.Handler {
 		r.Get("/", h(create, e, c))
 	})
 
-	r.Get("/add/{booking_token_id}/{driver_id}/{partition}", h(add, e, c))
+	r.Get("/add/{booking_token_id}/{driver_id}/{topic}/{partition}", h(add, e, c))
 
 	return r
 }
@@ -2,104 +2,132 @@ package stream
 
 import (
 	"context"
-	"errors"
 	"net/http"
-	"strconv"
 	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
-	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/app/pkg/services"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	_errors "github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"github.com/go-chi/chi/v5"
 	"github.com/lesismal/nbio/nbhttp/websocket"
-	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
-	"github.com/segmentio/kafka-go"
 )
 
 func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C) {
+	// the settings are kept for the lifetime of the connection even if they are reloaded
+	settings := e.Settings()
+
 	bookingID := chi.URLParam(r, "booking_id")
 	if bookingID == "" {
-		http.Error(w, "provide a valid booking id", http.StatusBadRequest)
+		lib.ErrorResponse(w, r, _errors.ErrBookingIDNotValid)
 		return
 	}
 
-	client := c.R.DB
-
-	val := client.Get(r.Context(), bookingID).Val()
-	if val == "" {
-		http.Error(w, "provide a valid booking id", http.StatusBadRequest)
-		return
-	}
-	BookingID := lib.NewBookingID()
-	err := sonic.UnmarshalString(val, &BookingID)
+	request, err := services.ParseTrailRequest(r.URL.Query())
 	if err != nil {
-		log.Error().Err(err).Msg("failed to unmarshal the value from Redis")
-		http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+		lib.ErrorResponse(w, r, err)
 		return
 	}
-	partition := BookingID[lib.BookingIDPartitionNo]
-	cKey := lib.C(partition)
 
-	val = client.Get(r.Context(), cKey).Val()
-	if val == "" {
-		log.Warn().Msg("number of connections is not present in the redis db")
-		http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
-		return
-	}
-	connections, err := strconv.Atoi(val)
+	stream, err := services.JoinStream(r.Context(), e, c, bookingID)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to convert the maximum connections to int")
-		http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
-		return
-	}
-	if connections >= e.MaxConnections {
-		log.Warn().
-			Msgf(
-				"booking_id : %s\tconnections : %d\tmaximum number of connections reached for the booking",
-				bookingID,
-				connections,
-			)
-		http.Error(w, _errors.ErrBadRequest.Error(), http.StatusTooManyRequests)
-		return
-	}
-	client.Incr(r.Context(), cKey)
-
-	location := client.Get(r.Context(), lib.L(partition)).Val()
-	if location == "" {
-		log.Error().Msg("failed to get the last location from redis")
-		http.Error(w, _errors.ErrServer.Error(), http.StatusInternalServerError)
+		lib.ErrorResponse(w, r, err)
 		return
 	}
+	slot := stream.Slot
+	feed := services.NewFeed(bookingID, stream.Location)
 
 	upgrader := websocket.NewUpgrader()
+	upgrader.Subprotocols = []string{envelopeProtocol, protoProtocol}
 	upgrader.CheckOrigin = func(r *http.Request) bool {
 		return true
 	}
 
-	var (
-		ctx    context.Context
-		cancel context.CancelFunc
-	)
-
 	upgrader.OnOpen(func(conn *websocket.Conn) {
 		log.Info().
 			Msgf("addr : %s\tconnection opened", conn.RemoteAddr().String())
 		done := make(chan struct{})
 		closed := int32(0)
+		out := newSender(conn)
 
 		go func() {
-			ticker := time.NewTicker(heartbeat)
-			reader := c.KafkaReader(e, e.Topic, partition, kafka.LastOffset)
+			// the viewers of the same booking share a single subscription of the location bus
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			viewer, err := c.Hub.Join(ctx, slot)
+			cancel()
+			if err != nil {
+				log.Error().Err(err).
+					Msgf(
+						"booking_id : %s\tfailed to join the viewers of the booking",
+						bookingID,
+					)
+				conn.Close()
+				return
+			}
+			ticker := time.NewTicker(time.Duration(settings.Heartbeat) * time.Second)
+			eta := time.NewTicker(time.Duration(settings.ETAInterval) * time.Second)
+			// the last known location is sent again when there are no new locations for a while
+			pending := time.NewTicker(time.Duration(settings.Pending) * time.Second)
 
 			defer func() {
-				reader.Close()
+				c.Hub.Leave(viewer)
 				ticker.Stop()
+				eta.Stop()
+				pending.Stop()
 			}()
 
+			// the messages that are published before next are already sent with the path so far
+			next := connections.LatestOffset
+			if request != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				locations, end, err := feed.Replay(ctx, c, settings, stream, request)
+				cancel()
+				if err != nil {
+					log.Error().Err(err).
+						Msgf(
+							"booking_id : %s\tfailed to read the path of the booking",
+							bookingID,
+						)
+				} else {
+					next = end
+					if err := out.trail(locations); err != nil {
+						log.Error().Err(err).Msg("error sending data to the websocket client")
+					}
+				}
+			}
+
+			// the request is already done once the connection is upgraded, so the first messages are read with a
+			// context of their own
+			ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+
+			// the viewers with the envelope are sent the details of the driver and the job status right away
+			if out.enveloped() {
+				info, err := services.GetDriverInfo(ctx, c, bookingID)
+				if err != nil {
+					log.Error().Err(err).
+						Msgf(
+							"booking_id : %s\tfailed to get the details of the driver",
+							bookingID,
+						)
+				} else {
+					out.notify(messageDriverInfo, info)
+				}
+				out.notify(messageStatus, services.GetJobState(ctx, c, slot))
+				out.send(messageLocation, feed.Location)
+			}
+
+			// the viewers that connect while the signal is not live are told so right away
+			if current := services.CurrentSignal(ctx, c, settings, slot); current != nil {
+				if payload, err := sonic.Marshal(current); err == nil {
+					out.send(messageSignalLost, payload)
+				}
+			}
+			cancel()
+
 			for {
 				select {
 				case <-done:
@@ -111,72 +139,98 @@ func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C)
 
 					log.Info().Msg("heartbeat ... ")
 					conn.WriteMessage(websocket.PingMessage, nil)
-				default:
-					ctx, cancel = context.WithTimeout(r.Context(), pending)
-					message, err := reader.ReadMessage(ctx)
-					cancel()
-
-					if err != nil {
-						if errors.Is(err, context.DeadlineExceeded) {
-							if isClosed(&closed) {
-								continue
-							}
+				case <-eta.C:
+					if isClosed(&closed) {
+						return
+					}
 
-							if err := conn.WriteMessage(websocket.TextMessage, []byte(location)); err != nil {
-								log.Error().Err(err).Msg("error sending data to the websocket client")
-							}
-							continue
-						}
-						log.Error().Err(err).Msg("error reading message from kafka")
+					ctx, cancel := context.WithTimeout(context.Background(), time.Duration(settings.ETAInterval)*time.Second)
+					payload := feed.ETA(ctx, c, time.Now())
+					cancel()
+					if payload == nil {
 						continue
 					}
+					if err := out.send(messageETA, payload); err != nil {
+						log.Error().Err(err).Msg("error sending data to the websocket client")
+					}
+				case <-pending.C:
+					if isClosed(&closed) {
+						return
+					}
 
-					if len(message.Value) == 0 || isClosed(&closed) {
+					if err := out.send(messageLocation, feed.Location); err != nil {
+						log.Error().Err(err).Msg("error sending data to the websocket client")
+					}
+				case message, ok := <-viewer.Messages():
+					if !ok {
+						log.Warn().Err(viewer.Err()).
+							Msgf(
+								"booking_id : %s\tthe viewer is removed from the hub, closing the connection",
+								bookingID,
+							)
+						conn.Close()
+						return
+					}
+					if isClosed(&closed) {
+						return
+					}
+					// the messages of the path and the messages that are read again after the hub subscribes again are
+					// not sent twice
+					if message.Offset < next {
 						continue
 					}
+					next = message.Offset + 1
+					pending.Reset(time.Duration(settings.Pending) * time.Second)
 
-					location = string(message.Value)
-					if err := conn.WriteMessage(websocket.TextMessage, message.Value); err != nil {
-						log.Error().Err(err).Msg("error sending data to the websocket client")
+					kind, ok := feed.Next(message.Value)
+					if !ok {
 						continue
 					}
+					// the ended message is sent right before the connection is closed
+					if kind != services.EndedMessage {
+						if err := out.send(envelopeType(kind), message.Value); err != nil {
+							log.Error().Err(err).Msg("error sending data to the websocket client")
+							continue
+						}
+					}
+					if kind == services.LocationMessage && feed.Transition() != nil {
+						ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+						out.notify(messageStatus, services.GetJobState(ctx, c, slot))
+						cancel()
+					}
+
+					// the stream ends once the job is cleared or the booking ends, so the viewer is disconnected
+					// after it is told so
+					if feed.Ended() {
+						log.Info().
+							Msgf(
+								"booking_id : %s\tthe stream has ended, closing the connection",
+								bookingID,
+							)
+						out.end()
+						return
+					}
 				}
 			}
 		}()
 
-		conn.OnClose(func(c *websocket.Conn, err error) {
+		conn.OnClose(func(conn *websocket.Conn, err error) {
 			close(done)
 			atomic.StoreInt32(&closed, 1)
 
-			go func() {
-				ctx := context.TODO()
-
-				val := client.Get(ctx, cKey).Val()
-				connections, err := strconv.Atoi(val)
-				if err != nil {
-					log.Error().Err(err).Msg("failed to convert the connections to int")
-					resetActiveConn(ctx, client, cKey)
-					return
-				}
-				if connections <= 0 {
-					resetActiveConn(ctx, client, cKey)
-					return
-				}
-
-				client.Decr(ctx, cKey)
-			}()
+			go services.LeaveStream(c, slot)
 
 			if err != nil {
 				log.Error().Err(err).
 					Msgf(
 						"addr : %s\tconnection closed with error",
-						c.RemoteAddr().String(),
+						conn.RemoteAddr().String(),
 					)
 			} else {
 				log.Info().
 					Msgf(
 						"addr : %s\tconnection closed",
-						c.RemoteAddr().String(),
+						conn.RemoteAddr().String(),
 					)
 			}
 		})
@@ -185,15 +239,7 @@ func view(w http.ResponseWriter, r *http.Request, e *env.Env, c *connections.C)
 	_, err = upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("error occured while upgrading the websocket connection")
+		services.LeaveStream(c, slot)
 		return
 	}
 }
-
-func resetActiveConn(
-	ctx context.Context,
-	client *redis.Client,
-	key string,
-) {
-	ttl := client.TTL(ctx, key).Val()
-	client.SetNX(ctx, key, 0, ttl)
-}
@@ -0,0 +1,41 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+)
+
+// ErrArchiveNotFound is an error that occurs when there is no archive under the given name
+var ErrArchiveNotFound = fmt.Errorf("there is no archive with the given name")
+
+// ArchiveStore is the interface that is used to persist the location histories of the finished bookings
+type ArchiveStore interface {
+	// Put is used to save the given data under the given name, replacing any existing archive
+	Put(ctx context.Context, name string, data []byte) error
+	// Get is used to read the archive under the given name
+	Get(ctx context.Context, name string) ([]byte, error)
+	// Delete is used to remove the archive under the given name
+	Delete(ctx context.Context, name string) error
+	// Close is used to close the connection to the archive store
+	Close() error
+}
+
+// InitArchive is a function that is used to initialize the archive store depending on the configured backend
+func (c *C) InitArchive(e *env.Env) {
+	switch enums.Backend(e.ArchiveStore) {
+	case enums.Local:
+		archive, err := NewLocalArchive(e.ArchivePath)
+		lib.LogFatal(err)
+		c.Archive = archive
+	case enums.S3:
+		archive, err := NewS3(e)
+		lib.LogFatal(err)
+		c.Archive = archive
+	default:
+		c.Archive = NewGCS(e)
+	}
+}
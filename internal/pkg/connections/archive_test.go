@@ -0,0 +1,188 @@
+package connections
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+)
+
+// fakeS3 is an S3 compatible object storage that keeps the objects in memory
+type fakeS3 struct {
+	objects map[string][]byte
+	mu      sync.Mutex
+}
+
+// ServeHTTP is used to handle the object requests of the S3 API
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+		return
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	data, ok := f.objects[key]
+	if !ok {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("ETag", `"etag"`)
+	w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+// readS3Body is used to read the body of an upload, the uploads over plain HTTP are signed in chunks
+//
+//	<size in hex>;chunk-signature=<signature>\r\n<data>\r\n ... 0;chunk-signature=<signature>\r\n\r\n
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	reader := bufio.NewReader(r.Body)
+	body := []byte{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return body, nil
+		}
+		body = append(body, chunk[:n]...)
+	}
+}
+
+// testArchives is used to get every backend of the archive store that runs without a remote service, so that
+// the same behaviour is checked for all of them
+func testArchives(t *testing.T) map[string]ArchiveStore {
+	t.Helper()
+
+	local, err := NewLocalArchive(filepath.Join(t.TempDir(), "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	t.Cleanup(server.Close)
+	s3, err := NewS3(&env.Env{
+		S3Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		S3AccessKey: "access",
+		S3SecretKey: "secret",
+		S3Region:    "us-east-1",
+		S3Insecure:  true,
+		BucketName:  "archives",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]ArchiveStore{
+		"local": local,
+		"s3":    s3,
+	}
+}
+
+func TestArchiveStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, archive := range testArchives(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := archive.Get(ctx, "B1.json"); !errors.Is(err, ErrArchiveNotFound) {
+				t.Errorf("got %v, want a missing archive to be reported", err)
+			}
+			if err := archive.Delete(ctx, "B1.json"); !errors.Is(err, ErrArchiveNotFound) {
+				t.Errorf("got %v, want a missing archive to be reported on delete", err)
+			}
+
+			// the archive round trips and a second put replaces it
+			for _, data := range []string{`[{"lat":51.5}]`, `[{"lat":51.6}]`} {
+				if err := archive.Put(ctx, "B1.json", []byte(data)); err != nil {
+					t.Fatal(err)
+				}
+				got, err := archive.Get(ctx, "B1.json")
+				if err != nil || string(got) != data {
+					t.Errorf("got %q, %v, want %q", got, err, data)
+				}
+			}
+
+			if err := archive.Delete(ctx, "B1.json"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := archive.Get(ctx, "B1.json"); !errors.Is(err, ErrArchiveNotFound) {
+				t.Errorf("got %v, want the deleted archive to be missing", err)
+			}
+		})
+	}
+}
+
+func TestLocalArchiveNames(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	archive, err := NewLocalArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the names can not leave the directory of the archives
+	for _, name := range []string{"", ".", "..", "../B1.json", `dir\B1.json`} {
+		if err := archive.Put(ctx, name, []byte("{}")); err == nil {
+			t.Errorf("%q: want the name to be rejected", name)
+		}
+		if _, err := archive.Get(ctx, name); err == nil || errors.Is(err, ErrArchiveNotFound) {
+			t.Errorf("%q: got %v, want the name to be rejected", name, err)
+		}
+	}
+
+	// the temporary file of a put is not left behind
+	if err := archive.Put(ctx, "B1.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "B1.json" {
+		t.Errorf("got %v, %v, want only the archive in the directory", entries, err)
+	}
+}
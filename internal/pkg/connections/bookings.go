@@ -0,0 +1,169 @@
+package connections
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+)
+
+// ErrBookingNotFound is an error that occurs when there is no booking with the given booking ID
+var ErrBookingNotFound = fmt.Errorf("there is no booking with the given booking id")
+
+// Driver contains the details of the driver that is assigned to a booking
+type Driver struct {
+	// ID is nil when a driver is not assigned to the booking yet
+	ID      *int
+	Name    string
+	Contact string
+}
+
+// Vehicle contains the details of the vehicle that is assigned to a booking
+type Vehicle struct {
+	RegNo string
+	Model string
+	Color string
+}
+
+// Booking contains the details of a booking
+type Booking struct {
+	// JobStatus is nil when the status of the job is not recorded yet
+	JobStatus     *int
+	Driver        Driver
+	Vehicle       Vehicle
+	RefNo         string
+	PassengerName string
+	Contact       string
+	PickupAddr    string
+	DropAddr      string
+	Total         float64
+}
+
+// BookingRepository is the interface that is used to read the bookings from the booking database
+type BookingRepository interface {
+	// GetBooking is used to get the booking with its driver and vehicle by the booking ID
+	GetBooking(ctx context.Context, bookingID string) (Booking, error)
+}
+
+// SQLBookings is the booking repository that is backed by a SQL database
+type SQLBookings struct {
+	db      *sql.DB
+	dialect enums.Backend
+	query   string
+}
+
+// NewSQLBookings is a function that is used to create a booking repository on top of the given database
+// the dialect must be one of mssql, postgres or sqlite
+func NewSQLBookings(db *sql.DB, dialect enums.Backend) *SQLBookings {
+	placeholder := "?"
+	switch dialect {
+	case enums.MSSQL:
+		placeholder = "@BookRefNo"
+	case enums.Postgres:
+		placeholder = "$1"
+	}
+
+	return &SQLBookings{
+		db:      db,
+		dialect: dialect,
+		query: fmt.Sprintf(`
+SELECT
+	bookings.BookRefNo,
+	bookings.BookPassengerNm,
+	bookings.BookingContact,
+	bookings.BookPickUpAddr,
+	bookings.BookDropAddr,
+	bookings.JobStatus,
+	bookings.BookTotal,
+	bookings.DriverPk,
+	bookings.DriverName,
+	bookings.DriverContact,
+	COALESCE(vehicles.VehicleRegNo, bookings.VehicleRegNo),
+	COALESCE(vehicles.VehicleModel, bookings.VehicleModal),
+	vehicles.VehicleColor
+FROM
+	Tbl_BookingDetails bookings
+	LEFT JOIN Tbl_VehicleDetails vehicles ON bookings.VehicleId = vehicles.VehicleId
+WHERE
+	bookings.BookRefNo = %s
+`, placeholder),
+	}
+}
+
+func (s *SQLBookings) arg(bookingID string) any {
+	if s.dialect == enums.MSSQL {
+		return sql.Named("BookRefNo", bookingID)
+	}
+	return bookingID
+}
+
+// GetBooking is used to get the booking with its driver and vehicle by the booking ID
+func (s *SQLBookings) GetBooking(ctx context.Context, bookingID string) (Booking, error) {
+	var (
+		refNo         sql.NullString
+		passengerName sql.NullString
+		contact       sql.NullString
+		pickupAddr    sql.NullString
+		dropAddr      sql.NullString
+		jobStatus     sql.NullInt64
+		total         sql.NullFloat64
+		driverID      sql.NullInt64
+		driverName    sql.NullString
+		driverContact sql.NullString
+		vehicleRegNo  sql.NullString
+		vehicleModel  sql.NullString
+		vehicleColor  sql.NullString
+	)
+
+	err := s.db.QueryRowContext(ctx, s.query, s.arg(bookingID)).Scan(
+		&refNo,
+		&passengerName,
+		&contact,
+		&pickupAddr,
+		&dropAddr,
+		&jobStatus,
+		&total,
+		&driverID,
+		&driverName,
+		&driverContact,
+		&vehicleRegNo,
+		&vehicleModel,
+		&vehicleColor,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Booking{}, ErrBookingNotFound
+		}
+		return Booking{}, err
+	}
+
+	booking := Booking{
+		RefNo:         refNo.String,
+		PassengerName: passengerName.String,
+		Contact:       contact.String,
+		PickupAddr:    pickupAddr.String,
+		DropAddr:      dropAddr.String,
+		Total:         total.Float64,
+		Driver: Driver{
+			Name:    driverName.String,
+			Contact: driverContact.String,
+		},
+		Vehicle: Vehicle{
+			RegNo: vehicleRegNo.String,
+			Model: vehicleModel.String,
+			Color: vehicleColor.String,
+		},
+	}
+	if jobStatus.Valid {
+		status := int(jobStatus.Int64)
+		booking.JobStatus = &status
+	}
+	if driverID.Valid {
+		id := int(driverID.Int64)
+		booking.Driver.ID = &id
+	}
+
+	return booking, nil
+}
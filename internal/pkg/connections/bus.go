@@ -0,0 +1,62 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// ErrUnknownTopic is an error that occurs when the topic does not exist in the location bus
+var ErrUnknownTopic = fmt.Errorf("the topic does not exist in the location bus")
+
+// LatestOffset is used to subscribe to a partition starting from the next message that is published
+const LatestOffset int64 = -1
+
+// Message is a single message that is read from the location bus
+type Message struct {
+	Time   time.Time
+	Key    []byte
+	Value  []byte
+	Offset int64
+}
+
+// LocationBus is the interface that is used to publish and consume the location streams
+// of the active bookings, every booking owns a single slot (topic and partition) of the bus
+type LocationBus interface {
+	// Publish is used to publish the given value to the given slot
+	Publish(ctx context.Context, slot partitions.Slot, key, value []byte) error
+	// PublishBatch is used to publish the given values to the given slot in the given order
+	PublishBatch(ctx context.Context, slot partitions.Slot, key []byte, values [][]byte) error
+	// Subscribe is used to consume the given slot starting from the given offset
+	Subscribe(ctx context.Context, slot partitions.Slot, offset int64) (Subscription, error)
+	// ReadRange is used to read all the messages of the given slot in the range [from, to)
+	ReadRange(ctx context.Context, slot partitions.Slot, from, to int64) ([]Message, error)
+	// LastOffset is used to get the offset of the next message that will be published to the given slot
+	LastOffset(ctx context.Context, slot partitions.Slot) (int64, error)
+	// TopicPartitions is used to get the number of partitions of the given topic
+	TopicPartitions(ctx context.Context, topic string) (int, error)
+	// Close is used to close the connection to the bus
+	Close() error
+}
+
+// Subscription is a consumer of a single slot of the location bus
+type Subscription interface {
+	// Read is used to block until the next message is available or the context is done
+	Read(ctx context.Context) (Message, error)
+	// Close is used to stop consuming the partition
+	Close() error
+}
+
+// InitBus is a function that is used to initialize the location bus depending on the configured backend
+func (c *C) InitBus(e *env.Env) {
+	if e.LocationBus == string(enums.Memory) {
+		c.Bus = NewMemoryBus(e.TotalPartitions)
+		return
+	}
+
+	c.Bus = NewKafka(e)
+}
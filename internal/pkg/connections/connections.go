@@ -4,31 +4,45 @@ package connections
 import (
 	"database/sql"
 
-	"cloud.google.com/go/storage"
-	"googlemaps.github.io/maps"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 )
 
 // C contains all third pary connections
 type C struct {
 	// R contains all Redis related databases
 	R *Redis
-	// K contains all Kafka writers
-	K *KafkaWriters
+	// State contains the store that keeps the state of the active bookings
+	State StateStore
+	// Bus contains the location bus that is used to stream the locations
+	Bus LocationBus
+	// Hub contains the hub that shares the subscriptions of the location bus between the viewers
+	Hub *Hub
+	// Partitions contains the allocator that is used to hand out the partitions of the bus
+	Partitions *partitions.Allocator
+	// Queue contains the waiting queue of the stream creations when every partition is in use
+	Queue *partitions.Queue
 	// DB contains the Database connection
 	DB *sql.DB
-	// M contains the connection to Google maps
-	M *maps.Client
-	// S contains the cloud run storage connection
-	S *storage.Client
+	// Bookings contains the repository that is used to read the bookings
+	Bookings BookingRepository
+	// Geo contains the geocoder that is used to convert addresses to coordinates
+	Geo Geocoder
+	// Router contains the route estimator that is used to estimate the time of arrival
+	Router RouteEstimator
+	// Archive contains the store that keeps the location histories
+	Archive ArchiveStore
 }
 
 // Close is a function that is used to close all the connections
 func (c *C) Close() {
-	// close all the kafka writers
-	c.K.B.Close()
+	// close the location bus
+	c.Bus.Close()
 
-	// close all the redis clients
-	c.R.DB.Close()
+	// close the state store
+	c.State.Close()
+
+	// close the archive store
+	c.Archive.Close()
 
 	// close the connection to the database
 	c.DB.Close()
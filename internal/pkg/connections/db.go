@@ -10,10 +10,39 @@ import (
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	// Driver for the postgres server
+	_ "github.com/lib/pq"
+	// Driver for sqlite
+	_ "modernc.org/sqlite"
 )
 
 // InitDB is a function that is used to initialize databases
 func (c *C) InitDB(e *env.Env) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	switch enums.Backend(e.Database) {
+	case enums.Postgres:
+		db, err = sql.Open("postgres", e.DatabaseURL)
+	case enums.SQLite:
+		db, err = sql.Open("sqlite", e.DatabaseURL)
+	default:
+		db, err = sql.Open("sqlserver", mssqlDSN(e))
+	}
+	if err != nil {
+		lib.LogFatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		lib.LogFatal(err)
+	}
+
+	c.DB = db
+	c.Bookings = NewSQLBookings(db, enums.Backend(e.Database))
+}
+
+func mssqlDSN(e *env.Env) string {
 	username := e.DBUser
 	password := ""
 	if e.Env == string(enums.Prd) {
@@ -24,15 +53,6 @@ func (c *C) InitDB(e *env.Env) {
 	host := e.DBHost
 	port := e.DBPort
 	database := e.DBDatabase
-	dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%d?database=%s&encrypt=true&TrustServerCertificate=true", username, password, host, port, database)
-
-	db, err := sql.Open("sqlserver", dsn)
-	if err != nil {
-		lib.LogFatal(err)
-	}
-	if err := db.Ping(); err != nil {
-		lib.LogFatal(err)
-	}
 
-	c.DB = db
+	return fmt.Sprintf("sqlserver://%s:%s@%s:%d?database=%s&encrypt=true&TrustServerCertificate=true", username, password, host, port, database)
 }
@@ -0,0 +1,142 @@
+package connections
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+type geocodeCacheEntry struct {
+	address string
+	geo     Geo
+}
+
+// CachedGeocoder is a geocoder that caches the results of another geocoder in an in process LRU
+// and optionally in Redis, so that the results are shared between the instances and survive restarts
+type CachedGeocoder struct {
+	geocoder Geocoder
+	redis    *redis.Client
+	entries  map[string]*list.Element
+	lru      *list.List
+	ttl      time.Duration
+	size     int
+	mu       sync.Mutex
+}
+
+// NewCachedGeocoder is a function that is used to wrap the given geocoder with an in process LRU cache
+// that holds at most size addresses
+func NewCachedGeocoder(geocoder Geocoder, size int) *CachedGeocoder {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &CachedGeocoder{
+		geocoder: geocoder,
+		entries:  make(map[string]*list.Element, size),
+		lru:      list.New(),
+		size:     size,
+	}
+}
+
+// WithRedis is used to add Redis as the second cache layer with the given ttl
+func (c *CachedGeocoder) WithRedis(client *redis.Client, ttl time.Duration) *CachedGeocoder {
+	c.redis = client
+	c.ttl = ttl
+	return c
+}
+
+func geocodeKey(address string) string {
+	return "geo:" + address
+}
+
+// load is used to get the given address from the in process cache
+func (c *CachedGeocoder) load(address string) (Geo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[address]
+	if !ok {
+		return Geo{}, false
+	}
+	c.lru.MoveToFront(element)
+
+	return element.Value.(geocodeCacheEntry).geo, true
+}
+
+// store is used to add the given address to the in process cache while evicting the least recently used address
+func (c *CachedGeocoder) store(address string, geo Geo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[address]; ok {
+		element.Value = geocodeCacheEntry{address: address, geo: geo}
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[address] = c.lru.PushFront(geocodeCacheEntry{address: address, geo: geo})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(geocodeCacheEntry).address)
+	}
+}
+
+// Geocode is used to get the coordinates of the given address
+func (c *CachedGeocoder) Geocode(ctx context.Context, address string) (Geo, error) {
+	key := NormalizeAddress(address)
+	if key == "" {
+		return Geo{}, ErrGeocodeNoResults
+	}
+
+	if geo, ok := c.load(key); ok {
+		return geo, nil
+	}
+
+	if c.redis != nil {
+		val, err := c.redis.Get(ctx, geocodeKey(key)).Result()
+		if err == nil {
+			var geo Geo
+			if err = sonic.UnmarshalString(val, &geo); err == nil {
+				c.store(key, geo)
+				return geo, nil
+			}
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			log.Warn().Err(err).
+				Msgf(
+					"address : %s\tfailed to read the geocode cache",
+					key,
+				)
+		}
+	}
+
+	geo, err := c.geocoder.Geocode(ctx, address)
+	if err != nil {
+		return Geo{}, err
+	}
+	c.store(key, geo)
+
+	if c.redis != nil {
+		payload, err := sonic.MarshalString(geo)
+		if err == nil {
+			err = c.redis.Set(ctx, geocodeKey(key), payload, c.ttl).Err()
+		}
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf(
+					"address : %s\tfailed to write the geocode cache",
+					key,
+				)
+		}
+	}
+
+	return geo, nil
+}
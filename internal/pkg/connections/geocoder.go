@@ -0,0 +1,71 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+)
+
+// ErrGeocodeNoResults is an error that occurs when the geocoder cannot find the given address
+var ErrGeocodeNoResults = fmt.Errorf("the given address cannot be found")
+
+// Geo lat-lon direction mapping
+type Geo struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Geocoder is the interface that is used to convert addresses to coordinates
+type Geocoder interface {
+	// Geocode is used to get the coordinates of the given address
+	Geocode(ctx context.Context, address string) (Geo, error)
+}
+
+// GeocodeError is an error that occurs when a single address cannot be geocoded
+type GeocodeError struct {
+	Err     error
+	Address string
+}
+
+func (g *GeocodeError) Error() string {
+	return fmt.Sprintf("address : %s\t%s", g.Address, g.Err.Error())
+}
+
+func (g *GeocodeError) Unwrap() error {
+	return g.Err
+}
+
+// NormalizeAddress is used to get the canonical form of the given address that is used as the cache key
+func NormalizeAddress(address string) string {
+	address = strings.ToLower(strings.Join(strings.Fields(address), " "))
+	return strings.Trim(address, " ,.;")
+}
+
+// InitGeocoder is a function that is used to initialize the geocoder depending on the configured backend
+//
+// NOTE: The Redis cache layer is only used when the state store is backed by Redis, so InitState
+// must be called before this function
+func (c *C) InitGeocoder(e *env.Env) {
+	var geocoder Geocoder
+
+	switch enums.Backend(e.Geocoder) {
+	case enums.Static:
+		static, err := NewStaticGeocoderFromFile(e.GeocoderStaticFile)
+		lib.LogFatal(err)
+		geocoder = static
+	default:
+		geocoder = NewGoogleGeocoder(e)
+	}
+
+	cache := NewCachedGeocoder(geocoder, e.GeocodeCacheSize)
+	if c.R != nil {
+		cache.WithRedis(c.R.DB, time.Duration(e.GeocodeCacheTTL)*time.Second)
+	}
+
+	c.Geo = cache
+}
@@ -0,0 +1,139 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// countingGeocoder is a geocoder that counts the addresses that it is asked for
+type countingGeocoder struct {
+	geocoder Geocoder
+	calls    int
+}
+
+// Geocode is used to get the coordinates of the given address
+func (g *countingGeocoder) Geocode(ctx context.Context, address string) (Geo, error) {
+	g.calls++
+	return g.geocoder.Geocode(ctx, address)
+}
+
+// testAddresses contains the addresses that the static geocoder of the tests resolves
+var testAddresses = map[string]Geo{
+	"Colombo Fort": {Lat: 6.9344, Lon: 79.8428},
+	"Galle Face":   {Lat: 6.9271, Lon: 79.8450},
+	"Kandy":        {Lat: 7.2906, Lon: 80.6337},
+}
+
+func TestStaticGeocoder(t *testing.T) {
+	ctx := context.Background()
+	geocoder := NewStaticGeocoder(testAddresses)
+
+	// the addresses are matched in their canonical form
+	geo, err := geocoder.Geocode(ctx, "  colombo   FORT. ")
+	if err != nil || geo != testAddresses["Colombo Fort"] {
+		t.Errorf("got %+v, %v, want the coordinates of Colombo Fort", geo, err)
+	}
+	if _, err := geocoder.Geocode(ctx, "Jaffna"); !errors.Is(err, ErrGeocodeNoResults) {
+		t.Errorf("got %v, want an unknown address to have no results", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "addresses.json")
+	if err := os.WriteFile(file, []byte(`{"Galle Face": {"lat": 6.9271, "lon": 79.845}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromFile, err := NewStaticGeocoderFromFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geo, err := fromFile.Geocode(ctx, "galle face"); err != nil || geo != testAddresses["Galle Face"] {
+		t.Errorf("got %+v, %v, want the coordinates of the file", geo, err)
+	}
+	if _, err := NewStaticGeocoderFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("want a missing file to be reported")
+	}
+}
+
+func TestCachedGeocoder(t *testing.T) {
+	ctx := context.Background()
+	counting := &countingGeocoder{geocoder: NewStaticGeocoder(testAddresses)}
+	cache := NewCachedGeocoder(counting, 2)
+
+	// the same address in another form is a cache hit
+	for _, address := range []string{"Colombo Fort", "colombo fort", "COLOMBO  FORT,"} {
+		if geo, err := cache.Geocode(ctx, address); err != nil || geo != testAddresses["Colombo Fort"] {
+			t.Errorf("%q: got %+v, %v", address, geo, err)
+		}
+	}
+	if counting.calls != 1 {
+		t.Errorf("got %d calls, want the address to be geocoded once", counting.calls)
+	}
+
+	// the misses are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Geocode(ctx, "Jaffna"); !errors.Is(err, ErrGeocodeNoResults) {
+			t.Errorf("got %v, want no results", err)
+		}
+	}
+	if counting.calls != 3 {
+		t.Errorf("got %d calls, want every miss to be geocoded", counting.calls)
+	}
+	if _, err := cache.Geocode(ctx, " , "); !errors.Is(err, ErrGeocodeNoResults) || counting.calls != 3 {
+		t.Errorf("got %v after %d calls, want an empty address to have no results without geocoding", err, counting.calls)
+	}
+
+	// the least recently used address is evicted once the cache is full
+	cache.Geocode(ctx, "Galle Face")
+	cache.Geocode(ctx, "Colombo Fort")
+	cache.Geocode(ctx, "Kandy")
+	counting.calls = 0
+	cache.Geocode(ctx, "Colombo Fort")
+	if counting.calls != 0 {
+		t.Errorf("got %d calls, want the recently used address to be kept", counting.calls)
+	}
+	cache.Geocode(ctx, "Galle Face")
+	if counting.calls != 1 {
+		t.Errorf("got %d calls, want the least recently used address to be evicted", counting.calls)
+	}
+}
+
+func TestCachedGeocoderRedis(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	counting := &countingGeocoder{geocoder: NewStaticGeocoder(testAddresses)}
+	if _, err := NewCachedGeocoder(counting, 1).WithRedis(client, time.Hour).Geocode(ctx, "Kandy"); err != nil {
+		t.Fatal(err)
+	}
+
+	// another instance reads the address from redis
+	other := NewCachedGeocoder(counting, 1).WithRedis(client, time.Hour)
+	if geo, err := other.Geocode(ctx, "kandy"); err != nil || geo != testAddresses["Kandy"] || counting.calls != 1 {
+		t.Errorf("got %+v, %v after %d calls, want the address to be read from redis", geo, err, counting.calls)
+	}
+
+	// the address is geocoded again once it expires in redis
+	server.FastForward(2 * time.Hour)
+	if _, err := NewCachedGeocoder(counting, 1).WithRedis(client, time.Hour).Geocode(ctx, "Kandy"); err != nil || counting.calls != 2 {
+		t.Errorf("got %v after %d calls, want the expired address to be geocoded again", err, counting.calls)
+	}
+
+	// a broken cache entry is geocoded again
+	server.Set("geo:galle face", "not json")
+	if geo, err := other.Geocode(ctx, "Galle Face"); err != nil || geo != testAddresses["Galle Face"] || counting.calls != 3 {
+		t.Errorf("got %+v, %v after %d calls, want the broken entry to be geocoded again", geo, err, counting.calls)
+	}
+	if val, _ := client.Get(ctx, "geo:galle face").Result(); val == "not json" {
+		t.Error("want the broken entry to be replaced")
+	}
+}
@@ -0,0 +1,206 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// viewerBuffer is the number of messages that are kept for a viewer that has not read them yet
+	viewerBuffer = 64
+	// resubscribeDelay is the time to wait before subscribing to the location bus again after a failure
+	resubscribeDelay = time.Second
+)
+
+// ErrSlowViewer is an error that occurs when a viewer falls so far behind the messages of its slot that it
+// is removed from the hub
+var ErrSlowViewer = fmt.Errorf("the viewer can not keep up with the messages of the stream")
+
+// Viewer is a single viewer of a slot that receives the messages of the slot from the hub
+type Viewer struct {
+	messages chan Message
+	err      error
+	slot     partitions.Slot
+}
+
+// Messages is used to get the channel that the messages of the slot are sent to, the channel is closed when
+// the viewer is removed from the hub
+func (v *Viewer) Messages() <-chan Message {
+	return v.messages
+}
+
+// Err is used to get the reason that the viewer is removed from the hub, it must only be called after the
+// channel of the messages is closed
+func (v *Viewer) Err() error {
+	return v.err
+}
+
+// feed is the single subscription of a slot that is shared by every viewer of the slot
+type feed struct {
+	viewers map[*Viewer]struct{}
+	cancel  context.CancelFunc
+	// next is the offset of the next message of the slot, it is only used by the goroutine that consumes the slot
+	next int64
+}
+
+// Hub is used to share a single subscription of the location bus between every viewer of the same slot in this
+// server, the subscription of a slot starts with its first viewer and stops when its last viewer leaves
+type Hub struct {
+	bus   LocationBus
+	feeds map[partitions.Slot]*feed
+	mu    sync.Mutex
+}
+
+// NewHub is a function that is used to create a new hub on top of the given location bus
+func NewHub(bus LocationBus) *Hub {
+	return &Hub{
+		bus:   bus,
+		feeds: make(map[partitions.Slot]*feed),
+	}
+}
+
+// Join is used to add a new viewer to the given slot, the viewer receives the messages that are published to
+// the slot after it joins
+func (h *Hub) Join(ctx context.Context, slot partitions.Slot) (*Viewer, error) {
+	viewer := &Viewer{
+		messages: make(chan Message, viewerBuffer),
+		slot:     slot,
+	}
+
+	for {
+		h.mu.Lock()
+		if f, ok := h.feeds[slot]; ok {
+			f.viewers[viewer] = struct{}{}
+			h.mu.Unlock()
+			return viewer, nil
+		}
+		h.mu.Unlock()
+
+		// the first viewer starts the subscription at an explicit offset, since a subscription at the latest offset
+		// only starts in the background and the messages that are published meanwhile would never be sent
+		next, err := h.bus.LastOffset(ctx, slot)
+		if err != nil {
+			return nil, err
+		}
+
+		h.mu.Lock()
+		if _, ok := h.feeds[slot]; ok {
+			// another viewer has started the subscription meanwhile
+			h.mu.Unlock()
+			continue
+		}
+
+		feedCtx, cancel := context.WithCancel(context.Background())
+		f := &feed{
+			viewers: map[*Viewer]struct{}{viewer: {}},
+			cancel:  cancel,
+			next:    next,
+		}
+		h.feeds[slot] = f
+		h.mu.Unlock()
+
+		go h.consume(feedCtx, slot, f)
+
+		return viewer, nil
+	}
+}
+
+// Leave is used to remove the given viewer from its slot, leaving more than once does nothing
+func (h *Hub) Leave(viewer *Viewer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(viewer, nil)
+}
+
+// remove is used to remove the given viewer with the given reason while the hub is locked
+func (h *Hub) remove(viewer *Viewer, err error) {
+	f, ok := h.feeds[viewer.slot]
+	if !ok {
+		return
+	}
+	if _, ok := f.viewers[viewer]; !ok {
+		return
+	}
+
+	delete(f.viewers, viewer)
+	viewer.err = err
+	close(viewer.messages)
+
+	if len(f.viewers) == 0 {
+		f.cancel()
+		delete(h.feeds, viewer.slot)
+	}
+}
+
+// consume is used to read the given slot until the given context is done while sending every message to the
+// viewers of the slot, the subscription starts again right after the last message that is read when it fails
+func (h *Hub) consume(ctx context.Context, slot partitions.Slot, f *feed) {
+	for ctx.Err() == nil {
+		sub, err := h.bus.Subscribe(ctx, slot, f.next)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"slot : %s\tfailed to subscribe to the location bus",
+					slot,
+				)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		h.read(ctx, slot, f, sub)
+		sub.Close()
+	}
+}
+
+// read is used to read the given subscription until it fails or the given context is done
+func (h *Hub) read(ctx context.Context, slot partitions.Slot, f *feed, sub Subscription) {
+	for {
+		message, err := sub.Read(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Error().Err(err).
+					Msgf(
+						"slot : %s\tfailed to read from the location bus, subscribing again",
+						slot,
+					)
+			}
+			return
+		}
+		f.next = message.Offset + 1
+		if len(message.Value) == 0 {
+			continue
+		}
+
+		h.mu.Lock()
+		for viewer := range f.viewers {
+			select {
+			case viewer.messages <- message:
+			default:
+				// a slow viewer is removed instead of holding back the rest of the viewers
+				log.Warn().
+					Msgf(
+						"slot : %s\tremoving a viewer that can not keep up with the stream",
+						slot,
+					)
+				h.remove(viewer, ErrSlowViewer)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// InitHub is a function that is used to initialize the hub of the viewers on top of the location bus
+func (c *C) InitHub() {
+	c.Hub = NewHub(c.Bus)
+}
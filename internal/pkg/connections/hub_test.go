@@ -0,0 +1,118 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// flakyBus is a location bus that takes a while to subscribe and whose first subscription fails after its first
+// message, like a location bus over the network does
+type flakyBus struct {
+	*MemoryBus
+	subscriptions atomic.Int32
+}
+
+// Subscribe is used to consume the given slot starting from the given offset after a short delay
+func (b *flakyBus) Subscribe(ctx context.Context, slot partitions.Slot, offset int64) (Subscription, error) {
+	time.Sleep(20 * time.Millisecond)
+
+	sub, err := b.MemoryBus.Subscribe(ctx, slot, offset)
+	if err != nil {
+		return nil, err
+	}
+	if b.subscriptions.Add(1) > 1 {
+		return sub, nil
+	}
+
+	return &flakySubscription{Subscription: sub}, nil
+}
+
+// flakySubscription is a subscription that fails after its first message
+type flakySubscription struct {
+	Subscription
+	read bool
+}
+
+// Read is used to read the next message, every message after the first one fails
+func (s *flakySubscription) Read(ctx context.Context) (Message, error) {
+	if s.read {
+		// the messages that are published while the subscription is down are read once it subscribes again
+		time.Sleep(20 * time.Millisecond)
+		return Message{}, errors.New("connection reset")
+	}
+	s.read = true
+
+	return s.Subscription.Read(ctx)
+}
+
+// receive is used to read the given number of messages of the given viewer
+func receive(t *testing.T, viewer *Viewer, count int) []string {
+	t.Helper()
+
+	values := []string{}
+	for len(values) < count {
+		select {
+		case message, ok := <-viewer.Messages():
+			if !ok {
+				t.Fatalf("the viewer is removed: %v", viewer.Err())
+			}
+			values = append(values, string(message.Value))
+		case <-time.After(3 * time.Second):
+			t.Fatalf("got %v, want %d messages", values, count)
+		}
+	}
+
+	return values
+}
+
+func TestHubJoin(t *testing.T) {
+	ctx := context.Background()
+	bus := &flakyBus{MemoryBus: NewMemoryBus(1)}
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	publish(t, bus, slot, "before")
+
+	hub := NewHub(bus)
+	viewer, err := hub.Join(ctx, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Leave(viewer)
+
+	// the messages that are published while the hub is still subscribing are not lost, neither are the messages
+	// that are published while it subscribes again after a failure
+	publish(t, bus, slot, "a")
+	time.Sleep(30 * time.Millisecond)
+	publish(t, bus, slot, "b", "c")
+
+	got := receive(t, viewer, 3)
+	if got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("got %v, want the messages a, b and c once", got)
+	}
+	if bus.subscriptions.Load() < 2 {
+		t.Errorf("got %d subscriptions, want the hub to subscribe again", bus.subscriptions.Load())
+	}
+
+	// a viewer that joins the running subscription receives the messages that are published after it joins
+	other, err := hub.Join(ctx, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, bus, slot, "d")
+	if got := receive(t, other, 1); got[0] != "d" {
+		t.Errorf("got %v, want the message d", got)
+	}
+	if got := receive(t, viewer, 1); got[0] != "d" {
+		t.Errorf("got %v, want the message d", got)
+	}
+
+	// the messages channel of a viewer that leaves is closed
+	hub.Leave(other)
+	if _, ok := <-other.Messages(); ok {
+		t.Error("got a message, want the channel to be closed")
+	}
+}
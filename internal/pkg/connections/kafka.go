@@ -5,11 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
-	"github.com/rs/zerolog/log"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/scram"
@@ -23,11 +23,6 @@ func getMechanism(e *env.Env) sasl.Mechanism {
 	return mechanism
 }
 
-// KafkaWriters contains kafka writers
-type KafkaWriters struct {
-	B *kafka.Writer
-}
-
 func writer(e *env.Env, topic string) *kafka.Writer {
 	w := kafka.Writer{
 		Addr:  kafka.TCP(e.KafkaBroker),
@@ -48,13 +43,6 @@ func getDialer(e *env.Env) *kafka.Dialer {
 	}
 }
 
-// InitKafkaWriters is a function that is used to initialize kafka writers
-func (c *C) InitKafkaWriters(e *env.Env) {
-	c.K = &KafkaWriters{
-		B: writer(e, e.Topic),
-	}
-}
-
 // GetKafkaConnection is a function that is used to initialize the kafka connection
 func (c *C) GetKafkaConnection(e *env.Env) (*kafka.Conn, error) {
 	conn, err := getDialer(e).Dial("tcp", e.KafkaBroker)
@@ -70,18 +58,6 @@ func (c *C) GetKafkaLeaderConnection(ctx context.Context, e *env.Env, topic stri
 	return conn, err
 }
 
-// GetLastOffset is a function that is used to get the last offset of a given partition in a given topic
-func (c *C) GetLastOffset(ctx context.Context, e *env.Env, topic string, partition int) (offset int64, err error) {
-	conn, err := c.GetKafkaLeaderConnection(ctx, e, topic, partition)
-	if err != nil {
-		return -1, err
-	}
-	defer conn.Close()
-
-	offset, err = conn.ReadLastOffset()
-	return offset, err
-}
-
 // KafkaWriteToTopic is a function that is used to write to a given Kafka topic
 func (c *C) KafkaWriteToTopic(e *env.Env, topic string, payload []kafka.Message) {
 	w := kafka.Writer{
@@ -122,27 +98,116 @@ func (c *C) KafkaWriteToTopicWithHTTP(e *env.Env, topic string, payload string)
 	return nil
 }
 
-// GetLastNMessages is a function that is used to get the last N messages from the kafka topic within a given kafka partition
-func (c *C) GetLastNMessages(ctx context.Context, e *env.Env, from, to int64, topic string, partition int) ([]any, error) {
-	messages := []any{}
+// writeBatchTimeout is the time that a write waits for more messages before the messages are sent to the broker
+const writeBatchTimeout = 5 * time.Millisecond
 
+// Kafka is the location bus that is backed by a kafka topic
+type Kafka struct {
+	e       *env.Env
+	writers map[partitions.Slot]*kafka.Writer
+	mu      sync.Mutex
+}
+
+// NewKafka is a function that is used to create a location bus on top of the kafka broker
+func NewKafka(e *env.Env) *Kafka {
+	return &Kafka{
+		e:       e,
+		writers: make(map[partitions.Slot]*kafka.Writer),
+	}
+}
+
+// writer is used to get the writer of the given slot, writers are created lazily and kept
+// for the lifetime of the bus so that the balancer of a shared writer never has to be mutated, the writes of the
+// writer return once the broker acknowledges them
+func (k *Kafka) writer(slot partitions.Slot) *kafka.Writer {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	w, ok := k.writers[slot]
+	if ok {
+		return w
+	}
+
+	w = writer(k.e, slot.Topic)
+	w.Balancer = kafka.BalancerFunc(func(m kafka.Message, i ...int) int {
+		return slot.Partition
+	})
+	// the writes wait for the broker to acknowledge them, so that a location that fails to be published is reported
+	// to the caller instead of being dropped, a batch is sent right away instead of waiting for more messages
+	w.RequiredAcks = kafka.RequireAll
+	w.BatchTimeout = writeBatchTimeout
+
+	k.writers[slot] = w
+	return w
+}
+
+// Publish is used to publish the given value to the given slot
+func (k *Kafka) Publish(ctx context.Context, slot partitions.Slot, key, value []byte) error {
+	return k.writer(slot).WriteMessages(ctx, kafka.Message{
+		Key:   key,
+		Value: value,
+	})
+}
+
+// PublishBatch is used to publish the given values to the given slot in the given order
+func (k *Kafka) PublishBatch(ctx context.Context, slot partitions.Slot, key []byte, values [][]byte) error {
+	messages := make([]kafka.Message, len(values))
+	for i, value := range values {
+		messages[i] = kafka.Message{
+			Key:   key,
+			Value: value,
+		}
+	}
+
+	return k.writer(slot).WriteMessages(ctx, messages...)
+}
+
+// reader is used to intitialize a kafka reader instance
+func (k *Kafka) reader(slot partitions.Slot, offset int64) (*kafka.Reader, error) {
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{e.KafkaBroker},
-		Topic:   e.Topic,
-		Dialer: &kafka.Dialer{
-			SASLMechanism: getMechanism(e),
-			TLS:           &tls.Config{},
-		},
-		Partition: partition,
+		Brokers:   []string{k.e.KafkaBroker},
+		Topic:     slot.Topic,
+		Dialer:    getDialer(k.e),
+		Partition: slot.Partition,
 	})
-	defer reader.Close()
 
-	err := reader.SetOffset(from)
+	err := reader.SetOffset(offset)
 	if err != nil {
-		return messages, err
+		reader.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// Subscribe is used to consume the given slot starting from the given offset
+func (k *Kafka) Subscribe(_ context.Context, slot partitions.Slot, offset int64) (Subscription, error) {
+	if offset == LatestOffset {
+		offset = kafka.LastOffset
+	}
+
+	reader, err := k.reader(slot, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaSubscription{
+		reader: reader,
+	}, nil
+}
+
+// ReadRange is used to read all the messages of the given slot in the range [from, to)
+func (k *Kafka) ReadRange(ctx context.Context, slot partitions.Slot, from, to int64) ([]Message, error) {
+	messages := []Message{}
+	if from >= to {
+		return messages, nil
 	}
 
-	var payload any
+	reader, err := k.reader(slot, from)
+	if err != nil {
+		return messages, err
+	}
+	defer reader.Close()
 
 	for {
 		m, err := reader.ReadMessage(ctx)
@@ -150,18 +215,9 @@ func (c *C) GetLastNMessages(ctx context.Context, e *env.Env, from, to int64, to
 			return messages, err
 		}
 
-		err = sonic.Unmarshal(m.Value, &payload)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("value", string(m.Value)).
-				Msg("failed to unmarshal the payload receiving from kafka")
-			continue
-		}
-
-		messages = append(messages, payload)
+		messages = append(messages, toMessage(m))
 
-		if m.Offset == to-1 {
+		if m.Offset >= to-1 {
 			break
 		}
 	}
@@ -169,18 +225,74 @@ func (c *C) GetLastNMessages(ctx context.Context, e *env.Env, from, to int64, to
 	return messages, nil
 }
 
-// KafkaReader is a function that is used to intitialize a kafka reader instance
-func (c *C) KafkaReader(e *env.Env, topic string, partition int, offset int64) *kafka.Reader {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{e.KafkaBroker},
-		Topic:   topic,
-		Dialer: &kafka.Dialer{
-			SASLMechanism: getMechanism(e),
-			TLS:           &tls.Config{},
-		},
-		Partition: partition,
-	})
-	reader.SetOffset(offset)
+// LastOffset is used to get the offset of the next message that will be published to the given slot
+func (k *Kafka) LastOffset(ctx context.Context, slot partitions.Slot) (int64, error) {
+	conn, err := getDialer(k.e).DialLeader(ctx, "tcp", k.e.KafkaBroker, slot.Topic, slot.Partition)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	return conn.ReadLastOffset()
+}
+
+// TopicPartitions is used to get the number of partitions of the given topic
+func (k *Kafka) TopicPartitions(ctx context.Context, topic string) (int, error) {
+	conn, err := getDialer(k.e).DialContext(ctx, "tcp", k.e.KafkaBroker)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
 
-	return reader
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return 0, err
+	}
+	if len(partitions) == 0 {
+		return 0, ErrUnknownTopic
+	}
+
+	return len(partitions), nil
+}
+
+// Close is used to flush and close all the writers
+func (k *Kafka) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var err error
+	for slot, w := range k.writers {
+		if e := w.Close(); e != nil {
+			err = e
+		}
+		delete(k.writers, slot)
+	}
+
+	return err
+}
+
+type kafkaSubscription struct {
+	reader *kafka.Reader
+}
+
+func (s *kafkaSubscription) Read(ctx context.Context) (Message, error) {
+	m, err := s.reader.ReadMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return toMessage(m), nil
+}
+
+func (s *kafkaSubscription) Close() error {
+	return s.reader.Close()
+}
+
+func toMessage(m kafka.Message) Message {
+	return Message{
+		Time:   m.Time,
+		Key:    m.Key,
+		Value:  m.Value,
+		Offset: m.Offset,
+	}
 }
@@ -0,0 +1,98 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalArchive is the archive store that keeps the archives as files in a directory of the local filesystem
+type LocalArchive struct {
+	dir string
+}
+
+// NewLocalArchive is a function that is used to create a new local filesystem archive store
+func NewLocalArchive(dir string) (*LocalArchive, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalArchive{
+		dir: dir,
+	}, nil
+}
+
+// path is used to get the path of the archive under the given name
+func (l *LocalArchive) path(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%s is not a valid archive name", name)
+	}
+
+	return filepath.Join(l.dir, name), nil
+}
+
+// Put is used to save the given data under the given name, replacing any existing archive
+func (l *LocalArchive) Put(_ context.Context, name string, data []byte) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that readers never see a partially written archive
+	tmp, err := os.CreateTemp(l.dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get is used to read the archive under the given name
+func (l *LocalArchive) Get(_ context.Context, name string) ([]byte, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrArchiveNotFound
+	}
+
+	return data, err
+}
+
+// Delete is used to remove the archive under the given name
+func (l *LocalArchive) Delete(_ context.Context, name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrArchiveNotFound
+	}
+
+	return err
+}
+
+// Close is used to close the archive store
+func (l *LocalArchive) Close() error {
+	return nil
+}
@@ -1,17 +1,154 @@
 package connections
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
-	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
 	"googlemaps.github.io/maps"
 )
 
-// InitMap is a function that is used to initialize the Google maps connections
-func (c *C) InitMap(e *env.Env) {
-	client, err := maps.NewClient(maps.WithAPIKey(e.GoogleMapsAPIKey))
+// googleMaps is used to share the lazily initialized Google maps client between the Google maps backends
+type googleMaps struct {
+	client *maps.Client
+	apiKey string
+	mu     sync.Mutex
+}
+
+// maps is used to get the Google maps client while initializing it if required
+func (g *googleMaps) maps() (*maps.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client == nil {
+		client, err := maps.NewClient(maps.WithAPIKey(g.apiKey))
+		if err != nil {
+			return nil, err
+		}
+
+		g.client = client
+	}
+
+	return g.client, nil
+}
+
+// GoogleGeocoder is the geocoder that is backed by the Google maps geocoding API
+type GoogleGeocoder struct {
+	googleMaps
+}
+
+// NewGoogleGeocoder is a function that is used to create a new Google maps geocoder
+//
+// NOTE: The maps client is initialized on the first use, not doing so will effect the
+// startup time of the container
+func NewGoogleGeocoder(e *env.Env) *GoogleGeocoder {
+	return &GoogleGeocoder{
+		googleMaps: googleMaps{
+			apiKey: e.GoogleMapsAPIKey,
+		},
+	}
+}
+
+// Geocode is used to get the coordinates of the given address
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) (Geo, error) {
+	client, err := g.maps()
+	if err != nil {
+		return Geo{}, err
+	}
+
+	route, err := client.Geocode(ctx, &maps.GeocodingRequest{
+		Address: address,
+	})
 	if err != nil {
-		lib.LogFatal(err)
+		return Geo{}, err
+	}
+	if len(route) == 0 {
+		return Geo{}, ErrGeocodeNoResults
+	}
+
+	return Geo{
+		Lat: route[0].Geometry.Location.Lat,
+		Lon: route[0].Geometry.Location.Lng,
+	}, nil
+}
+
+// GoogleRouter is the route estimator that is backed by the Google maps directions API
+type GoogleRouter struct {
+	googleMaps
+}
+
+// NewGoogleRouter is a function that is used to create a new Google maps route estimator
+//
+// NOTE: The maps client is initialized on the first use
+func NewGoogleRouter(e *env.Env) *GoogleRouter {
+	return &GoogleRouter{
+		googleMaps: googleMaps{
+			apiKey: e.GoogleMapsAPIKey,
+		},
+	}
+}
+
+// Estimate is used to get the driving distance and the time in traffic between the given coordinates
+func (g *GoogleRouter) Estimate(ctx context.Context, from, to Geo) (Estimate, error) {
+	client, err := g.maps()
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	routes, _, err := client.Directions(ctx, &maps.DirectionsRequest{
+		Origin:        fmt.Sprintf("%f,%f", from.Lat, from.Lon),
+		Destination:   fmt.Sprintf("%f,%f", to.Lat, to.Lon),
+		Mode:          maps.TravelModeDriving,
+		DepartureTime: "now",
+	})
+	if err != nil {
+		return Estimate{}, err
+	}
+	if len(routes) == 0 || len(routes[0].Legs) == 0 {
+		return Estimate{}, ErrNoRoute
+	}
+
+	leg := routes[0].Legs[0]
+	duration := leg.DurationInTraffic
+	if duration == 0 {
+		duration = leg.Duration
+	}
+
+	return Estimate{
+		Distance: float64(leg.Meters),
+		Duration: duration,
+	}, nil
+}
+
+// Route is used to get the driving route through the given coordinates, the overview of the route is used as the path
+func (g *GoogleRouter) Route(ctx context.Context, stops []Geo) ([]Geo, error) {
+	if len(stops) < 2 {
+		return nil, ErrNoRoute
+	}
+
+	client, err := g.maps()
+	if err != nil {
+		return nil, err
+	}
+
+	waypoints := make([]string, 0, len(stops)-2)
+	for _, stop := range stops[1 : len(stops)-1] {
+		waypoints = append(waypoints, fmt.Sprintf("%f,%f", stop.Lat, stop.Lon))
+	}
+
+	routes, _, err := client.Directions(ctx, &maps.DirectionsRequest{
+		Origin:      fmt.Sprintf("%f,%f", stops[0].Lat, stops[0].Lon),
+		Destination: fmt.Sprintf("%f,%f", stops[len(stops)-1].Lat, stops[len(stops)-1].Lon),
+		Waypoints:   waypoints,
+		Mode:        maps.TravelModeDriving,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, ErrNoRoute
 	}
 
-	c.M = client
+	return DecodeRoute(routes[0].OverviewPolyline.Points)
 }
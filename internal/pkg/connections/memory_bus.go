@@ -0,0 +1,205 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// ErrBusClosed is an error that occurs when the location bus is used after it is closed
+var ErrBusClosed = fmt.Errorf("the location bus is closed")
+
+type memoryPartition struct {
+	// notify is closed and replaced everytime a message is appended to the slot
+	notify   chan struct{}
+	messages []Message
+}
+
+// MemoryBus is an in process partitioned log that is used to run the service without a kafka broker
+//
+// Messages are never evicted, so it is only meant to be used for local development and tests
+type MemoryBus struct {
+	partitions map[partitions.Slot]*memoryPartition
+	closed     chan struct{}
+	// count is the number of partitions of every topic
+	count int
+	mu    sync.RWMutex
+	once  sync.Once
+}
+
+// NewMemoryBus is a function that is used to create a new in process location bus where every
+// topic has count partitions
+func NewMemoryBus(count int) *MemoryBus {
+	return &MemoryBus{
+		partitions: make(map[partitions.Slot]*memoryPartition),
+		closed:     make(chan struct{}),
+		count:      count,
+	}
+}
+
+// partition is used to get the given slot while creating it if it does not exist
+// NOTE: the caller must hold the write lock
+func (b *MemoryBus) partition(slot partitions.Slot) *memoryPartition {
+	p, ok := b.partitions[slot]
+	if !ok {
+		p = &memoryPartition{
+			notify: make(chan struct{}),
+		}
+		b.partitions[slot] = p
+	}
+
+	return p
+}
+
+// Publish is used to publish the given value to the given slot
+func (b *MemoryBus) Publish(ctx context.Context, slot partitions.Slot, key, value []byte) error {
+	return b.PublishBatch(ctx, slot, key, [][]byte{value})
+}
+
+// PublishBatch is used to publish the given values to the given slot in the given order
+func (b *MemoryBus) PublishBatch(_ context.Context, slot partitions.Slot, key []byte, values [][]byte) error {
+	select {
+	case <-b.closed:
+		return ErrBusClosed
+	default:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.partition(slot)
+	for _, value := range values {
+		p.messages = append(p.messages, Message{
+			Time:   time.Now().UTC(),
+			Key:    append([]byte(nil), key...),
+			Value:  append([]byte(nil), value...),
+			Offset: int64(len(p.messages)),
+		})
+	}
+
+	close(p.notify)
+	p.notify = make(chan struct{})
+
+	return nil
+}
+
+// next is used to get the message in the given offset, when the message is not published yet
+// the returned channel is closed as soon as a new message is published to the slot
+func (b *MemoryBus) next(slot partitions.Slot, offset int64) (Message, bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.partition(slot)
+	if offset >= 0 && offset < int64(len(p.messages)) {
+		return p.messages[offset], true, nil
+	}
+
+	return Message{}, false, p.notify
+}
+
+// Subscribe is used to consume the given slot starting from the given offset
+func (b *MemoryBus) Subscribe(ctx context.Context, slot partitions.Slot, offset int64) (Subscription, error) {
+	if offset == LatestOffset {
+		last, err := b.LastOffset(ctx, slot)
+		if err != nil {
+			return nil, err
+		}
+		offset = last
+	}
+
+	return &memorySubscription{
+		bus:    b,
+		slot:   slot,
+		offset: offset,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// ReadRange is used to read all the messages of the given slot in the range [from, to)
+func (b *MemoryBus) ReadRange(_ context.Context, slot partitions.Slot, from, to int64) ([]Message, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	messages := []Message{}
+
+	p, ok := b.partitions[slot]
+	if !ok {
+		return messages, nil
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > int64(len(p.messages)) {
+		to = int64(len(p.messages))
+	}
+	if from >= to {
+		return messages, nil
+	}
+
+	return append(messages, p.messages[from:to]...), nil
+}
+
+// LastOffset is used to get the offset of the next message that will be published to the given slot
+func (b *MemoryBus) LastOffset(_ context.Context, slot partitions.Slot) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	p, ok := b.partitions[slot]
+	if !ok {
+		return 0, nil
+	}
+
+	return int64(len(p.messages)), nil
+}
+
+// TopicPartitions is used to get the number of partitions of the given topic, every topic exists in
+// the in process bus
+func (b *MemoryBus) TopicPartitions(_ context.Context, _ string) (int, error) {
+	return b.count, nil
+}
+
+// Close is used to wake up all the subscribers and reject any further messages
+func (b *MemoryBus) Close() error {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+	return nil
+}
+
+type memorySubscription struct {
+	bus    *MemoryBus
+	closed chan struct{}
+	once   sync.Once
+	slot   partitions.Slot
+	offset int64
+}
+
+func (s *memorySubscription) Read(ctx context.Context) (Message, error) {
+	for {
+		message, ok, notify := s.bus.next(s.slot, s.offset)
+		if ok {
+			s.offset++
+			return message, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-s.closed:
+			return Message{}, ErrBusClosed
+		case <-s.bus.closed:
+			return Message{}, ErrBusClosed
+		case <-notify:
+		}
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+	})
+	return nil
+}
@@ -0,0 +1,127 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+func publish(t *testing.T, bus LocationBus, slot partitions.Slot, values ...string) {
+	t.Helper()
+
+	batch := make([][]byte, len(values))
+	for i, value := range values {
+		batch[i] = []byte(value)
+	}
+	if err := bus.PublishBatch(context.Background(), slot, []byte("key"), batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryBusOffsets(t *testing.T) {
+	ctx := context.Background()
+	bus := NewMemoryBus(2)
+	slot := partitions.Slot{Topic: "locations", Partition: 1}
+
+	last, err := bus.LastOffset(ctx, slot)
+	if err != nil || last != 0 {
+		t.Fatalf("got %d, %v, want the first offset to be 0", last, err)
+	}
+
+	publish(t, bus, slot, "a", "b", "c")
+	publish(t, bus, partitions.Slot{Topic: "locations", Partition: 0}, "other")
+
+	// the last offset is the offset of the next message
+	if last, _ = bus.LastOffset(ctx, slot); last != 3 {
+		t.Errorf("got %d, want 3", last)
+	}
+
+	messages, err := bus.ReadRange(ctx, slot, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || string(messages[0].Value) != "b" || messages[0].Offset != 1 || string(messages[1].Value) != "c" {
+		t.Errorf("got %v, want the messages b and c", messages)
+	}
+
+	if messages, _ = bus.ReadRange(ctx, slot, 3, 3); len(messages) != 0 {
+		t.Errorf("got %v, want an empty range", messages)
+	}
+	if count, _ := bus.TopicPartitions(ctx, "anything"); count != 2 {
+		t.Errorf("got %d partitions, want 2", count)
+	}
+}
+
+func TestMemoryBusSubscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	bus := NewMemoryBus(1)
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+	publish(t, bus, slot, "old")
+
+	// a subscription from the start reads the published messages first
+	from, err := bus.Subscribe(ctx, slot, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer from.Close()
+
+	latest, err := bus.Subscribe(ctx, slot, LatestOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer latest.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = bus.Publish(ctx, slot, nil, []byte("new"))
+	}()
+
+	for _, want := range []string{"old", "new"} {
+		message, err := from.Read(ctx)
+		if err != nil || string(message.Value) != want {
+			t.Fatalf("got %q, %v, want %q", message.Value, err, want)
+		}
+	}
+
+	// a subscription at the latest offset only reads the messages that are published after it
+	message, err := latest.Read(ctx)
+	if err != nil || string(message.Value) != "new" || message.Offset != 1 {
+		t.Fatalf("got %q at %d, %v, want new at 1", message.Value, message.Offset, err)
+	}
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	ctx := context.Background()
+	bus := NewMemoryBus(1)
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+
+	sub, err := bus.Subscribe(ctx, slot, LatestOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := sub.Read(ctx)
+		done <- err
+	}()
+
+	bus.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrBusClosed) {
+			t.Errorf("got %v, want ErrBusClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the subscription is not woken up when the bus is closed")
+	}
+
+	if err := bus.Publish(ctx, slot, nil, []byte("late")); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("got %v, want ErrBusClosed", err)
+	}
+}
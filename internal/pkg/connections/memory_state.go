@@ -0,0 +1,635 @@
+package connections
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+type memoryEntry struct {
+	expires time.Time
+	value   string
+}
+
+func (m memoryEntry) expired(now time.Time) bool {
+	return !m.expires.IsZero() && !now.Before(m.expires)
+}
+
+// MemoryState is an in process state store that is used to run the service without a Redis server
+type MemoryState struct {
+	entries    map[string]memoryEntry
+	partitions map[partitions.Slot]struct{}
+	// locations contains the recorded location indexes of every slot
+	locations map[partitions.Slot]map[int]struct{}
+	topics    map[string]int
+	// queue contains the IDs of the waiting reservations in the order that they are added
+	queue        []string
+	reservations map[string]partitions.Reservation
+	// slotLocks contains a channel with a single buffered value for every slot, the lock of the slot is held
+	// while the value is in the channel
+	slotLocks map[partitions.Slot]chan struct{}
+	mu        sync.Mutex
+}
+
+// NewMemoryState is a function that is used to create a new in memory state store
+func NewMemoryState() *MemoryState {
+	return &MemoryState{
+		entries:      make(map[string]memoryEntry),
+		partitions:   make(map[partitions.Slot]struct{}),
+		locations:    make(map[partitions.Slot]map[int]struct{}),
+		topics:       make(map[string]int),
+		reservations: make(map[string]partitions.Reservation),
+		slotLocks:    make(map[partitions.Slot]chan struct{}),
+	}
+}
+
+// load is used to get the entry under the given key, expired entries are removed while loading
+// NOTE: the caller must hold the lock
+func (m *MemoryState) load(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if entry.expired(time.Now()) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+
+	return entry, true
+}
+
+// store is used to set the value of the given key, a ttl that is smaller than or equal to zero
+// keeps the key forever
+// NOTE: the caller must hold the lock
+func (m *MemoryState) store(key, value string, ttl time.Duration) {
+	entry := memoryEntry{
+		value: value,
+	}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	m.entries[key] = entry
+}
+
+// storeNX is used to set the value of the given key only if the key does not exist
+// NOTE: the caller must hold the lock
+func (m *MemoryState) storeNX(key, value string, ttl time.Duration) bool {
+	if _, ok := m.load(key); ok {
+		return false
+	}
+
+	m.store(key, value, ttl)
+	return true
+}
+
+func (m *MemoryState) get(key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, _ := m.load(key)
+	return entry.value
+}
+
+func (m *MemoryState) del(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+}
+
+// GetBooking is used to get the booking details under the given booking ID
+func (m *MemoryState) GetBooking(_ context.Context, bookingID string) (string, error) {
+	return m.get(bookingID), nil
+}
+
+// GetDriver is used to get the driver details under the given driver ID
+func (m *MemoryState) GetDriver(_ context.Context, driverID int) (string, error) {
+	return m.get(driverKey(driverID)), nil
+}
+
+// SetDriver is used to replace the driver details under the given driver ID
+func (m *MemoryState) SetDriver(_ context.Context, driverID int, payload string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(driverKey(driverID), payload, ttl)
+	return nil
+}
+
+// DriverTTL is used to get the remaining time to live of the driver details
+func (m *MemoryState) DriverTTL(_ context.Context, driverID int) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.load(driverKey(driverID))
+	if !ok {
+		return -2, nil
+	}
+	if entry.expires.IsZero() {
+		return -1, nil
+	}
+
+	return time.Until(entry.expires), nil
+}
+
+// GetPartition is used to get the backup (n-) details of the booking in the given slot
+func (m *MemoryState) GetPartition(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(nKey(slot)), nil
+}
+
+// ClearPartition is used to remove the backup, last location, location indexes, filter, track, geofence, deviation, job and signal state and the viewer count of the given slot
+func (m *MemoryState) ClearPartition(_ context.Context, slot partitions.Slot) error {
+	m.del(nKey(slot), lKey(slot), fKey(slot), tKey(slot), gKey(slot), dKey(slot), sKey(slot), uKey(slot), oKey(slot), cKey(slot))
+	m.unmark(slot)
+	return nil
+}
+
+// GetLastLocation is used to get the last known location of the given slot
+func (m *MemoryState) GetLastLocation(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(lKey(slot)), nil
+}
+
+// SetLastLocation is used to update the last known location while keeping the existing ttl
+func (m *MemoryState) SetLastLocation(_ context.Context, slot partitions.Slot, payload string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := lKey(slot)
+	entry, _ := m.load(key)
+	entry.value = payload
+	m.entries[key] = entry
+
+	return nil
+}
+
+// AdvanceLastLocation is used to update the last known location only when the given location index is
+// greater than the location index of the current last known location
+func (m *MemoryState) AdvanceLastLocation(_ context.Context, slot partitions.Slot, index int, payload string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := lKey(slot)
+	entry, ok := m.load(key)
+	if ok {
+		var current lastLocation
+		err := sonic.UnmarshalString(entry.value, &current)
+		if err == nil && current.LocationIndex != nil && *current.LocationIndex >= index {
+			return false, nil
+		}
+	}
+
+	entry.value = payload
+	m.entries[key] = entry
+
+	return true, nil
+}
+
+// MarkLocations is used to record the given location indexes of the given slot
+func (m *MemoryState) MarkLocations(_ context.Context, slot partitions.Slot, indexes []int) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	marked, ok := m.locations[slot]
+	if !ok {
+		marked = make(map[int]struct{})
+		m.locations[slot] = marked
+	}
+
+	fresh := make([]bool, len(indexes))
+	for i, index := range indexes {
+		if _, ok := marked[index]; ok {
+			continue
+		}
+
+		marked[index] = struct{}{}
+		fresh[i] = true
+	}
+
+	return fresh, nil
+}
+
+// UnmarkLocations is used to forget the given location indexes of the given slot
+func (m *MemoryState) UnmarkLocations(_ context.Context, slot partitions.Slot, indexes []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, index := range indexes {
+		delete(m.locations[slot], index)
+	}
+
+	return nil
+}
+
+// unmark is used to forget every location index of the given slot
+func (m *MemoryState) unmark(slot partitions.Slot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.locations, slot)
+}
+
+// GetFilter is used to get the state of the location filter of the given slot
+func (m *MemoryState) GetFilter(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(fKey(slot)), nil
+}
+
+// SetFilter is used to replace the state of the location filter of the given slot
+func (m *MemoryState) SetFilter(_ context.Context, slot partitions.Slot, payload string) error {
+	m.storeAlong(fKey(slot), slot, payload)
+	return nil
+}
+
+// GetTrack is used to get the state of the track of the given slot
+func (m *MemoryState) GetTrack(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(tKey(slot)), nil
+}
+
+// SetTrack is used to replace the state of the track of the given slot
+func (m *MemoryState) SetTrack(_ context.Context, slot partitions.Slot, payload string) error {
+	m.storeAlong(tKey(slot), slot, payload)
+	return nil
+}
+
+// GetGeofence is used to get the state of the geofences of the given slot
+func (m *MemoryState) GetGeofence(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(gKey(slot)), nil
+}
+
+// SetGeofence is used to replace the state of the geofences of the given slot
+func (m *MemoryState) SetGeofence(_ context.Context, slot partitions.Slot, payload string) error {
+	m.storeAlong(gKey(slot), slot, payload)
+	return nil
+}
+
+// GetDeviation is used to get the state of the route deviation of the given slot
+func (m *MemoryState) GetDeviation(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(dKey(slot)), nil
+}
+
+// SetDeviation is used to replace the state of the route deviation of the given slot
+func (m *MemoryState) SetDeviation(_ context.Context, slot partitions.Slot, payload string) error {
+	m.storeAlong(dKey(slot), slot, payload)
+	return nil
+}
+
+// GetLastUpdate is used to get the time that the driver of the given slot last sent a location
+func (m *MemoryState) GetLastUpdate(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(uKey(slot)), nil
+}
+
+// SetLastUpdate is used to record the time that the driver of the given slot sent a location
+func (m *MemoryState) SetLastUpdate(_ context.Context, slot partitions.Slot, at int64) error {
+	m.storeAlong(uKey(slot), slot, strconv.FormatInt(at, 10))
+	return nil
+}
+
+// SwapSignal is used to replace the signal status of the given slot while getting the previous signal status
+func (m *MemoryState) SwapSignal(_ context.Context, slot partitions.Slot, status string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, _ := m.load(oKey(slot))
+	location, ok := m.load(lKey(slot))
+	if !ok {
+		return previous.value, nil
+	}
+
+	m.entries[oKey(slot)] = memoryEntry{
+		value:   status,
+		expires: location.expires,
+	}
+	return previous.value, nil
+}
+
+// GetJobState is used to get the job status and the timeline of the given slot
+func (m *MemoryState) GetJobState(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(sKey(slot)), nil
+}
+
+// SetJobState is used to replace the job status and the timeline of the given slot
+func (m *MemoryState) SetJobState(_ context.Context, slot partitions.Slot, payload string) error {
+	m.storeAlong(sKey(slot), slot, payload)
+	return nil
+}
+
+// storeAlong is used to set the value of the given key so that it expires along with the last known location
+// of the given slot
+func (m *MemoryState) storeAlong(key string, slot partitions.Slot, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	location, _ := m.load(lKey(slot))
+	m.entries[key] = memoryEntry{
+		value:   value,
+		expires: location.expires,
+	}
+}
+
+// GetViewers is used to get the number of viewers connected to the given slot
+func (m *MemoryState) GetViewers(_ context.Context, slot partitions.Slot) (string, error) {
+	return m.get(cKey(slot)), nil
+}
+
+// IncrViewers is used to increment the number of viewers connected to the given slot
+func (m *MemoryState) IncrViewers(_ context.Context, slot partitions.Slot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := cKey(slot)
+	entry, _ := m.load(key)
+	viewers, err := strconv.Atoi(entry.value)
+	if err != nil && entry.value != "" {
+		return err
+	}
+	entry.value = strconv.Itoa(viewers + 1)
+	m.entries[key] = entry
+
+	return nil
+}
+
+// DecrViewers is used to decrement the number of viewers connected to the given slot
+// without letting it go below zero
+func (m *MemoryState) DecrViewers(_ context.Context, slot partitions.Slot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := cKey(slot)
+	entry, ok := m.load(key)
+	if !ok {
+		return nil
+	}
+	viewers, err := strconv.Atoi(entry.value)
+	if err != nil || viewers <= 0 {
+		viewers = 1
+	}
+	entry.value = strconv.Itoa(viewers - 1)
+	m.entries[key] = entry
+
+	return nil
+}
+
+// CreateBooking is used to store all the keys that are related to a newly created booking
+func (m *MemoryState) CreateBooking(_ context.Context, state BookingState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.storeNX(driverKey(state.DriverID), state.Driver, state.TTL)
+	m.storeNX(state.BookingID, state.Booking, state.TTL)
+	m.storeNX(lKey(state.Slot), state.Location, state.TTL)
+	m.storeNX(gKey(state.Slot), state.Geofence, state.TTL)
+	m.storeNX(dKey(state.Slot), state.Deviation, state.TTL)
+	m.storeNX(sKey(state.Slot), state.Job, state.TTL)
+	m.storeNX(uKey(state.Slot), strconv.FormatInt(state.LastUpdate, 10), state.TTL)
+	m.storeNX(cKey(state.Slot), "0", state.TTL)
+	m.storeNX(nKey(state.Slot), state.Backup, state.BackupTTL)
+
+	return nil
+}
+
+// DelBooking is used to remove a booking and all its related components
+func (m *MemoryState) DelBooking(_ context.Context, driverID int, bookingID string, slot partitions.Slot) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.load(bookingID)
+	for _, key := range []string{
+		driverKey(driverID),
+		bookingID,
+		lKey(slot),
+		fKey(slot),
+		tKey(slot),
+		gKey(slot),
+		dKey(slot),
+		sKey(slot),
+		uKey(slot),
+		oKey(slot),
+		cKey(slot),
+		nKey(slot),
+	} {
+		delete(m.entries, key)
+	}
+	delete(m.locations, slot)
+
+	return ok, nil
+}
+
+// Partitions is used to get all the slots that are currently in use
+func (m *MemoryState) Partitions(_ context.Context) ([]partitions.Slot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slots := make([]partitions.Slot, 0, len(m.partitions))
+	for slot := range m.partitions {
+		slots = append(slots, slot)
+	}
+
+	return slots, nil
+}
+
+// ClaimPartition is used to mark the given slot as in use, false is returned when the
+// slot is already in use
+func (m *MemoryState) ClaimPartition(_ context.Context, slot partitions.Slot) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.partitions[slot]; ok {
+		return false, nil
+	}
+
+	m.partitions[slot] = struct{}{}
+	return true, nil
+}
+
+// Free is used to deallocate the used slot for upcomming jobs
+func (m *MemoryState) Free(_ context.Context, slot partitions.Slot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, lKey(slot))
+	delete(m.entries, fKey(slot))
+	delete(m.entries, tKey(slot))
+	delete(m.entries, gKey(slot))
+	delete(m.entries, dKey(slot))
+	delete(m.entries, sKey(slot))
+	delete(m.entries, uKey(slot))
+	delete(m.entries, oKey(slot))
+	delete(m.partitions, slot)
+	delete(m.locations, slot)
+	return nil
+}
+
+// Topics is used to get the topics that are added to the pool along with their number of partitions
+func (m *MemoryState) Topics(_ context.Context) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	topics := make(map[string]int, len(m.topics))
+	for topic, count := range m.topics {
+		topics[topic] = count
+	}
+
+	return topics, nil
+}
+
+// AddTopic is used to add the given topic with count partitions to the pool
+func (m *MemoryState) AddTopic(_ context.Context, topic string, count int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.topics[topic] = count
+	return nil
+}
+
+// Enqueue is used to add the given waiting reservation to the end of the queue
+func (m *MemoryState) Enqueue(_ context.Context, reservation partitions.Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queue = append(m.queue, reservation.ID)
+	m.reservations[reservation.ID] = reservation
+	return nil
+}
+
+// GetReservation is used to get the given reservation along with its position in the queue
+func (m *MemoryState) GetReservation(_ context.Context, id string) (partitions.Reservation, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok {
+		return partitions.Reservation{}, false, nil
+	}
+	reservation.Position = slices.Index(m.queue, id) + 1
+
+	return reservation, true, nil
+}
+
+// AssignReservation is used to remove the reservation at the head of the queue while holding the given slot for it
+func (m *MemoryState) AssignReservation(_ context.Context, slot partitions.Slot, expiresAt time.Time) (partitions.Reservation, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.queue) > 0 {
+		id := m.queue[0]
+		m.queue = m.queue[1:]
+
+		reservation, ok := m.reservations[id]
+		if !ok {
+			continue
+		}
+		reservation.Status = partitions.ReservationReady
+		reservation.Slot = slot
+		reservation.ExpiresAt = expiresAt
+		m.reservations[id] = reservation
+
+		return reservation, true, nil
+	}
+
+	return partitions.Reservation{}, false, nil
+}
+
+// RefreshReservation is used to move the expiry of the given reservation to the given time
+func (m *MemoryState) RefreshReservation(_ context.Context, id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok {
+		return nil
+	}
+	reservation.ExpiresAt = expiresAt
+	m.reservations[id] = reservation
+
+	return nil
+}
+
+// TakeReservation is used to remove the given reservation only when a slot is held for it
+func (m *MemoryState) TakeReservation(_ context.Context, id string) (partitions.Reservation, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok || reservation.Status != partitions.ReservationReady {
+		return partitions.Reservation{}, false, nil
+	}
+	delete(m.reservations, id)
+
+	return reservation, true, nil
+}
+
+// ExpireReservations is used to remove every reservation that expires before the given time
+func (m *MemoryState) ExpireReservations(_ context.Context, now time.Time) ([]partitions.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := []partitions.Reservation{}
+	for id, reservation := range m.reservations {
+		if reservation.ExpiresAt.After(now) {
+			continue
+		}
+
+		delete(m.reservations, id)
+		m.queue = slices.DeleteFunc(m.queue, func(queued string) bool {
+			return queued == id
+		})
+		expired = append(expired, reservation)
+	}
+
+	return expired, nil
+}
+
+// Waiting is used to get the number of reservations that are waiting in the queue
+func (m *MemoryState) Waiting(_ context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.queue), nil
+}
+
+// LockSlot is used to hold the lock of the given slot, the lock of the in memory store only expires with the
+// process so the ttl is not used
+func (m *MemoryState) LockSlot(ctx context.Context, slot partitions.Slot, _ time.Duration) (func(), error) {
+	m.mu.Lock()
+	lock, ok := m.slotLocks[slot]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		m.slotLocks[slot] = lock
+	}
+	m.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() {
+			<-lock
+		}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Flush is used to remove every key in the store
+func (m *MemoryState) Flush(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = make(map[string]memoryEntry)
+	m.partitions = make(map[partitions.Slot]struct{})
+	m.locations = make(map[partitions.Slot]map[int]struct{})
+	m.topics = make(map[string]int)
+	m.queue = nil
+	m.reservations = make(map[string]partitions.Reservation)
+	return nil
+}
+
+// Close is used to close the connection to the store
+func (m *MemoryState) Close() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// osrmRoute is the response body of the route service of an OSRM server
+type osrmRoute struct {
+	Code   string `json:"code"`
+	Routes []struct {
+		// Geometry is the encoded polyline of the route when the overview is requested
+		Geometry string `json:"geometry"`
+		// Distance is in meters
+		Distance float64 `json:"distance"`
+		// Duration is in seconds
+		Duration float64 `json:"duration"`
+	} `json:"routes"`
+}
+
+// OSRMRouter is the route estimator that is backed by the route service of an OSRM server
+type OSRMRouter struct {
+	client *http.Client
+	url    string
+}
+
+// NewOSRMRouter is a function that is used to create a new OSRM route estimator with the base URL of the OSRM server
+func NewOSRMRouter(url string) *OSRMRouter {
+	return &OSRMRouter{
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		url: strings.TrimSuffix(url, "/"),
+	}
+}
+
+// route is used to get the driving route through the given coordinates with the given overview of its geometry
+func (o *OSRMRouter) route(ctx context.Context, stops []Geo, overview string) (osrmRoute, error) {
+	coordinates := make([]string, 0, len(stops))
+	for _, stop := range stops {
+		coordinates = append(coordinates, fmt.Sprintf("%f,%f", stop.Lon, stop.Lat))
+	}
+
+	url := fmt.Sprintf(
+		"%s/route/v1/driving/%s?overview=%s",
+		o.url,
+		strings.Join(coordinates, ";"),
+		overview,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return osrmRoute{}, err
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return osrmRoute{}, err
+	}
+	defer res.Body.Close()
+
+	var body osrmRoute
+	if err = sonic.ConfigDefault.NewDecoder(res.Body).Decode(&body); err != nil {
+		return osrmRoute{}, err
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return osrmRoute{}, ErrNoRoute
+	}
+
+	return body, nil
+}
+
+// Estimate is used to get the driving distance and time between the given coordinates
+func (o *OSRMRouter) Estimate(ctx context.Context, from, to Geo) (Estimate, error) {
+	body, err := o.route(ctx, []Geo{from, to}, "false")
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return Estimate{
+		Distance: body.Routes[0].Distance,
+		Duration: time.Duration(body.Routes[0].Duration * float64(time.Second)),
+	}, nil
+}
+
+// Route is used to get the driving route through the given coordinates, the geometry of the route is simplified
+// by the OSRM server
+func (o *OSRMRouter) Route(ctx context.Context, stops []Geo) ([]Geo, error) {
+	if len(stops) < 2 {
+		return nil, ErrNoRoute
+	}
+
+	body, err := o.route(ctx, stops, "simplified")
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeRoute(body.Routes[0].Geometry)
+}
@@ -0,0 +1,21 @@
+package connections
+
+import (
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// InitPartitions is a function that is used to initialize the partition allocator and its waiting queue on top of the state store,
+// the waiting queue is kept in the state store so that it is shared by every instance of the service
+// NOTE: the state store must be initialized before calling this function
+func (c *C) InitPartitions(e *env.Env) {
+	var claimer partitions.Claimer = partitions.NewStoreClaimer(c.State)
+	if c.R != nil {
+		claimer = partitions.NewRedisClaimer(c.R.DB, e.PartitionManagerKey, e.Topic)
+	}
+
+	c.Partitions = partitions.New(claimer, c.State, e.Topic, e.TotalPartitions)
+	c.Queue = partitions.NewQueue(c.Partitions, c.State, time.Duration(e.ReservationTimeout)*time.Second)
+}
@@ -2,52 +2,858 @@ package connections
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/bytedance/sonic"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 )
 
-func getLockKey(key string) string {
-	return "lock:" + key
+// advanceScript replaces the last known location in KEYS[1] with ARGV[2] only when the location index in
+// ARGV[1] is greater than the location index of the current last known location
+var advanceScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	local ok, location = pcall(cjson.decode, current)
+	if ok and type(location) == "table" and tonumber(location["location_index"]) ~= nil and tonumber(location["location_index"]) >= tonumber(ARGV[1]) then
+		return 0
+	end
+end
+
+redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+return 1
+`)
+
+// markScript adds the location indexes in ARGV to the set in KEYS[1] while reporting which of them are new,
+// the set expires along with the last known location in KEYS[2]
+var markScript = redis.NewScript(`
+local added = {}
+for i, index in ipairs(ARGV) do
+	added[i] = redis.call("SADD", KEYS[1], index)
+end
+
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+
+return added
+`)
+
+// expireWithScript sets KEYS[1] to ARGV[1] with the remaining time to live of KEYS[2]
+var expireWithScript = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+
+return 1
+`)
+
+// swapScript sets KEYS[1] to ARGV[1] while returning its previous value, the key expires along with the last known
+// location in KEYS[2] and it is left as it is when there is no last known location
+var swapScript = redis.NewScript(`
+local previous = redis.call("GET", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl == -2 then
+	return previous
+end
+
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+
+return previous
+`)
+
+// decrScript decrements the number in KEYS[1] without letting it go below zero, a missing key is left as it is,
+// since the script runs atomically the viewers that leave at the same time can never push the number below zero
+var decrScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	return 0
+end
+
+local viewers = tonumber(current)
+if viewers == nil or viewers <= 0 then
+	redis.call("SET", KEYS[1], 0, "KEEPTTL")
+	return 0
+end
+
+return redis.call("DECR", KEYS[1])
+`)
+
+// unlockScript removes the lock in KEYS[1] only when it is still held with the token in ARGV[1], so that a lock
+// that has expired and is held by another caller is not released, a single wakeup that expires after the
+// milliseconds in ARGV[2] is left in the list in KEYS[2] for the callers that wait for the lock
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+
+redis.call("DEL", KEYS[1], KEYS[2])
+redis.call("RPUSH", KEYS[2], ARGV[1])
+redis.call("PEXPIRE", KEYS[2], ARGV[2])
+return 1
+`)
+
+// migrateScript renames every key in the odd positions of KEYS to the key that follows it, a key is left as it is
+// when it does not exist or when the key that it is renamed to already exists
+var migrateScript = redis.NewScript(`
+local renamed = 0
+for i = 1, #KEYS, 2 do
+	if redis.call("EXISTS", KEYS[i]) == 1 and redis.call("EXISTS", KEYS[i + 1]) == 0 then
+		redis.call("RENAME", KEYS[i], KEYS[i + 1])
+		renamed = renamed + 1
+	end
+end
+
+return renamed
+`)
+
+// lockWait is the longest time to block for the wakeup of a lock that is held by another caller, so that a lock
+// that expires without being released is retried and so that a done context is noticed, it is kept below the
+// default read timeout of the connection
+const lockWait = time.Second
+
+// assignScript removes the first reservation of the queue in KEYS[1] that still exists in the hash in KEYS[2] and
+// holds the slot in ARGV[1] for it, the reservation expires at the unix milliseconds in ARGV[2] in the sorted set in
+// KEYS[3]
+var assignScript = redis.NewScript(`
+while true do
+	local head = redis.call("ZRANGE", KEYS[1], 0, 0)[1]
+	if not head then
+		return false
+	end
+	redis.call("ZREM", KEYS[1], head)
+
+	local payload = redis.call("HGET", KEYS[2], head)
+	if payload then
+		local reservation = cjson.decode(payload)
+		reservation["status"] = "ready"
+		reservation["slot"] = ARGV[1]
+		payload = cjson.encode(reservation)
+
+		redis.call("HSET", KEYS[2], head, payload)
+		redis.call("ZADD", KEYS[3], ARGV[2], head)
+		return {payload, ARGV[2]}
+	end
+end
+`)
+
+// takeScript removes the reservation in ARGV[1] from the hash in KEYS[1] and the sorted set of the expiries in KEYS[2]
+// only when a slot is held for it
+var takeScript = redis.NewScript(`
+local payload = redis.call("HGET", KEYS[1], ARGV[1])
+if not payload then
+	return false
+end
+
+local reservation = cjson.decode(payload)
+if reservation["status"] ~= "ready" then
+	return false
+end
+
+local expiresAt = redis.call("ZSCORE", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+return {payload, expiresAt}
+`)
+
+// expireScript removes every reservation that expires before the unix milliseconds in ARGV[1] from the queue in
+// KEYS[1], the hash in KEYS[2] and the sorted set of the expiries in KEYS[3] while returning them
+var expireScript = redis.NewScript(`
+local expired = {}
+for _, id in ipairs(redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1])) do
+	local payload = redis.call("HGET", KEYS[2], id)
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("HDEL", KEYS[2], id)
+	redis.call("ZREM", KEYS[3], id)
+	if payload then
+		table.insert(expired, payload)
+	end
+end
+
+return expired
+`)
+
+// queuedReservation is a reservation as it is kept in the state store along with the slot that is held for it, the
+// expiry is kept in a sorted set instead so that the expired reservations can be found
+type queuedReservation struct {
+	ID        string `json:"reservation_id"`
+	BookingID string `json:"booking_id"`
+	Status    string `json:"status"`
+	Slot      string `json:"slot,omitempty"`
+}
+
+// reservation is used to get the reservation of the given payload that expires at the given unix milliseconds
+func (q queuedReservation) reservation(expiresAt int64) (partitions.Reservation, error) {
+	reservation := partitions.Reservation{
+		ExpiresAt: time.UnixMilli(expiresAt).UTC(),
+		ID:        q.ID,
+		BookingID: q.BookingID,
+		Status:    partitions.ReservationStatus(q.Status),
+	}
+	if q.Slot == "" {
+		return reservation, nil
+	}
+
+	slot, err := partitions.ParseSlot(q.Slot)
+	if err != nil {
+		return partitions.Reservation{}, err
+	}
+	reservation.Slot = slot
+
+	return reservation, nil
+}
+
+// decodeReservation is used to decode the given payload of a reservation that expires at the given unix milliseconds
+func decodeReservation(payload string, expiresAt int64) (partitions.Reservation, error) {
+	var queued queuedReservation
+	if err := sonic.UnmarshalString(payload, &queued); err != nil {
+		return partitions.Reservation{}, err
+	}
+
+	return queued.reservation(expiresAt)
 }
 
 // Redis contains all Redis connections
 type Redis struct {
 	DB *redis.Client
+	// key is the key of the set that contains the partitions that are in use
+	key string
+	// topic is the primary topic whose partitions are added to the set without the topic by the older releases
+	topic   string
+	claimer *partitions.RedisClaimer
 }
 
-// AcquireLock is a function that is used to lock redis keys until an operation is finished modifiying it
-func (r *Redis) AcquireLock(ctx context.Context, client *redis.Client, key string, lockDuration, waitDuration time.Duration) (bool, error) {
-	for {
-		acquired, err := client.SetNX(ctx, getLockKey(key), "", lockDuration).Result()
+// NewRedis is a function that is used to create a Redis state store with the given client where the given topic is
+// the primary topic of the pool
+func NewRedis(client *redis.Client, partitionManagerKey, topic string) *Redis {
+	return &Redis{
+		DB:      client,
+		key:     partitionManagerKey,
+		topic:   topic,
+		claimer: partitions.NewRedisClaimer(client, partitionManagerKey, topic),
+	}
+}
+
+// get is used to get the value of the given key while treating missing keys as empty values
+func (r *Redis) get(ctx context.Context, key string) (string, error) {
+	val, err := r.DB.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return val, nil
+}
+
+// GetBooking is used to get the booking details under the given booking ID
+func (r *Redis) GetBooking(ctx context.Context, bookingID string) (string, error) {
+	return r.get(ctx, bookingID)
+}
+
+// GetDriver is used to get the driver details under the given driver ID
+func (r *Redis) GetDriver(ctx context.Context, driverID int) (string, error) {
+	return r.get(ctx, driverKey(driverID))
+}
+
+// SetDriver is used to replace the driver details under the given driver ID
+func (r *Redis) SetDriver(ctx context.Context, driverID int, payload string, ttl time.Duration) error {
+	return r.DB.Set(ctx, driverKey(driverID), payload, ttl).Err()
+}
+
+// DriverTTL is used to get the remaining time to live of the driver details
+func (r *Redis) DriverTTL(ctx context.Context, driverID int) (time.Duration, error) {
+	return r.DB.TTL(ctx, driverKey(driverID)).Result()
+}
+
+// GetPartition is used to get the backup (n-) details of the booking in the given slot
+func (r *Redis) GetPartition(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, nKey(slot))
+}
+
+// ClearPartition is used to remove the backup, last location, location indexes, filter, track, geofence, deviation, job and signal state and the viewer count of the given slot
+func (r *Redis) ClearPartition(ctx context.Context, slot partitions.Slot) error {
+	pipe := r.DB.Pipeline()
+
+	pipe.Del(ctx, nKey(slot))
+	pipe.Del(ctx, lKey(slot))
+	pipe.Del(ctx, iKey(slot))
+	pipe.Del(ctx, fKey(slot))
+	pipe.Del(ctx, tKey(slot))
+	pipe.Del(ctx, gKey(slot))
+	pipe.Del(ctx, dKey(slot))
+	pipe.Del(ctx, sKey(slot))
+	pipe.Del(ctx, uKey(slot))
+	pipe.Del(ctx, oKey(slot))
+	pipe.Del(ctx, cKey(slot))
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetLastLocation is used to get the last known location of the given slot
+func (r *Redis) GetLastLocation(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, lKey(slot))
+}
+
+// SetLastLocation is used to update the last known location while keeping the existing ttl
+func (r *Redis) SetLastLocation(ctx context.Context, slot partitions.Slot, payload string) error {
+	return r.DB.Set(ctx, lKey(slot), payload, redis.KeepTTL).Err()
+}
+
+// AdvanceLastLocation is used to update the last known location only when the given location index is
+// greater than the location index of the current last known location
+func (r *Redis) AdvanceLastLocation(ctx context.Context, slot partitions.Slot, index int, payload string) (bool, error) {
+	advanced, err := advanceScript.Run(ctx, r.DB, []string{lKey(slot)}, index, payload).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return advanced == 1, nil
+}
+
+// MarkLocations is used to record the given location indexes of the given slot
+func (r *Redis) MarkLocations(ctx context.Context, slot partitions.Slot, indexes []int) ([]bool, error) {
+	args := make([]any, len(indexes))
+	for i, index := range indexes {
+		args[i] = index
+	}
+
+	added, err := markScript.Run(ctx, r.DB, []string{iKey(slot), lKey(slot)}, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := make([]bool, len(added))
+	for i, val := range added {
+		fresh[i] = val == 1
+	}
+
+	return fresh, nil
+}
+
+// UnmarkLocations is used to forget the given location indexes of the given slot
+func (r *Redis) UnmarkLocations(ctx context.Context, slot partitions.Slot, indexes []int) error {
+	members := make([]any, len(indexes))
+	for i, index := range indexes {
+		members[i] = index
+	}
+
+	return r.DB.SRem(ctx, iKey(slot), members...).Err()
+}
+
+// GetFilter is used to get the state of the location filter of the given slot
+func (r *Redis) GetFilter(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, fKey(slot))
+}
+
+// SetFilter is used to replace the state of the location filter of the given slot
+func (r *Redis) SetFilter(ctx context.Context, slot partitions.Slot, payload string) error {
+	return expireWithScript.Run(ctx, r.DB, []string{fKey(slot), lKey(slot)}, payload).Err()
+}
+
+// GetTrack is used to get the state of the track of the given slot
+func (r *Redis) GetTrack(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, tKey(slot))
+}
+
+// SetTrack is used to replace the state of the track of the given slot
+func (r *Redis) SetTrack(ctx context.Context, slot partitions.Slot, payload string) error {
+	return expireWithScript.Run(ctx, r.DB, []string{tKey(slot), lKey(slot)}, payload).Err()
+}
+
+// GetGeofence is used to get the state of the geofences of the given slot
+func (r *Redis) GetGeofence(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, gKey(slot))
+}
+
+// SetGeofence is used to replace the state of the geofences of the given slot
+func (r *Redis) SetGeofence(ctx context.Context, slot partitions.Slot, payload string) error {
+	return expireWithScript.Run(ctx, r.DB, []string{gKey(slot), lKey(slot)}, payload).Err()
+}
+
+// GetDeviation is used to get the state of the route deviation of the given slot
+func (r *Redis) GetDeviation(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, dKey(slot))
+}
+
+// SetDeviation is used to replace the state of the route deviation of the given slot
+func (r *Redis) SetDeviation(ctx context.Context, slot partitions.Slot, payload string) error {
+	return expireWithScript.Run(ctx, r.DB, []string{dKey(slot), lKey(slot)}, payload).Err()
+}
+
+// GetLastUpdate is used to get the time that the driver of the given slot last sent a location
+func (r *Redis) GetLastUpdate(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, uKey(slot))
+}
+
+// SetLastUpdate is used to record the time that the driver of the given slot sent a location
+func (r *Redis) SetLastUpdate(ctx context.Context, slot partitions.Slot, at int64) error {
+	return expireWithScript.Run(ctx, r.DB, []string{uKey(slot), lKey(slot)}, at).Err()
+}
+
+// SwapSignal is used to replace the signal status of the given slot while getting the previous signal status
+func (r *Redis) SwapSignal(ctx context.Context, slot partitions.Slot, status string) (string, error) {
+	previous, err := swapScript.Run(ctx, r.DB, []string{oKey(slot), lKey(slot)}, status).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return previous, nil
+}
+
+// GetJobState is used to get the job status and the timeline of the given slot
+func (r *Redis) GetJobState(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, sKey(slot))
+}
+
+// SetJobState is used to replace the job status and the timeline of the given slot
+func (r *Redis) SetJobState(ctx context.Context, slot partitions.Slot, payload string) error {
+	return expireWithScript.Run(ctx, r.DB, []string{sKey(slot), lKey(slot)}, payload).Err()
+}
+
+// GetViewers is used to get the number of viewers connected to the given slot
+func (r *Redis) GetViewers(ctx context.Context, slot partitions.Slot) (string, error) {
+	return r.get(ctx, cKey(slot))
+}
+
+// IncrViewers is used to increment the number of viewers connected to the given slot
+func (r *Redis) IncrViewers(ctx context.Context, slot partitions.Slot) error {
+	return r.DB.Incr(ctx, cKey(slot)).Err()
+}
+
+// DecrViewers is used to decrement the number of viewers connected to the given slot
+// without letting it go below zero
+func (r *Redis) DecrViewers(ctx context.Context, slot partitions.Slot) error {
+	return decrScript.Run(ctx, r.DB, []string{cKey(slot)}).Err()
+}
+
+// CreateBooking is used to store all the keys that are related to a newly created booking
+func (r *Redis) CreateBooking(ctx context.Context, state BookingState) error {
+	pipe := r.DB.Pipeline()
+
+	pipe.SetNX(ctx, driverKey(state.DriverID), state.Driver, state.TTL)
+	pipe.SetNX(ctx, state.BookingID, state.Booking, state.TTL)
+	pipe.SetNX(ctx, lKey(state.Slot), state.Location, state.TTL)
+	pipe.SetNX(ctx, gKey(state.Slot), state.Geofence, state.TTL)
+	pipe.SetNX(ctx, dKey(state.Slot), state.Deviation, state.TTL)
+	pipe.SetNX(ctx, sKey(state.Slot), state.Job, state.TTL)
+	pipe.SetNX(ctx, uKey(state.Slot), state.LastUpdate, state.TTL)
+	pipe.SetNX(ctx, cKey(state.Slot), 0, state.TTL)
+	pipe.SetNX(ctx, nKey(state.Slot), state.Backup, state.BackupTTL)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DelBooking is used to remove a booking and all its related components
+func (r *Redis) DelBooking(ctx context.Context, driverID int, bookingID string, slot partitions.Slot) (bool, error) {
+	// the keys are removed in a transaction so that only one caller sees the booking being removed
+	pipe := r.DB.TxPipeline()
+
+	pipe.Del(ctx, driverKey(driverID))
+	removed := pipe.Del(ctx, bookingID)
+	pipe.Del(ctx, lKey(slot))
+	pipe.Del(ctx, iKey(slot))
+	pipe.Del(ctx, fKey(slot))
+	pipe.Del(ctx, tKey(slot))
+	pipe.Del(ctx, gKey(slot))
+	pipe.Del(ctx, dKey(slot))
+	pipe.Del(ctx, sKey(slot))
+	pipe.Del(ctx, uKey(slot))
+	pipe.Del(ctx, oKey(slot))
+	pipe.Del(ctx, cKey(slot))
+	pipe.Del(ctx, nKey(slot))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return removed.Val() > 0, nil
+}
+
+// Partitions is used to get all the slots that are currently in use
+func (r *Redis) Partitions(ctx context.Context) ([]partitions.Slot, error) {
+	members, err := r.DB.SMembers(ctx, r.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]partitions.Slot, 0, len(members))
+	seen := make(map[partitions.Slot]bool, len(members))
+	for _, member := range members {
+		slot, err := partitions.ParseMember(member, r.topic)
 		if err != nil {
-			return false, err
+			log.Error().Err(err).
+				Msgf(
+					"member : %s\tremoving the invalid member from the partition manager",
+					member,
+				)
+			r.DB.SRem(ctx, r.key, member)
+			continue
+		}
+		if seen[slot] {
+			continue
 		}
+		seen[slot] = true
+		slots = append(slots, slot)
+	}
+
+	return slots, nil
+}
+
+// ClaimPartition is used to mark the given slot as in use, false is returned when the
+// slot is already in use
+func (r *Redis) ClaimPartition(ctx context.Context, slot partitions.Slot) (bool, error) {
+	_, err := r.claimer.Claim(ctx, []partitions.Slot{slot}, 0)
+	if err != nil {
+		if errors.Is(err, partitions.ErrNoPartition) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Free is used to deallocate the used slot for upcomming jobs
+func (r *Redis) Free(ctx context.Context, slot partitions.Slot) error {
+	pipe := r.DB.Pipeline()
+
+	pipe.Del(ctx, lKey(slot))
+	pipe.Del(ctx, iKey(slot))
+	pipe.Del(ctx, fKey(slot))
+	pipe.Del(ctx, tKey(slot))
+	pipe.Del(ctx, gKey(slot))
+	pipe.Del(ctx, dKey(slot))
+	pipe.Del(ctx, sKey(slot))
+	pipe.Del(ctx, uKey(slot))
+	pipe.Del(ctx, oKey(slot))
+	pipe.SRem(ctx, r.key, slot.String())
+	if legacy := slot.LegacyMember(r.topic); legacy != "" {
+		pipe.SRem(ctx, r.key, legacy)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// topicsKey is used to get the key of the hash that contains the topics of the pool
+func (r *Redis) topicsKey() string {
+	return r.key + ":topics"
+}
+
+// Topics is used to get the topics that are added to the pool along with their number of partitions
+func (r *Redis) Topics(ctx context.Context) (map[string]int, error) {
+	fields, err := r.DB.HGetAll(ctx, r.topicsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make(map[string]int, len(fields))
+	for topic, val := range fields {
+		count, err := strconv.Atoi(val)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"topic : %s\tvalue : %s\tskipping the topic with an invalid number of partitions",
+					topic,
+					val,
+				)
+			continue
+		}
+		topics[topic] = count
+	}
+
+	return topics, nil
+}
+
+// AddTopic is used to add the given topic with count partitions to the pool
+func (r *Redis) AddTopic(ctx context.Context, topic string, count int) error {
+	return r.DB.HSet(ctx, r.topicsKey(), topic, count).Err()
+}
+
+// queueKey is used to get the key of the sorted set that contains the waiting reservations in the order that they
+// are added
+func (r *Redis) queueKey() string {
+	return r.key + ":queue"
+}
+
+// sequenceKey is used to get the key of the counter that orders the waiting reservations
+func (r *Redis) sequenceKey() string {
+	return r.key + ":sequence"
+}
+
+// reservationsKey is used to get the key of the hash that contains every reservation
+func (r *Redis) reservationsKey() string {
+	return r.key + ":reservations"
+}
+
+// expiriesKey is used to get the key of the sorted set that contains the expiry of every reservation
+func (r *Redis) expiriesKey() string {
+	return r.key + ":expiries"
+}
+
+// Enqueue is used to add the given waiting reservation to the end of the queue
+func (r *Redis) Enqueue(ctx context.Context, reservation partitions.Reservation) error {
+	payload, err := sonic.MarshalString(queuedReservation{
+		ID:        reservation.ID,
+		BookingID: reservation.BookingID,
+		Status:    string(reservation.Status),
+	})
+	if err != nil {
+		return err
+	}
+
+	sequence, err := r.DB.Incr(ctx, r.sequenceKey()).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.reservationsKey(), reservation.ID, payload)
+		pipe.ZAdd(ctx, r.expiriesKey(), redis.Z{
+			Score:  float64(reservation.ExpiresAt.UnixMilli()),
+			Member: reservation.ID,
+		})
+		pipe.ZAdd(ctx, r.queueKey(), redis.Z{
+			Score:  float64(sequence),
+			Member: reservation.ID,
+		})
+		return nil
+	})
+	return err
+}
+
+// GetReservation is used to get the given reservation along with its position in the queue
+func (r *Redis) GetReservation(ctx context.Context, id string) (partitions.Reservation, bool, error) {
+	pipe := r.DB.Pipeline()
+	payload := pipe.HGet(ctx, r.reservationsKey(), id)
+	expiresAt := pipe.ZScore(ctx, r.expiriesKey(), id)
+	rank := pipe.ZRank(ctx, r.queueKey(), id)
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return partitions.Reservation{}, false, err
+	}
+	if errors.Is(payload.Err(), redis.Nil) {
+		return partitions.Reservation{}, false, nil
+	}
 
+	reservation, err := decodeReservation(payload.Val(), int64(expiresAt.Val()))
+	if err != nil {
+		return partitions.Reservation{}, false, err
+	}
+	if rank.Err() == nil {
+		reservation.Position = int(rank.Val()) + 1
+	}
+
+	return reservation, true, nil
+}
+
+// AssignReservation is used to remove the reservation at the head of the queue while holding the given slot for it
+func (r *Redis) AssignReservation(ctx context.Context, slot partitions.Slot, expiresAt time.Time) (partitions.Reservation, bool, error) {
+	keys := []string{r.queueKey(), r.reservationsKey(), r.expiriesKey()}
+	return r.runReservation(ctx, assignScript, keys, slot.String(), expiresAt.UnixMilli())
+}
+
+// RefreshReservation is used to move the expiry of the given reservation to the given time
+func (r *Redis) RefreshReservation(ctx context.Context, id string, expiresAt time.Time) error {
+	return r.DB.ZAddXX(ctx, r.expiriesKey(), redis.Z{
+		Score:  float64(expiresAt.UnixMilli()),
+		Member: id,
+	}).Err()
+}
+
+// TakeReservation is used to remove the given reservation only when a slot is held for it
+func (r *Redis) TakeReservation(ctx context.Context, id string) (partitions.Reservation, bool, error) {
+	keys := []string{r.reservationsKey(), r.expiriesKey()}
+	return r.runReservation(ctx, takeScript, keys, id)
+}
+
+// runReservation is used to run the given script that returns a single reservation along with its expiry
+func (r *Redis) runReservation(ctx context.Context, script *redis.Script, keys []string, args ...any) (partitions.Reservation, bool, error) {
+	val, err := script.Run(ctx, r.DB, keys, args...).Slice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return partitions.Reservation{}, false, nil
+		}
+		return partitions.Reservation{}, false, err
+	}
+	if len(val) != 2 {
+		return partitions.Reservation{}, false, fmt.Errorf("unexpected reply of the reservation script : %v", val)
+	}
+
+	payload, _ := val[0].(string)
+	expiresAt, _ := val[1].(string)
+	at, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return partitions.Reservation{}, false, err
+	}
+
+	reservation, err := decodeReservation(payload, at)
+	if err != nil {
+		return partitions.Reservation{}, false, err
+	}
+
+	return reservation, true, nil
+}
+
+// ExpireReservations is used to remove every reservation that expires before the given time
+func (r *Redis) ExpireReservations(ctx context.Context, now time.Time) ([]partitions.Reservation, error) {
+	keys := []string{r.queueKey(), r.reservationsKey(), r.expiriesKey()}
+	payloads, err := expireScript.Run(ctx, r.DB, keys, now.UnixMilli()).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]partitions.Reservation, 0, len(payloads))
+	for _, payload := range payloads {
+		reservation, err := decodeReservation(payload, now.UnixMilli())
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"payload : %s\tfailed to decode the expired reservation",
+					payload,
+				)
+			continue
+		}
+		expired = append(expired, reservation)
+	}
+
+	return expired, nil
+}
+
+// Waiting is used to get the number of reservations that are waiting in the queue
+func (r *Redis) Waiting(ctx context.Context) (int, error) {
+	waiting, err := r.DB.ZCard(ctx, r.queueKey()).Result()
+	return int(waiting), err
+}
+
+// LockSlot is used to hold the lock of the given slot, the lock expires once the given ttl has passed even when it
+// is not released so that a stopped instance does not hold it forever, the callers that wait for the lock block on
+// the wakeup list of the slot with BLPOP, which the release of the lock pushes to, for at most lockWait at a time
+func (r *Redis) LockSlot(ctx context.Context, slot partitions.Slot, ttl time.Duration) (func(), error) {
+	key := lockKey(slot)
+	wake := lockWakeKey(slot)
+	token := uuid.New().String()
+
+	for {
+		acquired, err := r.DB.SetNX(ctx, key, token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
 		if acquired {
-			return true, nil
+			break
+		}
+
+		// the blocking read does not end with the context, so the wait ends before the deadline of the context
+		wait := lockWait
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = min(wait, time.Until(deadline))
+		}
+		if wait <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+
+		// the wakeup that is left by a release before the wait started is taken at once, so that it is not missed
+		timeout := strconv.FormatFloat(max(wait.Seconds(), 0.001), 'f', 3, 64)
+		err = r.DB.Do(ctx, "BLPOP", wake, timeout).Err()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+	}
+
+	return func() {
+		err := unlockScript.Run(context.Background(), r.DB, []string{key, wake}, token, ttl.Milliseconds()).Err()
+		if err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"slot : %s\tfailed to release the lock of the slot",
+					slot,
+				)
 		}
+	}, nil
+}
 
-		select {
-		case <-ctx.Done():
-			return false, ctx.Err()
-		default:
-			time.Sleep(waitDuration)
+// MigrateLegacyKeys is used to rename the last location, viewer count and backup keys of the slots of the primary
+// topic that the older releases store without the topic, so that the bookings that are live during the rollout keep
+// their state, the number of the renamed keys is returned
+func (r *Redis) MigrateLegacyKeys(ctx context.Context) (int, error) {
+	slots, err := r.Partitions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{}
+	for _, slot := range slots {
+		partition := slot.LegacyMember(r.topic)
+		if partition == "" {
+			continue
 		}
+
+		keys = append(keys,
+			"l"+partition, lKey(slot),
+			"c"+partition, cKey(slot),
+			"n"+partition, nKey(slot),
+		)
 	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	return migrateScript.Run(ctx, r.DB, keys).Int()
+}
+
+// Flush is used to remove every key in the store
+func (r *Redis) Flush(ctx context.Context) error {
+	return r.DB.FlushDB(ctx).Err()
 }
 
-// ReleaseLock is a function that is used to release the added lock
-func (r *Redis) ReleaseLock(client *redis.Client, key string) {
-	client.Del(context.Background(), getLockKey(key))
+// Close is used to close the connection to the store
+func (r *Redis) Close() error {
+	return r.DB.Close()
 }
 
 // InitRedis is a function that is used to intialize redis databases
 func (c *C) InitRedis(e *env.Env) {
-	c.R = &Redis{
-		DB: connect(e.RedisDBURL),
+	c.R = NewRedis(connect(e.RedisDBURL), e.PartitionManagerKey, e.Topic)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	renamed, err := c.R.MigrateLegacyKeys(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to migrate the keys of the slots without a topic")
+		return
+	}
+	if renamed > 0 {
+		log.Info().
+			Msgf(
+				"renamed : %d	migrated the keys of the slots without a topic",
+				renamed,
+			)
 	}
 }
 
@@ -0,0 +1,101 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+	"googlemaps.github.io/maps"
+)
+
+// routeDetour is how much longer the roads are than the straight line between two coordinates on average
+const routeDetour = 1.3
+
+// ErrNoRoute is an error that occurs when the route estimator cannot find a route between the given coordinates
+var ErrNoRoute = fmt.Errorf("there is no route between the given coordinates")
+
+// Estimate contains the remaining distance and time to reach a destination
+type Estimate struct {
+	// Distance is the remaining distance in meters
+	Distance float64
+	// Duration is the remaining time to reach the destination
+	Duration time.Duration
+}
+
+// RouteEstimator is the interface that is used to estimate the time to travel between two coordinates
+type RouteEstimator interface {
+	// Estimate is used to get the distance and the time to travel from the first coordinate to the second coordinate
+	Estimate(ctx context.Context, from, to Geo) (Estimate, error)
+	// Route is used to get the path that the driver is expected to take to travel through the given coordinates in order
+	Route(ctx context.Context, stops []Geo) ([]Geo, error)
+}
+
+// DecodeRoute is a function that is used to get the coordinates of the given encoded polyline
+func DecodeRoute(polyline string) ([]Geo, error) {
+	points, err := maps.DecodePolyline(polyline)
+	if err != nil {
+		return nil, err
+	}
+
+	route := make([]Geo, 0, len(points))
+	for _, point := range points {
+		route = append(route, Geo{
+			Lat: point.Lat,
+			Lon: point.Lng,
+		})
+	}
+
+	return route, nil
+}
+
+// StraightLineRouter is the route estimator that assumes that the driver travels along the straight line
+// between the coordinates at a constant speed
+type StraightLineRouter struct {
+	// speed is the average speed in meters per second
+	speed float64
+	// detour is the factor that the straight line distance is multiplied by to account for the roads
+	detour float64
+}
+
+// NewStraightLineRouter is a function that is used to create a new straight line route estimator with the given
+// average speed in kilometers per hour and the given detour factor
+func NewStraightLineRouter(speed, detour float64) *StraightLineRouter {
+	return &StraightLineRouter{
+		speed:  speed * 1000 / 3600,
+		detour: detour,
+	}
+}
+
+// Estimate is used to get the distance and the time to travel between the given coordinates
+func (s *StraightLineRouter) Estimate(_ context.Context, from, to Geo) (Estimate, error) {
+	distance := geo.Distance(from.Lat, from.Lon, to.Lat, to.Lon) * s.detour
+
+	return Estimate{
+		Distance: distance,
+		Duration: time.Duration(distance / s.speed * float64(time.Second)),
+	}, nil
+}
+
+// Route is used to get the straight lines between the given coordinates as the path
+func (s *StraightLineRouter) Route(_ context.Context, stops []Geo) ([]Geo, error) {
+	if len(stops) < 2 {
+		return nil, ErrNoRoute
+	}
+
+	return stops, nil
+}
+
+// InitRouter is a function that is used to initialize the route estimator depending on the configured backend
+func (c *C) InitRouter(e *env.Env) {
+	switch enums.Backend(e.Router) {
+	case enums.OSRM:
+		c.Router = NewOSRMRouter(e.OSRMURL)
+	case enums.Google:
+		c.Router = NewGoogleRouter(e)
+	default:
+		c.Router = NewStraightLineRouter(float64(e.RouteSpeed), routeDetour)
+	}
+}
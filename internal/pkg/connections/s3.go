@@ -0,0 +1,88 @@
+package connections
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 is the archive store that is backed by a bucket of any S3 compatible object storage (AWS S3, MinIO, ... )
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3 is a function that is used to create a new S3 compatible archive store
+func NewS3(e *env.Env) (*S3, error) {
+	client, err := minio.New(e.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(e.S3AccessKey, e.S3SecretKey, ""),
+		Secure: !e.S3Insecure,
+		Region: e.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		client: client,
+		bucket: e.BucketName,
+	}, nil
+}
+
+func isS3NotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+// Put is used to save the given data under the given name, replacing any existing archive
+func (s *S3) Put(ctx context.Context, name string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, name, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	return err
+}
+
+// Get is used to read the archive under the given name
+func (s *S3) Get(ctx context.Context, name string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Delete is used to remove the archive under the given name
+func (s *S3) Delete(ctx context.Context, name string) error {
+	// S3 does not report missing objects on removal, so check the existence of the object first
+	_, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return ErrArchiveNotFound
+		}
+		return err
+	}
+
+	return s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{})
+}
+
+// Close is used to close the archive store
+func (s *S3) Close() error {
+	return nil
+}
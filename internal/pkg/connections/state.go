@@ -0,0 +1,253 @@
+package connections
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/enums"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+)
+
+// StateStore is the interface that is used to persist the state of the active bookings
+//
+// All the getters return an empty string when the requested key does not exist, so that the
+// callers can treat a missing booking the same way regardless of the backend that is in use
+type StateStore interface {
+	// GetBooking is used to get the booking details under the given booking ID
+	GetBooking(ctx context.Context, bookingID string) (string, error)
+	// GetDriver is used to get the driver details under the given driver ID
+	GetDriver(ctx context.Context, driverID int) (string, error)
+	// SetDriver is used to replace the driver details under the given driver ID
+	SetDriver(ctx context.Context, driverID int, payload string, ttl time.Duration) error
+	// DriverTTL is used to get the remaining time to live of the driver details
+	DriverTTL(ctx context.Context, driverID int) (time.Duration, error)
+
+	// GetPartition is used to get the backup (n-) details of the booking in the given slot
+	GetPartition(ctx context.Context, slot partitions.Slot) (string, error)
+	// ClearPartition is used to remove the backup, last location, location indexes, filter, track, geofence, deviation, job and signal state and the viewer count of the given slot
+	ClearPartition(ctx context.Context, slot partitions.Slot) error
+
+	// GetLastLocation is used to get the last known location of the given slot
+	GetLastLocation(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetLastLocation is used to update the last known location while keeping the existing ttl
+	SetLastLocation(ctx context.Context, slot partitions.Slot, payload string) error
+	// AdvanceLastLocation is used to update the last known location only when the given location index is
+	// greater than the location index of the current last known location, false is returned when the
+	// last known location is left as it is
+	AdvanceLastLocation(ctx context.Context, slot partitions.Slot, index int, payload string) (bool, error)
+
+	// MarkLocations is used to record the given location indexes of the given slot, the returned slice
+	// reports wether each index is recorded for the first time
+	MarkLocations(ctx context.Context, slot partitions.Slot, indexes []int) ([]bool, error)
+	// UnmarkLocations is used to forget the given location indexes of the given slot
+	UnmarkLocations(ctx context.Context, slot partitions.Slot, indexes []int) error
+
+	// GetFilter is used to get the state of the location filter of the given slot
+	GetFilter(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetFilter is used to replace the state of the location filter of the given slot, the state expires
+	// along with the last known location
+	SetFilter(ctx context.Context, slot partitions.Slot, payload string) error
+
+	// GetTrack is used to get the state of the track of the given slot
+	GetTrack(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetTrack is used to replace the state of the track of the given slot, the state expires along with the
+	// last known location
+	SetTrack(ctx context.Context, slot partitions.Slot, payload string) error
+
+	// GetGeofence is used to get the state of the geofences of the given slot
+	GetGeofence(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetGeofence is used to replace the state of the geofences of the given slot, the state expires along with
+	// the last known location
+	SetGeofence(ctx context.Context, slot partitions.Slot, payload string) error
+
+	// GetDeviation is used to get the state of the route deviation of the given slot
+	GetDeviation(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetDeviation is used to replace the state of the route deviation of the given slot, the state expires
+	// along with the last known location
+	SetDeviation(ctx context.Context, slot partitions.Slot, payload string) error
+
+	// GetLastUpdate is used to get the time that the driver of the given slot last sent a location in unix milliseconds
+	GetLastUpdate(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetLastUpdate is used to record the time that the driver of the given slot sent a location in unix
+	// milliseconds, the time expires along with the last known location
+	SetLastUpdate(ctx context.Context, slot partitions.Slot, at int64) error
+	// SwapSignal is used to replace the signal status of the given slot while getting the previous signal status,
+	// the status is not replaced when the slot does not have a last known location
+	SwapSignal(ctx context.Context, slot partitions.Slot, status string) (string, error)
+
+	// LockSlot is used to hold the lock of the given slot so that the states of the slot are changed by a single
+	// caller at a time across every instance of the service, it blocks until the lock is held or the context is
+	// done, the lock is released by the returned function or once the given ttl has passed
+	LockSlot(ctx context.Context, slot partitions.Slot, ttl time.Duration) (func(), error)
+
+	// GetJobState is used to get the job status and the timeline of the given slot
+	GetJobState(ctx context.Context, slot partitions.Slot) (string, error)
+	// SetJobState is used to replace the job status and the timeline of the given slot, the state expires along
+	// with the last known location
+	SetJobState(ctx context.Context, slot partitions.Slot, payload string) error
+
+	// GetViewers is used to get the number of viewers connected to the given slot
+	GetViewers(ctx context.Context, slot partitions.Slot) (string, error)
+	// IncrViewers is used to increment the number of viewers connected to the given slot
+	IncrViewers(ctx context.Context, slot partitions.Slot) error
+	// DecrViewers is used to decrement the number of viewers connected to the given slot
+	// without letting it go below zero
+	DecrViewers(ctx context.Context, slot partitions.Slot) error
+
+	// CreateBooking is used to store all the keys that are related to a newly created booking
+	CreateBooking(ctx context.Context, state BookingState) error
+	// DelBooking is used to remove a booking and all its related components in a single step, false is returned
+	// when the booking has already been removed so that only one of the callers that end the same booking ends it
+	DelBooking(ctx context.Context, driverID int, bookingID string, slot partitions.Slot) (bool, error)
+
+	// Partitions is used to get all the slots that are currently in use
+	Partitions(ctx context.Context) ([]partitions.Slot, error)
+	// ClaimPartition is used to mark the given slot as in use, false is returned when the
+	// slot is already in use
+	ClaimPartition(ctx context.Context, slot partitions.Slot) (bool, error)
+	// Free is used to deallocate the used slot for upcomming jobs
+	Free(ctx context.Context, slot partitions.Slot) error
+	// Topics is used to get the topics that are added to the pool along with their number of partitions
+	Topics(ctx context.Context) (map[string]int, error)
+	// AddTopic is used to add the given topic with count partitions to the pool
+	AddTopic(ctx context.Context, topic string, count int) error
+
+	// Enqueue is used to add the given waiting reservation to the end of the queue
+	Enqueue(ctx context.Context, reservation partitions.Reservation) error
+	// GetReservation is used to get the given reservation along with its position in the queue, false is returned
+	// when the reservation does not exist
+	GetReservation(ctx context.Context, id string) (partitions.Reservation, bool, error)
+	// AssignReservation is used to remove the reservation at the head of the queue while holding the given slot
+	// for it until the given time, false is returned when nobody is waiting in the queue
+	AssignReservation(ctx context.Context, slot partitions.Slot, expiresAt time.Time) (partitions.Reservation, bool, error)
+	// RefreshReservation is used to move the expiry of the given reservation to the given time
+	RefreshReservation(ctx context.Context, id string, expiresAt time.Time) error
+	// TakeReservation is used to remove the given reservation only when a slot is held for it, false is returned
+	// when the reservation does not exist or it is still waiting
+	TakeReservation(ctx context.Context, id string) (partitions.Reservation, bool, error)
+	// ExpireReservations is used to remove every reservation that expires before the given time
+	ExpireReservations(ctx context.Context, now time.Time) ([]partitions.Reservation, error)
+	// Waiting is used to get the number of reservations that are waiting in the queue
+	Waiting(ctx context.Context) (int, error)
+
+	// Flush is used to remove every key in the store
+	Flush(ctx context.Context) error
+	// Close is used to close the connection to the store
+	Close() error
+}
+
+// BookingState contains the serialized payloads that are stored when a booking is created
+type BookingState struct {
+	BookingID string
+	// Booking is the payload that is stored under the booking ID
+	Booking string
+	// Driver is the payload that is stored under the driver ID
+	Driver string
+	// Location is the initial last known location of the slot
+	Location string
+	// Geofence is the initial state of the geofences of the slot
+	Geofence string
+	// Deviation is the initial state of the route deviation of the slot along with the expected route
+	Deviation string
+	// Job is the initial job status of the slot
+	Job string
+	// LastUpdate is the time that the booking is created in unix milliseconds, so that a driver that never
+	// sends a location is reported as well
+	LastUpdate int64
+	// Backup is the payload that is stored under the n- key of the slot
+	Backup   string
+	Slot     partitions.Slot
+	DriverID int
+	// TTL is the time to live of the booking, driver, location and viewer keys
+	TTL time.Duration
+	// BackupTTL is the time to live of the backup key
+	BackupTTL time.Duration
+}
+
+// lKey is used to get the key of the last location of the given slot
+func lKey(slot partitions.Slot) string {
+	return "l" + slot.String()
+}
+
+// cKey is used to get the key of the number of viewers of the given slot
+func cKey(slot partitions.Slot) string {
+	return "c" + slot.String()
+}
+
+// nKey is used to get the key of the booking backup of the given slot
+func nKey(slot partitions.Slot) string {
+	return "n" + slot.String()
+}
+
+// iKey is used to get the key of the recorded location indexes of the given slot
+func iKey(slot partitions.Slot) string {
+	return "i" + slot.String()
+}
+
+// fKey is used to get the key of the location filter state of the given slot
+func fKey(slot partitions.Slot) string {
+	return "f" + slot.String()
+}
+
+// tKey is used to get the key of the track state of the given slot
+func tKey(slot partitions.Slot) string {
+	return "t" + slot.String()
+}
+
+// gKey is used to get the key of the geofence state of the given slot
+func gKey(slot partitions.Slot) string {
+	return "g" + slot.String()
+}
+
+// dKey is used to get the key of the route deviation state of the given slot
+func dKey(slot partitions.Slot) string {
+	return "d" + slot.String()
+}
+
+// uKey is used to get the key of the time of the last location of the given slot
+func uKey(slot partitions.Slot) string {
+	return "u" + slot.String()
+}
+
+// lockKey is used to get the key of the lock of the given slot
+func lockKey(slot partitions.Slot) string {
+	return "lock:" + slot.String()
+}
+
+// lockWakeKey is used to get the key of the list that wakes the callers that wait for the lock of the given slot
+func lockWakeKey(slot partitions.Slot) string {
+	return "lock:" + slot.String() + ":wake"
+}
+
+// oKey is used to get the key of the signal status of the given slot
+func oKey(slot partitions.Slot) string {
+	return "o" + slot.String()
+}
+
+// sKey is used to get the key of the job state of the given slot
+func sKey(slot partitions.Slot) string {
+	return "s" + slot.String()
+}
+
+// lastLocation is used to read the location index of a last known location payload
+type lastLocation struct {
+	LocationIndex *int `json:"location_index"`
+}
+
+// driverKey is used to get the key of the given driver
+func driverKey(driverID int) string {
+	return fmt.Sprint(driverID)
+}
+
+// InitState is a function that is used to initialize the state store depending on the configured backend
+func (c *C) InitState(e *env.Env) {
+	if e.StateStore == string(enums.Memory) {
+		c.State = NewMemoryState()
+		return
+	}
+
+	c.InitRedis(e)
+	c.State = c.R
+}
@@ -0,0 +1,514 @@
+package connections
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStores is used to get every backend of the state store, so that the same behaviour is checked for all of them
+func testStores(t *testing.T) map[string]StateStore {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	return map[string]StateStore{
+		"memory": NewMemoryState(),
+		"redis":  NewRedis(client, "partitions", "locations"),
+	}
+}
+
+func testBooking(slot partitions.Slot) BookingState {
+	return BookingState{
+		BookingID:  "B1",
+		Booking:    `{"slot":"` + slot.String() + `"}`,
+		Driver:     `{"booking_id":"B1"}`,
+		Location:   `{"lat":51.5,"lon":-0.12,"location_index":0}`,
+		Geofence:   `{}`,
+		Deviation:  `{}`,
+		Job:        `{"status":2,"assumed":true}`,
+		LastUpdate: 1000,
+		Backup:     "B1",
+		Slot:       slot,
+		DriverID:   7,
+		TTL:        time.Hour,
+		BackupTTL:  2 * time.Hour,
+	}
+}
+
+func TestStateStoreMissingKeys(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 0}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			getters := map[string]func() (string, error){
+				"booking":  func() (string, error) { return store.GetBooking(ctx, "missing") },
+				"driver":   func() (string, error) { return store.GetDriver(ctx, 1) },
+				"backup":   func() (string, error) { return store.GetPartition(ctx, slot) },
+				"location": func() (string, error) { return store.GetLastLocation(ctx, slot) },
+				"viewers":  func() (string, error) { return store.GetViewers(ctx, slot) },
+				"job":      func() (string, error) { return store.GetJobState(ctx, slot) },
+			}
+			for key, get := range getters {
+				val, err := get()
+				if err != nil || val != "" {
+					t.Errorf("%s: got %q, %v, want an empty value", key, val, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStateStoreBooking(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 3}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			state := testBooking(slot)
+			if err := store.CreateBooking(ctx, state); err != nil {
+				t.Fatal(err)
+			}
+
+			// the keys of an existing booking are not replaced
+			again := testBooking(slot)
+			again.Booking = "replaced"
+			if err := store.CreateBooking(ctx, again); err != nil {
+				t.Fatal(err)
+			}
+
+			if val, _ := store.GetBooking(ctx, state.BookingID); val != state.Booking {
+				t.Errorf("booking: got %q, want %q", val, state.Booking)
+			}
+			if val, _ := store.GetDriver(ctx, state.DriverID); val != state.Driver {
+				t.Errorf("driver: got %q, want %q", val, state.Driver)
+			}
+			if val, _ := store.GetLastLocation(ctx, slot); val != state.Location {
+				t.Errorf("location: got %q, want %q", val, state.Location)
+			}
+			if val, _ := store.GetPartition(ctx, slot); val != state.Backup {
+				t.Errorf("backup: got %q, want %q", val, state.Backup)
+			}
+			if val, _ := store.GetViewers(ctx, slot); val != "0" {
+				t.Errorf("viewers: got %q, want 0", val)
+			}
+			if val, _ := store.GetJobState(ctx, slot); val != state.Job {
+				t.Errorf("job: got %q, want %q", val, state.Job)
+			}
+			if val, _ := store.GetLastUpdate(ctx, slot); val != "1000" {
+				t.Errorf("last update: got %q, want 1000", val)
+			}
+			if ttl, _ := store.DriverTTL(ctx, state.DriverID); ttl <= 0 || ttl > state.TTL {
+				t.Errorf("driver ttl: got %s, want at most %s", ttl, state.TTL)
+			}
+
+			// only the first caller removes the booking
+			if ended, err := store.DelBooking(ctx, state.DriverID, state.BookingID, slot); err != nil || !ended {
+				t.Fatalf("got %t, %v, want the booking to be removed", ended, err)
+			}
+			if ended, err := store.DelBooking(ctx, state.DriverID, state.BookingID, slot); err != nil || ended {
+				t.Errorf("second delete: got %t, %v, want the booking to be removed already", ended, err)
+			}
+			if val, _ := store.GetDriver(ctx, state.DriverID); val != "" {
+				t.Errorf("driver after delete: got %q", val)
+			}
+			if val, _ := store.GetLastLocation(ctx, slot); val != "" {
+				t.Errorf("location after delete: got %q", val)
+			}
+		})
+	}
+}
+
+func TestStateStoreViewers(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 1}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateBooking(ctx, testBooking(slot)); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := store.IncrViewers(ctx, slot); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if val, _ := store.GetViewers(ctx, slot); val != "2" {
+				t.Errorf("got %q viewers, want 2", val)
+			}
+
+			// the number of viewers never goes below zero
+			for i := 0; i < 3; i++ {
+				if err := store.DecrViewers(ctx, slot); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if val, _ := store.GetViewers(ctx, slot); val != "0" {
+				t.Errorf("got %q viewers, want 0", val)
+			}
+
+			// the viewers that leave at the same time do not push the number below zero either
+			for i := 0; i < 2; i++ {
+				if err := store.IncrViewers(ctx, slot); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := store.DecrViewers(ctx, slot); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			if val, _ := store.GetViewers(ctx, slot); val != "0" {
+				t.Errorf("got %q viewers, want 0", val)
+			}
+		})
+	}
+}
+
+func TestStateStoreLocations(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 2}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateBooking(ctx, testBooking(slot)); err != nil {
+				t.Fatal(err)
+			}
+
+			// the last known location only moves forward
+			advanced, err := store.AdvanceLastLocation(ctx, slot, 5, `{"location_index":5}`)
+			if err != nil || !advanced {
+				t.Fatalf("got %t, %v, want the location to advance", advanced, err)
+			}
+			advanced, err = store.AdvanceLastLocation(ctx, slot, 4, `{"location_index":4}`)
+			if err != nil || advanced {
+				t.Fatalf("got %t, %v, want the older location to be ignored", advanced, err)
+			}
+			if val, _ := store.GetLastLocation(ctx, slot); val != `{"location_index":5}` {
+				t.Errorf("got %q, want the location with the index 5", val)
+			}
+
+			fresh, err := store.MarkLocations(ctx, slot, []int{1, 2, 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(fresh, []bool{true, true, true}) {
+				t.Errorf("got %v, want every index to be new", fresh)
+			}
+			fresh, _ = store.MarkLocations(ctx, slot, []int{3, 4})
+			if !slices.Equal(fresh, []bool{false, true}) {
+				t.Errorf("got %v, want only the index 4 to be new", fresh)
+			}
+			if err := store.UnmarkLocations(ctx, slot, []int{3}); err != nil {
+				t.Fatal(err)
+			}
+			fresh, _ = store.MarkLocations(ctx, slot, []int{3})
+			if !slices.Equal(fresh, []bool{true}) {
+				t.Errorf("got %v, want the forgotten index to be new again", fresh)
+			}
+
+			// the indexes are forgotten once the slot is freed
+			if err := store.Free(ctx, slot); err != nil {
+				t.Fatal(err)
+			}
+			fresh, _ = store.MarkLocations(ctx, slot, []int{1})
+			if !slices.Equal(fresh, []bool{true}) {
+				t.Errorf("got %v, want the index to be new after the slot is freed", fresh)
+			}
+		})
+	}
+}
+
+func TestStateStoreSignal(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 4}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// the signal is not recorded for a slot without a booking
+			if _, err := store.SwapSignal(ctx, slot, "stale"); err != nil {
+				t.Fatal(err)
+			}
+			if previous, _ := store.SwapSignal(ctx, slot, "lost"); previous != "" {
+				t.Errorf("got %q, want no signal without a last known location", previous)
+			}
+
+			if err := store.CreateBooking(ctx, testBooking(slot)); err != nil {
+				t.Fatal(err)
+			}
+			if previous, _ := store.SwapSignal(ctx, slot, "stale"); previous != "" {
+				t.Errorf("got %q, want no previous signal", previous)
+			}
+			if previous, _ := store.SwapSignal(ctx, slot, "live"); previous != "stale" {
+				t.Errorf("got %q, want the previous signal to be stale", previous)
+			}
+		})
+	}
+}
+
+func TestStateStorePartitions(t *testing.T) {
+	ctx := context.Background()
+	first := partitions.Slot{Topic: "locations", Partition: 0}
+	second := partitions.Slot{Topic: "extra", Partition: 1}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, slot := range []partitions.Slot{first, second} {
+				claimed, err := store.ClaimPartition(ctx, slot)
+				if err != nil || !claimed {
+					t.Fatalf("%s: got %t, %v, want the slot to be claimed", slot, claimed, err)
+				}
+			}
+			if claimed, _ := store.ClaimPartition(ctx, first); claimed {
+				t.Errorf("want a slot in use not to be claimed again")
+			}
+
+			slots, err := store.Partitions(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(slots) != 2 || !slices.Contains(slots, first) || !slices.Contains(slots, second) {
+				t.Errorf("got %v, want both slots", slots)
+			}
+
+			if err := store.Free(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+			if claimed, _ := store.ClaimPartition(ctx, first); !claimed {
+				t.Errorf("want a freed slot to be claimed again")
+			}
+
+			if err := store.AddTopic(ctx, "extra", 4); err != nil {
+				t.Fatal(err)
+			}
+			topics, err := store.Topics(ctx)
+			if err != nil || topics["extra"] != 4 {
+				t.Errorf("got %v, %v, want the extra topic with 4 partitions", topics, err)
+			}
+		})
+	}
+}
+
+func TestRedisLegacyPartitions(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	store := NewRedis(client, "partitions", "locations")
+	legacy := partitions.Slot{Topic: "locations", Partition: 0}
+
+	// the older releases add the partitions of the primary topic without the topic
+	if err := client.SAdd(ctx, "partitions", "0", "invalid").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	slots, err := store.Partitions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 1 || slots[0] != legacy {
+		t.Errorf("got %v, want the legacy member to be the partition 0 of the primary topic", slots)
+	}
+	if ok, _ := client.SIsMember(ctx, "partitions", "0").Result(); !ok {
+		t.Errorf("want the legacy member to be kept in the partition manager")
+	}
+
+	// the slot of the legacy member is in use until it is freed
+	if claimed, _ := store.ClaimPartition(ctx, legacy); claimed {
+		t.Errorf("want the slot of the legacy member not to be claimed")
+	}
+	if claimed, _ := store.ClaimPartition(ctx, partitions.Slot{Topic: "extra", Partition: 0}); !claimed {
+		t.Errorf("want the same partition of another topic to be claimed")
+	}
+
+	if err := store.Free(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := store.ClaimPartition(ctx, legacy); !claimed {
+		t.Errorf("want the freed slot to be claimed")
+	}
+}
+
+func TestRedisMigrateLegacyKeys(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	store := NewRedis(client, "partitions", "locations")
+	legacy := partitions.Slot{Topic: "locations", Partition: 2}
+
+	// the older releases keep the keys of the slot under the partition without the topic
+	if err := client.SAdd(ctx, "partitions", "2", "extra:3").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set(ctx, "l2", `{"lat":51.5}`, time.Hour).Err(); err != nil {
+		t.Fatal(err)
+	}
+	client.Set(ctx, "c2", 3, time.Hour)
+	client.Set(ctx, "n2", `["B1","10"]`, 0)
+	// a key that is already written under the new name is not replaced
+	client.Set(ctx, "c"+legacy.String(), 1, 0)
+
+	renamed, err := store.MigrateLegacyKeys(ctx)
+	if err != nil || renamed != 2 {
+		t.Fatalf("got %d, %v, want 2 keys to be renamed", renamed, err)
+	}
+	if val, _ := store.GetLastLocation(ctx, legacy); val != `{"lat":51.5}` {
+		t.Errorf("got the last location %q, want it to be migrated", val)
+	}
+	if ttl := server.TTL("l" + legacy.String()); ttl <= 0 {
+		t.Errorf("got the ttl %s, want the last location to keep its ttl", ttl)
+	}
+	if val, _ := store.GetPartition(ctx, legacy); val != `["B1","10"]` {
+		t.Errorf("got the backup %q, want it to be migrated", val)
+	}
+	if val, _ := store.GetViewers(ctx, legacy); val != "1" {
+		t.Errorf("got %q viewers, want the existing count to be kept", val)
+	}
+
+	// the migration only renames the keys once
+	if renamed, err = store.MigrateLegacyKeys(ctx); err != nil || renamed != 0 {
+		t.Errorf("got %d, %v, want nothing to be renamed again", renamed, err)
+	}
+}
+
+func TestStateStoreLockSlot(t *testing.T) {
+	ctx := context.Background()
+	slot := partitions.Slot{Topic: "locations", Partition: 5}
+	other := partitions.Slot{Topic: "locations", Partition: 6}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			unlock, err := store.LockSlot(ctx, slot, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the lock of another slot is not affected
+			unlockOther, err := store.LockSlot(ctx, other, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unlockOther()
+
+			waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			if _, err := store.LockSlot(waitCtx, slot, time.Minute); err == nil {
+				t.Fatal("want the held lock not to be held again")
+			}
+
+			acquired := make(chan func())
+			go func() {
+				unlock, err := store.LockSlot(ctx, slot, time.Minute)
+				if err != nil {
+					t.Error(err)
+					close(acquired)
+					return
+				}
+				acquired <- unlock
+			}()
+
+			select {
+			case <-acquired:
+				t.Fatal("want the lock to be held until it is released")
+			case <-time.After(30 * time.Millisecond):
+			}
+
+			unlock()
+			select {
+			case unlock := <-acquired:
+				if unlock != nil {
+					unlock()
+				}
+			case <-time.After(time.Second):
+				t.Fatal("want the waiting caller to hold the lock once it is released")
+			}
+		})
+	}
+}
+
+func TestRedisLockSlotWakeup(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	store := NewRedis(client, "partitions", "locations")
+	slot := partitions.Slot{Topic: "locations", Partition: 5}
+
+	lock := func() chan func() {
+		acquired := make(chan func(), 1)
+		go func() {
+			unlock, err := store.LockSlot(ctx, slot, time.Minute)
+			if err != nil {
+				t.Error(err)
+				close(acquired)
+				return
+			}
+			acquired <- unlock
+		}()
+		return acquired
+	}
+
+	unlock, err := store.LockSlot(ctx, slot, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(lockKey(slot)); ttl != time.Minute {
+		t.Errorf("got the ttl %s, want the lock to expire after a minute", ttl)
+	}
+
+	// the waiting caller is woken by the release instead of waiting for the timeout of its wait
+	acquired := lock()
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	select {
+	case unlock = <-acquired:
+	case <-time.After(lockWait / 2):
+		t.Fatal("want the waiting caller to be woken once the lock is released")
+	}
+	if unlock == nil {
+		t.FailNow()
+	}
+
+	// a lock that is not released is held by the waiting caller once it expires
+	acquired = lock()
+	time.Sleep(50 * time.Millisecond)
+	server.FastForward(time.Minute)
+	select {
+	case unlock = <-acquired:
+		if unlock != nil {
+			unlock()
+		}
+	case <-time.After(3 * lockWait):
+		t.Fatal("want the waiting caller to hold the lock once it expires")
+	}
+}
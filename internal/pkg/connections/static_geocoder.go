@@ -0,0 +1,54 @@
+package connections
+
+import (
+	"context"
+	"os"
+
+	"github.com/bytedance/sonic"
+)
+
+// StaticGeocoder is an offline geocoder that resolves addresses from a fixed set of coordinates
+type StaticGeocoder struct {
+	addresses map[string]Geo
+}
+
+// NewStaticGeocoder is a function that is used to create a new static geocoder with the given addresses
+func NewStaticGeocoder(addresses map[string]Geo) *StaticGeocoder {
+	normalized := make(map[string]Geo, len(addresses))
+	for address, geo := range addresses {
+		normalized[NormalizeAddress(address)] = geo
+	}
+
+	return &StaticGeocoder{
+		addresses: normalized,
+	}
+}
+
+// NewStaticGeocoderFromFile is a function that is used to create a new static geocoder from a JSON file
+// that maps addresses to coordinates
+//
+//	{ "Colombo Fort": { "lat": 6.9344, "lon": 79.8428 } }
+func NewStaticGeocoderFromFile(path string) (*StaticGeocoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := map[string]Geo{}
+	err = sonic.Unmarshal(data, &addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticGeocoder(addresses), nil
+}
+
+// Geocode is used to get the coordinates of the given address
+func (s *StaticGeocoder) Geocode(_ context.Context, address string) (Geo, error) {
+	geo, ok := s.addresses[NormalizeAddress(address)]
+	if !ok {
+		return Geo{}, ErrGeocodeNoResults
+	}
+
+	return geo, nil
+}
@@ -2,6 +2,9 @@ package connections
 
 import (
 	"context"
+	"errors"
+	"io"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/env"
@@ -9,18 +12,106 @@ import (
 	"google.golang.org/api/option"
 )
 
-// InitStorage is a function that is used to initialize the Google cloud storage
-func (c *C) InitStorage(e *env.Env) error {
-	key, err := lib.Base64URLDecode(e.GcloudAPIKey)
+// GCS is the archive store that is backed by a Google cloud storage bucket
+type GCS struct {
+	e      *env.Env
+	client *storage.Client
+	mu     sync.Mutex
+}
+
+// NewGCS is a function that is used to create a new Google cloud storage archive store
+//
+// NOTE: The storage client is initialized on the first use, not doing so will effect the
+// startup time of the container
+func NewGCS(e *env.Env) *GCS {
+	return &GCS{
+		e: e,
+	}
+}
+
+// bucket is used to get the configured bucket while initializing the storage client if required
+func (g *GCS) bucket(ctx context.Context) (*storage.BucketHandle, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client == nil {
+		key, err := lib.Base64URLDecode(g.e.GcloudAPIKey)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := storage.NewClient(ctx, option.WithCredentialsJSON(key))
+		if err != nil {
+			return nil, err
+		}
+
+		g.client = client
+	}
+
+	return g.client.Bucket(g.e.BucketName), nil
+}
+
+// Put is used to save the given data under the given name, replacing any existing archive
+func (g *GCS) Put(ctx context.Context, name string, data []byte) error {
+	bucket, err := g.bucket(ctx)
 	if err != nil {
 		return err
 	}
 
-	client, err := storage.NewClient(context.Background(), option.WithCredentialsJSON(key))
+	w := bucket.Object(name).NewWriter(ctx)
+	_, err = w.Write(data)
 	if err != nil {
+		w.Close()
 		return err
 	}
 
-	c.S = client
-	return nil
+	return w.Close()
+}
+
+// Get is used to read the archive under the given name
+func (g *GCS) Get(ctx context.Context, name string) ([]byte, error) {
+	bucket, err := g.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := bucket.Object(name).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Delete is used to remove the archive under the given name
+func (g *GCS) Delete(ctx context.Context, name string) error {
+	bucket, err := g.bucket(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = bucket.Object(name).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrArchiveNotFound
+	}
+
+	return err
+}
+
+// Close is used to close the storage client if it is initialized
+func (g *GCS) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client == nil {
+		return nil
+	}
+
+	err := g.client.Close()
+	g.client = nil
+	return err
 }
@@ -0,0 +1,103 @@
+// Package deviation is used to detect when a driver leaves the expected route of a booking
+package deviation
+
+import (
+	"math"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+)
+
+// returnFactor is how much closer than the threshold the driver has to move to return to the route, so that a
+// driver that moves along the edge of the threshold does not leave and return to the route over and over again
+const returnFactor = 0.8
+
+// EventType is used to classify the deviation events
+type EventType string
+
+const (
+	// Deviated is the event that occurs when the driver moves further away from the route than the threshold
+	Deviated EventType = "deviated"
+	// Returned is the event that occurs when the driver that has deviated comes back to the route
+	Returned EventType = "returned"
+)
+
+// Config contains the settings of the route deviation
+type Config struct {
+	// Threshold is the distance from the route in meters that the driver has to exceed to deviate from it,
+	// zero disables the route deviation
+	Threshold float64
+}
+
+// Point is a coordinate of the expected route
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Event is a deviation from or a return to the expected route
+type Event struct {
+	Type EventType `json:"type"`
+	// Distance is the distance from the route in meters at the time of the event
+	Distance float64 `json:"distance"`
+	// At is the time of the event in unix milliseconds
+	At  int64   `json:"at"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// State is the state of the route deviation of a single booking, it is serialized and kept in the state store
+// between the locations of the booking
+type State struct {
+	// Route is the expected route of the booking, the deviation is not checked when it is empty
+	Route    []Point `json:"route"`
+	Events   []Event `json:"events"`
+	Deviated bool    `json:"deviated"`
+}
+
+// Distance is used to get the shortest distance in meters from the given coordinates to the route
+func (s *State) Distance(lat, lon float64) float64 {
+	if len(s.Route) == 1 {
+		return geo.Distance(lat, lon, s.Route[0].Lat, s.Route[0].Lon)
+	}
+
+	shortest := math.Inf(1)
+	for i := 1; i < len(s.Route); i++ {
+		from, to := s.Route[i-1], s.Route[i]
+		shortest = math.Min(shortest, geo.SegmentDistance(lat, lon, from.Lat, from.Lon, to.Lat, to.Lon))
+	}
+
+	return shortest
+}
+
+// Apply is used to move the driver to the given location, the deviation or the return that occurs at the given
+// location is returned and nil is returned when nothing occurs
+func (s *State) Apply(config Config, lat, lon float64, at time.Time) *Event {
+	if len(s.Route) == 0 || config.Threshold <= 0 {
+		return nil
+	}
+
+	distance := s.Distance(lat, lon)
+
+	var eventType EventType
+	switch {
+	case !s.Deviated && distance > config.Threshold:
+		eventType = Deviated
+	case s.Deviated && distance <= config.Threshold*returnFactor:
+		eventType = Returned
+	default:
+		return nil
+	}
+
+	s.Deviated = eventType == Deviated
+	event := Event{
+		Type:     eventType,
+		Distance: math.Round(distance),
+		At:       at.UnixMilli(),
+		Lat:      lat,
+		Lon:      lon,
+	}
+	s.Events = append(s.Events, event)
+
+	return &event
+}
@@ -0,0 +1,122 @@
+package deviation
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// oneMeter is roughly the number of degrees of longitude in a meter at the equator
+const oneMeter = 1.0 / 111195
+
+var (
+	start  = time.UnixMilli(1700000000000)
+	config = Config{Threshold: 100}
+)
+
+// testState is used to get the state of a booking whose route runs about 2 kilometers east along the equator and
+// then about 2 kilometers north
+func testState() State {
+	return State{
+		Route: []Point{
+			{Lat: 0, Lon: 0},
+			{Lat: 0, Lon: 2000 * oneMeter},
+			{Lat: 2000 * oneMeter, Lon: 2000 * oneMeter},
+		},
+	}
+}
+
+// at is used to get the time that is the given number of seconds after the start
+func at(seconds int) time.Time {
+	return start.Add(time.Duration(seconds) * time.Second)
+}
+
+func TestDistance(t *testing.T) {
+	state := testState()
+
+	cases := map[string]struct {
+		lat, lon float64
+		want     float64
+	}{
+		"on the route":          {0, 1000 * oneMeter, 0},
+		"beside the first leg":  {50 * oneMeter, 1000 * oneMeter, 50},
+		"beside the second leg": {1000 * oneMeter, 2300 * oneMeter, 300},
+		"before the start":      {0, -200 * oneMeter, 200},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := state.Distance(tc.lat, tc.lon); math.Abs(got-tc.want) > 1 {
+				t.Errorf("got %f meters, want %f", got, tc.want)
+			}
+		})
+	}
+
+	// a route with a single point is the distance to the point
+	single := State{Route: []Point{{Lat: 0, Lon: 0}}}
+	if got := single.Distance(0, 100*oneMeter); math.Abs(got-100) > 1 {
+		t.Errorf("got %f meters, want the distance to the only point", got)
+	}
+}
+
+func TestApplyDeviation(t *testing.T) {
+	state := testState()
+
+	// moving along the route and within the threshold is not a deviation
+	for i, offset := range []float64{0, 50, 90} {
+		if event := state.Apply(config, offset*oneMeter, float64(i*500)*oneMeter, at(i)); event != nil {
+			t.Fatalf("got %+v, want no event within the threshold", event)
+		}
+	}
+
+	event := state.Apply(config, 150*oneMeter, 1500*oneMeter, at(10))
+	if event == nil || event.Type != Deviated || event.Distance != 150 || event.At != at(10).UnixMilli() {
+		t.Fatalf("got %+v, want a deviation 150 meters from the route", event)
+	}
+	if !state.Deviated {
+		t.Error("want the state to be deviated")
+	}
+
+	// the driver stays deviated while away from the route
+	if event := state.Apply(config, 300*oneMeter, 1500*oneMeter, at(20)); event != nil {
+		t.Errorf("got %+v, want a single deviation", event)
+	}
+}
+
+func TestApplyReturn(t *testing.T) {
+	state := testState()
+	if event := state.Apply(config, 150*oneMeter, 1000*oneMeter, at(0)); event == nil || event.Type != Deviated {
+		t.Fatalf("got %+v, want a deviation", event)
+	}
+
+	// coming back within the threshold is not a return until the driver is closer than the return factor
+	if event := state.Apply(config, 90*oneMeter, 1000*oneMeter, at(10)); event != nil {
+		t.Fatalf("got %+v, want no return at the edge of the threshold", event)
+	}
+
+	event := state.Apply(config, 20*oneMeter, 1000*oneMeter, at(20))
+	if event == nil || event.Type != Returned || event.Distance != 20 {
+		t.Fatalf("got %+v, want a return 20 meters from the route", event)
+	}
+	if state.Deviated {
+		t.Error("want the state not to be deviated")
+	}
+
+	// every event is kept in the order it occurs
+	if len(state.Events) != 2 || state.Events[0].Type != Deviated || state.Events[1].Type != Returned {
+		t.Errorf("got %+v, want the deviation followed by the return", state.Events)
+	}
+}
+
+func TestApplyDisabled(t *testing.T) {
+	// a booking without a route is never checked
+	var empty State
+	if event := empty.Apply(config, 1, 1, at(0)); event != nil {
+		t.Errorf("got %+v, want no event without a route", event)
+	}
+
+	// a threshold of zero disables the route deviation
+	state := testState()
+	if event := state.Apply(Config{}, 1, 1, at(0)); event != nil || state.Deviated {
+		t.Errorf("got %+v, want no event when the route deviation is disabled", event)
+	}
+}
@@ -12,3 +12,45 @@ const (
 	// Prd represents the production environment
 	Prd Env = "prd"
 )
+
+// Backend is used to select the implementation of a pluggable service
+type Backend string
+
+const (
+	// Redis represents the Redis backend
+	Redis Backend = "redis"
+	// Kafka represents the Kafka backend
+	Kafka Backend = "kafka"
+	// GCS represents the Google cloud storage backend
+	GCS Backend = "gcs"
+	// Local represents the local filesystem backend
+	Local Backend = "local"
+	// S3 represents any S3 compatible object storage backend
+	S3 Backend = "s3"
+	// Google represents the Google maps backend
+	Google Backend = "google"
+	// Straight represents the backend that estimates routes along the straight line between the coordinates
+	Straight Backend = "straight"
+	// OSRM represents the open source routing machine backend
+	OSRM Backend = "osrm"
+	// Static represents the offline backend that is backed by a fixed data set
+	Static Backend = "static"
+	// MSSQL represents the SQL server backend
+	MSSQL Backend = "mssql"
+	// Postgres represents the PostgreSQL backend
+	Postgres Backend = "postgres"
+	// SQLite represents the SQLite backend
+	SQLite Backend = "sqlite"
+	// Memory represents the in process backend that does not depend on external services
+	Memory Backend = "memory"
+)
+
+// Encoding is used to select the encoding of the locations
+type Encoding string
+
+const (
+	// JSON represents the JSON encoding
+	JSON Encoding = "json"
+	// Protobuf represents the protocol buffers encoding
+	Protobuf Encoding = "protobuf"
+)
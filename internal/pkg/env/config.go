@@ -0,0 +1,218 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configFiles contains the names of the config files that are looked up in the config path
+var configFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// field is used to describe how a single field of the schema is loaded
+type field struct {
+	value reflect.Value
+	// key is the key of the field in the config file
+	key string
+	// env is the name of the environment variable of the field
+	env string
+	// def is the default value of the field
+	def string
+}
+
+// fields is a function that is used to get the fields that are loaded from the given schemas
+func fields(schemas ...any) []field {
+	var fields []field
+
+	for _, schema := range schemas {
+		value := reflect.ValueOf(schema).Elem()
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			env := structField.Tag.Get("mapstructure")
+			if !structField.IsExported() || env == "" {
+				continue
+			}
+
+			key := structField.Tag.Get("config")
+			if key == "" {
+				key = strings.ToLower(env)
+			}
+
+			fields = append(fields, field{
+				value: value.Field(i),
+				key:   key,
+				env:   env,
+				def:   structField.Tag.Get("default"),
+			})
+		}
+	}
+
+	return fields
+}
+
+// load is used to fill the schema and the given settings from every layer of the configuration
+func (e *Env) load(settings *Settings) error {
+	v := viper.New()
+	fields := fields(e, settings)
+
+	for _, f := range fields {
+		if f.def != "" {
+			v.SetDefault(f.key, f.def)
+		}
+	}
+
+	envs, err := e.environ()
+	if err != nil {
+		return err
+	}
+
+	file, err := e.configFile(envs)
+	if err != nil {
+		return err
+	}
+	if file != "" {
+		v.SetConfigFile(file)
+		if err = v.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+
+	profile := envs["PROFILE"]
+	if profile == "" {
+		profile = v.GetString("profile")
+	}
+	if profile == "" {
+		profile = envs["ENV"]
+	}
+	if profile == "" {
+		profile = v.GetString("env")
+	}
+	if section := v.GetStringMap("profiles." + profile); profile != "" && len(section) > 0 {
+		if err = v.MergeConfigMap(section); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		if val, ok := envs[f.env]; ok {
+			v.Set(f.key, val)
+		}
+	}
+
+	for _, f := range fields {
+		if !v.IsSet(f.key) {
+			continue
+		}
+		if err = f.set(v.Get(f.key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// set is used to set the given value to the field while converting it to the type of the field
+func (f field) set(val any) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(fmt.Sprint(val))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(strings.TrimSpace(fmt.Sprint(val)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s as int: %v", f.env, err)
+		}
+
+		f.value.SetInt(v)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(strings.TrimSpace(fmt.Sprint(val)))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s as bool: %v", f.env, err)
+		}
+
+		f.value.SetBool(v)
+	case reflect.Slice:
+		if f.value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type for field %s", f.env)
+		}
+
+		var items []string
+		switch val := val.(type) {
+		case []any:
+			for _, item := range val {
+				items = append(items, fmt.Sprint(item))
+			}
+		case []string:
+			items = val
+		default:
+			// lists in the environment variables are comma separated
+			for _, item := range strings.Split(fmt.Sprint(val), ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type for field %s", f.env)
+	}
+
+	return nil
+}
+
+// environ is used to get the variables of the .env file along with the environment variables where the
+// environment variables take precedence
+func (e *Env) environ() (map[string]string, error) {
+	envs := make(map[string]string)
+
+	dotenv := filepath.Join(e.configPath, e.dotenvFile)
+	_, err := os.Stat(dotenv)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		v := viper.New()
+		v.SetConfigFile(dotenv)
+		v.SetConfigType("env")
+		if err = v.ReadInConfig(); err != nil {
+			return nil, err
+		}
+
+		for key, val := range v.AllSettings() {
+			envs[strings.ToUpper(key)] = fmt.Sprint(val)
+		}
+	}
+
+	for _, s := range os.Environ() {
+		key, val, _ := strings.Cut(s, "=")
+		envs[key] = val
+	}
+
+	return envs, nil
+}
+
+// configFile is used to find the config file, the file in CONFIG_FILE is used when it is provided
+func (e *Env) configFile(envs map[string]string) (string, error) {
+	if file := envs["CONFIG_FILE"]; file != "" {
+		return file, nil
+	}
+
+	for _, name := range configFiles {
+		file := filepath.Join(e.configPath, name)
+		_, err := os.Stat(file)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,249 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testConfig is a config file that contains every required field
+const testConfig = `
+env: dev
+database: sqlite
+location_bus: memory
+state_store: memory
+archive_store: local
+geocoder: static
+db:
+  url: "file::memory:"
+archive:
+  path: /tmp/archive
+maps:
+  static_file: addresses.json
+secrets:
+  booking_token: booking
+  driver_token: driver
+  admin: admin
+  admin_token: admin_token
+partitions:
+  manager_key: Jobs
+  topic: locations
+  total: 4
+cookies:
+  driver: driver
+  booking: booking
+  admin: admin
+websocket:
+  url: ws://localhost
+  max_connections: 10
+booking_token_expires_in: 3600
+`
+
+// unsetenv is used to remove the given environment variables for the duration of the test, so that the variables
+// of the machine that runs the tests do not change the configuration
+func unsetenv(t *testing.T, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+// write is used to write the given files to the given directory
+func write(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+		env   map[string]string
+		want  int
+	}{
+		"default": {
+			want: 5,
+		},
+		"config file": {
+			files: map[string]string{"config.yaml": "websocket:\n  heartbeat: 6\n"},
+			want:  6,
+		},
+		"profile": {
+			files: map[string]string{"config.yaml": "profile: live\nwebsocket:\n  heartbeat: 6\nprofiles:\n  live:\n    websocket:\n      heartbeat: 7\n"},
+			want:  7,
+		},
+		"dotenv": {
+			files: map[string]string{
+				"config.yaml": "profile: live\nprofiles:\n  live:\n    websocket:\n      heartbeat: 7\n",
+				".env":        "HEARTBEAT=8\n",
+			},
+			want: 8,
+		},
+		"environment variable": {
+			files: map[string]string{
+				"config.yaml": "profile: live\nprofiles:\n  live:\n    websocket:\n      heartbeat: 7\n",
+				".env":        "HEARTBEAT=8\n",
+			},
+			env:  map[string]string{"HEARTBEAT": "9"},
+			want: 9,
+		},
+		"config file in CONFIG_FILE": {
+			files: map[string]string{
+				"config.yaml": "websocket:\n  heartbeat: 6\n",
+				"other.toml":  "[websocket]\nheartbeat = 10\n",
+			},
+			env:  map[string]string{"CONFIG_FILE": "other.toml"},
+			want: 10,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			write(t, dir, test.files)
+			unsetenv(t, "HEARTBEAT", "PROFILE", "ENV", "CONFIG_FILE")
+			for key, val := range test.env {
+				if key == "CONFIG_FILE" {
+					val = filepath.Join(dir, val)
+				}
+				t.Setenv(key, val)
+			}
+
+			e := &Env{configPath: dir, dotenvFile: ".env"}
+			settings := &Settings{}
+			if err := e.load(settings); err != nil {
+				t.Fatal(err)
+			}
+			if settings.Heartbeat != test.want {
+				t.Errorf("got the heartbeat %d, want %d", settings.Heartbeat, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	config := `
+env: stg
+profiles:
+  stg:
+    rate_limit:
+      requests: 1
+  prd:
+    rate_limit:
+      requests: 2
+  live:
+    rate_limit:
+      requests: 3
+  fast:
+    rate_limit:
+      requests: 4
+`
+
+	tests := map[string]struct {
+		config string
+		env    map[string]string
+		want   int
+	}{
+		"env in the config file":                {config: config, want: 1},
+		"env in the environment":                {config: config, env: map[string]string{"ENV": "prd"}, want: 2},
+		"profile in the config file":            {config: config + "profile: live\n", env: map[string]string{"ENV": "prd"}, want: 3},
+		"profile in the environment":            {config: config + "profile: live\n", env: map[string]string{"PROFILE": "fast"}, want: 4},
+		"profile without a section in the file": {config: config, env: map[string]string{"PROFILE": "missing"}, want: 100},
+		"profile without an env":                {config: "profiles:\n  live:\n    rate_limit:\n      requests: 3\n", env: map[string]string{"PROFILE": "live"}, want: 3},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			write(t, dir, map[string]string{"config.yaml": test.config})
+			unsetenv(t, "RATE_LIMIT", "PROFILE", "ENV", "CONFIG_FILE")
+			for key, val := range test.env {
+				t.Setenv(key, val)
+			}
+
+			e := &Env{configPath: dir, dotenvFile: ".env"}
+			settings := &Settings{}
+			if err := e.load(settings); err != nil {
+				t.Fatal(err)
+			}
+			if settings.RateLimit != test.want {
+				t.Errorf("got the rate limit %d, want %d", settings.RateLimit, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadTypes(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"config.yaml": "cors:\n  origins:\n    - https://a.com\n    - https://b.com\n"})
+	unsetenv(t, "CORS_ORIGINS", "AUTO_STATUS", "PORT", "CONFIG_FILE", "PROFILE", "ENV")
+	t.Setenv("AUTO_STATUS", "true")
+	t.Setenv("PORT", " 9090 ")
+
+	e := &Env{configPath: dir, dotenvFile: ".env"}
+	settings := &Settings{}
+	if err := e.load(settings); err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.CORSOrigins) != 2 || settings.CORSOrigins[1] != "https://b.com" {
+		t.Errorf("got the origins %v, want the list of the config file", settings.CORSOrigins)
+	}
+	if !settings.AutoStatus || e.Port != 9090 {
+		t.Errorf("got %t, %d, want the environment variables to be parsed", settings.AutoStatus, e.Port)
+	}
+
+	// the lists in the environment variables are comma separated
+	t.Setenv("CORS_ORIGINS", "https://c.com, https://d.com,")
+	if err := e.load(settings); err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.CORSOrigins) != 2 || settings.CORSOrigins[1] != "https://d.com" {
+		t.Errorf("got the origins %v, want the list of the environment variable", settings.CORSOrigins)
+	}
+
+	t.Setenv("PORT", "eighty")
+	if err := e.load(settings); err == nil {
+		t.Error("want a port that is not a number to be rejected")
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"config.yaml": testConfig + "port: 8080\nrate_limit:\n  requests: 10\n"})
+	unsetenv(t, "PORT", "RATE_LIMIT", "HEARTBEAT", "CONFIG_FILE", "PROFILE", "ENV")
+
+	e := &Env{configPath: dir, dotenvFile: ".env"}
+	settings := &Settings{}
+	if err := e.load(settings); err != nil {
+		t.Fatal(err)
+	}
+	e.settings.Store(settings)
+
+	// only the settings are applied, the rest of the configuration keeps its value until the server restarts
+	write(t, dir, map[string]string{"config.yaml": testConfig + "port: 9090\nrate_limit:\n  requests: 20\n"})
+	t.Setenv("HEARTBEAT", "11")
+	if err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Settings(); got.RateLimit != 20 || got.Heartbeat != 11 {
+		t.Errorf("got the rate limit %d and the heartbeat %d, want 20 and 11", got.RateLimit, got.Heartbeat)
+	}
+	if e.Port != 8080 {
+		t.Errorf("got the port %d, want the port to stay at 8080", e.Port)
+	}
+
+	// the settings are kept as they are when the configuration is not valid
+	write(t, dir, map[string]string{"config.yaml": testConfig + "rate_limit:\n  requests: 0\n"})
+	if err := e.Reload(); err == nil {
+		t.Error("want the invalid settings to be rejected")
+	}
+	if got := e.Settings(); got.RateLimit != 20 {
+		t.Errorf("got the rate limit %d, want the previous settings to be kept", got.RateLimit)
+	}
+}
@@ -2,69 +2,104 @@
 package env
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"reflect"
-	"strconv"
-	"strings"
+	"sync/atomic"
 
 	"github.com/VinukaThejana/go-utils/logger"
 	"github.com/flitlabs/spotoncars_stream/internal/pkg/lib"
-	"github.com/spf13/viper"
 )
 
 // Env contains the env schema
+//
+// Every field is loaded from the layers of the configuration, the mapstructure tag is the name of the
+// environment variable, the config tag is the key in the config file and the default tag is the value
+// that is used when none of the layers provide the field
 type Env struct {
-	KafkaUsername       string `mapstructure:"KAFKA_USERNAME" validate:"required"`
-	KafkaPassword       string `mapstructure:"KAFKA_PASSWORD" validate:"required"`
-	KafkaBroker         string `mapstructure:"KAFKA_BROKER" validate:"required"`
-	KafkaRestURL        string `mapstructure:"KAFKA_REST_URL" validate:"required,url"`
-	RedisDBURL          string `mapstructure:"REDIS_DB_URL" validate:"required"`
-	Domain              string `mapstructure:"DOMAIN" validate:"required,url"`
-	Host                string `mapstructure:"HOST" validate:"required"`
-	APIDoc              string `mapstructure:"API_DOC" validate:"required,url"`
-	BookingTokenSecret  string `mapstructure:"BOOKING_TOKEN_SECRET" validate:"required"`
-	PartitionManagerKey string `mapstructure:"PARTITION_MANAGER_KEY" validate:"required"`
-	Topic               string `mapstructure:"TOPIC" validate:"required"`
-	DBUser              string `mapstructure:"DB_USER" validate:"required"`
-	DBPassword1         string `mapstructure:"DB_PASSWORD_1" validate:"required"`
-	DBPassword2         string `mapstructure:"DB_PASSWORD_2" validate:"required"`
-	DBHost              string `mapstructure:"DB_HOST" validate:"required"`
-	DBDatabase          string `mapstructure:"DB_DATABASE" validate:"required"`
-	DriverTokenSecret   string `mapstructure:"DRIVER_TOKEN_SECRET" validate:"required"`
-	AdminSecret         string `mapstructure:"ADMIN_SECRET" validate:"required"`
-	GoogleMapsAPIKey    string `mapstructure:"GOOGLE_MAPS_API_KEY" validate:"required"`
-	GcloudAPIKey        string `mapstructure:"GCLOUD_API" validate:"required"`
-	BucketName          string `mapstructure:"BUCKET_NAME" validate:"required"`
-	Env                 string `mapstructure:"ENV" validate:"required"`
-	AdminTokenSecret    string `mapstructure:"ADMIN_TOKEN_SECRET" validate:"required"`
-	DriverCookieName    string `mapstructure:"DRIVER_COOKIE_NAME" validate:"required"`
-	BookingCookieName   string `mapstructure:"BOOKING_COOKIE_NAME" validate:"required"`
-	AdminCookieName     string `mapstructure:"ADMIN_COOKIE_NAME" validate:"required"`
-	BookingToken        string `mapstructure:"BOOKING_TOKEN"`
-	AdminToken          string `mapstructure:"ADMIN_TOKEN"`
-	StgURL              string `mapstructure:"STG_URL"`
-	PrdURL              string `mapstructure:"PRD_URL"`
-	VercelToken         string `mapstructure:"VERCEL_TOKEN" validate:"required"`
-	EdgeConfig          string `mapstructure:"EDGE_CONFIG" validate:"required"`
-	EdgeConfigReadToken string `mapstructure:"EDGECONFIG_READ_TOKEN" validate:"required"`
-	WebsocketURL        string `mapstructure:"WEBSOCKET_URL" validate:"required"`
-	DashboardURL        string `mapstructure:"DASHBOARD_URL" validate:"required"`
-	TotalPartitions     int    `mapstructure:"TOTAL_PARTITIONS" validate:"required"`
-	BookingTokenExpires int    `mapstructure:"BOOKING_TOKEN_EXPIRES_IN" validate:"required"`
-	DBPassword3         int    `mapstructure:"DB_PASSWORD_3" validate:"required"`
-	Port                int    `mapstructure:"PORT" validate:"required"`
-	DBPort              int    `mapstructure:"DB_PORT" validate:"required"`
-	MaxConnections      int    `mapstructure:"MAX_CONNECTIONS" validate:"required"`
+	// settings contains the settings that are reloaded while the server is running
+	settings atomic.Pointer[Settings]
+	// configPath is the directory that contains the config file and the .env file
+	configPath string
+	// dotenvFile is the name of the .env file
+	dotenvFile string
+
+	KafkaUsername       string `mapstructure:"KAFKA_USERNAME" config:"kafka.username" validate:"required_unless=LocationBus memory"`
+	KafkaPassword       string `mapstructure:"KAFKA_PASSWORD" config:"kafka.password" validate:"required_unless=LocationBus memory"`
+	KafkaBroker         string `mapstructure:"KAFKA_BROKER" config:"kafka.broker" validate:"required_unless=LocationBus memory"`
+	KafkaRestURL        string `mapstructure:"KAFKA_REST_URL" config:"kafka.rest_url" validate:"required_unless=LocationBus memory,omitempty,url"`
+	LocationBus         string `mapstructure:"LOCATION_BUS" config:"location_bus" validate:"omitempty,oneof=kafka memory"`
+	RedisDBURL          string `mapstructure:"REDIS_DB_URL" config:"redis.url" validate:"required_unless=StateStore memory"`
+	StateStore          string `mapstructure:"STATE_STORE" config:"state_store" validate:"omitempty,oneof=redis memory"`
+	Domain              string `mapstructure:"DOMAIN" config:"domain" validate:"omitempty,url"`
+	Host                string `mapstructure:"HOST" config:"host"`
+	APIDoc              string `mapstructure:"API_DOC" config:"api_doc" validate:"omitempty,url"`
+	BookingTokenSecret  string `mapstructure:"BOOKING_TOKEN_SECRET" config:"secrets.booking_token" validate:"required"`
+	PartitionManagerKey string `mapstructure:"PARTITION_MANAGER_KEY" config:"partitions.manager_key" validate:"required"`
+	Topic               string `mapstructure:"TOPIC" config:"partitions.topic" validate:"required"`
+	Database            string `mapstructure:"DATABASE" config:"database" default:"mssql" validate:"required,oneof=mssql postgres sqlite"`
+	DatabaseURL         string `mapstructure:"DATABASE_URL" config:"db.url" validate:"required_unless=Database mssql"`
+	DBUser              string `mapstructure:"DB_USER" config:"db.user" validate:"required_if=Database mssql"`
+	DBPassword1         string `mapstructure:"DB_PASSWORD_1" config:"db.password_1" validate:"required_if=Database mssql"`
+	DBPassword2         string `mapstructure:"DB_PASSWORD_2" config:"db.password_2" validate:"required_if=Database mssql"`
+	DBHost              string `mapstructure:"DB_HOST" config:"db.host" validate:"required_if=Database mssql"`
+	DBDatabase          string `mapstructure:"DB_DATABASE" config:"db.database" validate:"required_if=Database mssql"`
+	DriverTokenSecret   string `mapstructure:"DRIVER_TOKEN_SECRET" config:"secrets.driver_token" validate:"required"`
+	AdminSecret         string `mapstructure:"ADMIN_SECRET" config:"secrets.admin" validate:"required"`
+	GoogleMapsAPIKey    string `mapstructure:"GOOGLE_MAPS_API_KEY" config:"maps.api_key" validate:"required_if=Geocoder google,required_if=Router google"`
+	Geocoder            string `mapstructure:"GEOCODER" config:"geocoder" default:"google" validate:"required,oneof=google static"`
+	GeocoderStaticFile  string `mapstructure:"GEOCODER_STATIC_FILE" config:"maps.static_file" validate:"required_if=Geocoder static"`
+	Router              string `mapstructure:"ROUTER" config:"routing.backend" default:"straight" validate:"required,oneof=straight osrm google"`
+	OSRMURL             string `mapstructure:"OSRM_URL" config:"routing.osrm_url" validate:"required_if=Router osrm,omitempty,url"`
+	GcloudAPIKey        string `mapstructure:"GCLOUD_API" config:"gcs.api_key" validate:"required_if=ArchiveStore gcs"`
+	BucketName          string `mapstructure:"BUCKET_NAME" config:"archive.bucket" validate:"required_unless=ArchiveStore local"`
+	ArchiveStore        string `mapstructure:"ARCHIVE_STORE" config:"archive_store" default:"gcs" validate:"required,oneof=gcs local s3"`
+	ArchivePath         string `mapstructure:"ARCHIVE_PATH" config:"archive.path" validate:"required_if=ArchiveStore local"`
+	S3Endpoint          string `mapstructure:"S3_ENDPOINT" config:"s3.endpoint" validate:"required_if=ArchiveStore s3"`
+	S3AccessKey         string `mapstructure:"S3_ACCESS_KEY" config:"s3.access_key" validate:"required_if=ArchiveStore s3"`
+	S3SecretKey         string `mapstructure:"S3_SECRET_KEY" config:"s3.secret_key" validate:"required_if=ArchiveStore s3"`
+	S3Region            string `mapstructure:"S3_REGION" config:"s3.region"`
+	Env                 string `mapstructure:"ENV" config:"env" validate:"required"`
+	Profile             string `mapstructure:"PROFILE" config:"profile"`
+	AdminTokenSecret    string `mapstructure:"ADMIN_TOKEN_SECRET" config:"secrets.admin_token" validate:"required"`
+	DriverCookieName    string `mapstructure:"DRIVER_COOKIE_NAME" config:"cookies.driver" validate:"required"`
+	BookingCookieName   string `mapstructure:"BOOKING_COOKIE_NAME" config:"cookies.booking" validate:"required"`
+	AdminCookieName     string `mapstructure:"ADMIN_COOKIE_NAME" config:"cookies.admin" validate:"required"`
+	BookingToken        string `mapstructure:"BOOKING_TOKEN" config:"testing.booking_token"`
+	AdminToken          string `mapstructure:"ADMIN_TOKEN" config:"testing.admin_token"`
+	StgURL              string `mapstructure:"STG_URL" config:"testing.stg_url"`
+	PrdURL              string `mapstructure:"PRD_URL" config:"testing.prd_url"`
+	VercelToken         string `mapstructure:"VERCEL_TOKEN" config:"vercel.token" validate:"required_with=EdgeConfig"`
+	EdgeConfig          string `mapstructure:"EDGE_CONFIG" config:"vercel.edge_config"`
+	EdgeConfigReadToken string `mapstructure:"EDGECONFIG_READ_TOKEN" config:"vercel.edge_config_read_token" validate:"required_with=EdgeConfig"`
+	WebsocketURL        string `mapstructure:"WEBSOCKET_URL" config:"websocket.url" validate:"required"`
+	DashboardURL        string `mapstructure:"DASHBOARD_URL" config:"vercel.dashboard_url" validate:"required_with=EdgeConfig"`
+	TotalPartitions     int    `mapstructure:"TOTAL_PARTITIONS" config:"partitions.total" validate:"required"`
+	BookingTokenExpires int    `mapstructure:"BOOKING_TOKEN_EXPIRES_IN" config:"booking_token_expires_in" validate:"required"`
+	DBPassword3         int    `mapstructure:"DB_PASSWORD_3" config:"db.password_3" validate:"required_if=Database mssql"`
+	Port                int    `mapstructure:"PORT" config:"port" default:"8080" validate:"required"`
+	DBPort              int    `mapstructure:"DB_PORT" config:"db.port" validate:"required_if=Database mssql"`
+	// RouteSpeed is the average speed of the straight line router in kilometers per hour
+	RouteSpeed       int `mapstructure:"ROUTE_SPEED" config:"routing.speed" default:"30" validate:"gt=0"`
+	GeocodeCacheSize int `mapstructure:"GEOCODE_CACHE_SIZE" config:"maps.cache_size" default:"1024" validate:"gte=0"`
+	// GeocodeCacheTTL defaults to 30 days
+	GeocodeCacheTTL int `mapstructure:"GEOCODE_CACHE_TTL" config:"maps.cache_ttl" default:"2592000" validate:"gte=0"`
+	// ReservationTimeout defaults to 2 minutes
+	ReservationTimeout int  `mapstructure:"RESERVATION_TIMEOUT" config:"reservation_timeout" default:"120" validate:"gte=0"`
+	S3Insecure         bool `mapstructure:"S3_INSECURE" config:"s3.insecure"`
 }
 
 // Load is a function that is used to Load environment variables
+//
+// The configuration is built from the following layers where every layer overrides the layers before it
+//  1. the default values of the schema
+//  2. the config file (config.yaml, config.yml or config.toml in the config path or the file in CONFIG_FILE)
+//  3. the section of the selected profile in the config file (profiles.<profile>)
+//  4. the .env file
+//  5. the environment variables
+//
+// The profile is selected with PROFILE and falls back to ENV
 func (e *Env) Load(path ...string) {
-	configPath := "."
-	configFile := ".env"
-
-	v := viper.New()
+	e.configPath = "."
+	e.dotenvFile = ".env"
 
 	if len(path) > 2 {
 		logger.Errorf(fmt.Errorf("invalid set of parameters are provided"))
@@ -72,76 +107,21 @@ func (e *Env) Load(path ...string) {
 
 	if len(path) > 0 {
 		if len(path) == 2 {
-			configFile = path[1]
-		}
-		configPath = path[0]
-
-		if strings.HasSuffix(path[0], "/") {
-			configFile = fmt.Sprintf("%s%s", configPath, configFile)
-		} else {
-			configFile = fmt.Sprintf("%s/%s", configPath, configFile)
+			e.dotenvFile = path[1]
 		}
+		e.configPath = path[0]
 	}
 
-	_, err := os.Stat(configFile)
-	if err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			lib.LogFatal(err)
-		}
-
-		lib.LogFatal(parseEnvVars(environ(), e))
-	} else {
-		v.AddConfigPath(configPath)
-		v.SetConfigFile(configFile)
-
-		lib.LogFatal(v.ReadInConfig())
-		lib.LogFatal(v.Unmarshal(&e))
-	}
+	settings := &Settings{}
+	lib.LogFatal(e.load(settings))
 
 	logger.Validatef(e)
-}
-
-func environ() map[string]string {
-	m := make(map[string]string)
-	for _, s := range os.Environ() {
-		a := strings.Split(s, "=")
-		m[a[0]] = a[1]
-	}
+	logger.Validatef(settings)
 
-	return m
+	e.settings.Store(settings)
 }
 
-func parseEnvVars(envMap map[string]string, e interface{}) error {
-	objValue := reflect.ValueOf(e).Elem()
-	objType := objValue.Type()
-
-	for i := 0; i < objType.NumField(); i++ {
-		field := objType.Field(i)
-		envKey := field.Tag.Get("mapstructure")
-		envValue, ok := envMap[envKey]
-		if !ok {
-			continue
-		}
-
-		fieldValue := objValue.Field(i)
-		if !fieldValue.CanSet() {
-			return fmt.Errorf("field %s is not settable", field.Name)
-		}
-
-		switch fieldValue.Kind() {
-		case reflect.String:
-			fieldValue.SetString(envValue)
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			val, err := strconv.ParseInt(envValue, 10, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse %s as int: %v", envKey, err)
-			}
-
-			fieldValue.SetInt(val)
-		default:
-			return fmt.Errorf("unsupported type for field %s", field.Name)
-		}
-	}
-
-	return nil
+// HasEdgeConfig is used to check wether the Vercel edge config integration is configured
+func (e *Env) HasEdgeConfig() bool {
+	return e.EdgeConfig != ""
 }
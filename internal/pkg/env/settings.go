@@ -0,0 +1,115 @@
+package env
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog/log"
+)
+
+// Settings contains the settings that are safe to change while the server is running, they are reloaded
+// along with the rest of the configuration when the server receives a SIGHUP
+type Settings struct {
+	// CORSOrigins contains the origins that are allowed to make cross origin requests, an origin can contain a single wildcard
+	CORSOrigins []string `mapstructure:"CORS_ORIGINS" config:"cors.origins" default:"https://*,http://*" validate:"required,dive,required"`
+	// RateLimit is the number of requests that a single IP address can make within the rate limit window
+	RateLimit int `mapstructure:"RATE_LIMIT" config:"rate_limit.requests" default:"100" validate:"gt=0"`
+	// RateLimitWindow is the length of the rate limit window in seconds
+	RateLimitWindow int `mapstructure:"RATE_LIMIT_WINDOW" config:"rate_limit.window" default:"60" validate:"gt=0"`
+	// MaxConnections is the maximum number of viewers of a single stream
+	MaxConnections int `mapstructure:"MAX_CONNECTIONS" config:"websocket.max_connections" validate:"required"`
+	// Heartbeat is the frequency to send a ping to the websocket client in seconds
+	Heartbeat int `mapstructure:"HEARTBEAT" config:"websocket.heartbeat" default:"5" validate:"gt=0"`
+	// UpdateInterval is the number of locations that are received before the last location is saved in the state store
+	UpdateInterval int `mapstructure:"UPDATE_INTERVAL" config:"websocket.update_interval" default:"5" validate:"gt=0"`
+	// ClockSkew is the number of seconds that the device time of a location can be ahead of the time of the server
+	ClockSkew int `mapstructure:"CLOCK_SKEW" config:"locations.clock_skew" default:"60" validate:"gte=0"`
+	// MaxLocationAge is the number of seconds that the device time of a location can be behind the time of the server
+	MaxLocationAge int `mapstructure:"MAX_LOCATION_AGE" config:"locations.max_age" default:"43200" validate:"gt=0"`
+	// Filter is the filter that the locations are passed through before they are published
+	Filter string `mapstructure:"GPS_FILTER" config:"locations.filter" default:"kalman" validate:"oneof=none speed kalman"`
+	// MaxSpeed is the speed in meters per second that a driver can not move faster than, the locations that
+	// are further away than the driver can reach at this speed are rejected
+	MaxSpeed int `mapstructure:"MAX_SPEED" config:"locations.max_speed" default:"70" validate:"gt=0"`
+	// MaxAccuracy is the largest accuracy radius in meters that is accepted, zero accepts every accuracy
+	MaxAccuracy int `mapstructure:"MAX_ACCURACY" config:"locations.max_accuracy" default:"200" validate:"gte=0"`
+	// GeofenceRadius is the radius of the fences around the stops of a booking in meters
+	GeofenceRadius int `mapstructure:"GEOFENCE_RADIUS" config:"geofence.radius" default:"100" validate:"gt=0"`
+	// GeofenceDwell is the number of seconds that the driver has to stay inside a fence to arrive at the stop
+	GeofenceDwell int `mapstructure:"GEOFENCE_DWELL" config:"geofence.dwell" default:"30" validate:"gte=0"`
+	// AutoStatus is used to advance the job status with the arrivals at and the departures from the pickups
+	AutoStatus bool `mapstructure:"AUTO_STATUS" config:"geofence.auto_status"`
+	// DeviationThreshold is the distance from the expected route in meters that the driver has to exceed to
+	// deviate from it, zero disables the route deviation
+	DeviationThreshold int `mapstructure:"DEVIATION_THRESHOLD" config:"deviation.threshold" default:"150" validate:"gte=0"`
+	// StaleAfter is the number of seconds without a location after which the signal of the driver is stale
+	StaleAfter int `mapstructure:"STALE_AFTER" config:"signal.stale_after" default:"30" validate:"gt=0"`
+	// LostAfter is the number of seconds without a location after which the signal of the driver is lost
+	LostAfter int `mapstructure:"LOST_AFTER" config:"signal.lost_after" default:"120" validate:"gtfield=StaleAfter"`
+	// ETAInterval is the frequency to send the time of arrival to the viewers in seconds
+	ETAInterval int `mapstructure:"ETA_INTERVAL" config:"websocket.eta_interval" default:"15" validate:"gt=0"`
+	// Pending is the deadline to keep waiting for the location bus in seconds
+	Pending int `mapstructure:"PENDING" config:"websocket.pending" default:"2" validate:"gt=0"`
+	// TrailTolerance is the largest distance in meters that a left out location can be from the simplified path
+	// that is sent to the viewers that ask for the path so far
+	TrailTolerance int `mapstructure:"TRAIL_TOLERANCE" config:"trail.tolerance" default:"10" validate:"gte=0"`
+	// TrailMaxPoints is the largest number of locations of the path so far that is sent to a viewer
+	TrailMaxPoints int `mapstructure:"TRAIL_MAX_POINTS" config:"trail.max_points" default:"500" validate:"gte=2"`
+	// BusEncoding is the encoding of the locations that are published to the location bus, the locations of
+	// both encodings are read so the encoding can be changed while the bookings are active
+	BusEncoding string `mapstructure:"BUS_ENCODING" config:"locations.bus_encoding" default:"json" validate:"oneof=json protobuf"`
+}
+
+// ClockSkewWindow is used to get how far ahead and how far behind the time of the server the device time of a location can be
+func (s *Settings) ClockSkewWindow() (skew, maxAge time.Duration) {
+	return time.Duration(s.ClockSkew) * time.Second, time.Duration(s.MaxLocationAge) * time.Second
+}
+
+// Settings is used to get the current settings
+func (e *Env) Settings() *Settings {
+	return e.settings.Load()
+}
+
+// Reload is used to load the configuration again and to apply the settings that are safe to change while the
+// server is running, the rest of the configuration keeps its value until the server restarts
+func (e *Env) Reload() error {
+	next := &Env{
+		configPath: e.configPath,
+		dotenvFile: e.dotenvFile,
+	}
+	settings := &Settings{}
+
+	err := next.load(settings)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if err = v.Struct(next); err != nil {
+		return err
+	}
+	if err = v.Struct(settings); err != nil {
+		return err
+	}
+
+	current := reflect.ValueOf(e).Elem()
+	updated := reflect.ValueOf(next).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if !reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			log.Warn().
+				Msgf(
+					"key : %s\tthe value has changed, restart the server to apply it",
+					field.Tag.Get("mapstructure"),
+				)
+		}
+	}
+
+	e.settings.Store(settings)
+	return nil
+}
@@ -1,21 +1,143 @@
 // Package errors contains essential errors
 package errors
 
-import "fmt"
+import (
+	ers "errors"
+	"maps"
+	"net/http"
+)
+
+// Code is a stable machine readable code that is used to identify an error
+type Code string
+
+const (
+	// CodeServer is the code of an internal server error
+	CodeServer Code = "server_error"
+	// CodeUnauthorized is the code of an error that occurs when the user is not authenticated or authorized
+	CodeUnauthorized Code = "unauthorized"
+	// CodeBadRequest is the code of an error that occurs when the request contains invalid data
+	CodeBadRequest Code = "bad_request"
+	// CodeNotAdmin is the code of an error that occurs when the requesting user is not an admin
+	CodeNotAdmin Code = "not_admin"
+	// CodeBookingIDNotValid is the code of an error that occurs when the booking id is not valid
+	CodeBookingIDNotValid Code = "booking_id_not_valid"
+	// CodeBookingNotFound is the code of an error that occurs when there is no booking with the given booking id
+	CodeBookingNotFound Code = "booking_not_found"
+	// CodeReservationNotValid is the code of an error that occurs when the reservation id is not valid
+	CodeReservationNotValid Code = "reservation_id_not_valid"
+	// CodeReservationNotFound is the code of an error that occurs when the reservation does not exist or it has expired
+	CodeReservationNotFound Code = "reservation_not_found"
+	// CodeUnsupportedMedia is the code of an error that occurs when the request body can not be decoded
+	CodeUnsupportedMedia Code = "unsupported_media"
+	// CodeUnsupportedContentType is the code of an error that occurs when the content type of the request is not supported
+	CodeUnsupportedContentType Code = "unsupported_content_type"
+	// CodeRequestTooLarge is the code of an error that occurs when the request body is too large
+	CodeRequestTooLarge Code = "request_too_large"
+	// CodeTooManyRequests is the code of an error that occurs when the client is rate limited
+	CodeTooManyRequests Code = "too_many_requests"
+	// CodeTooManyViewers is the code of an error that occurs when a stream has reached the maximum number of viewers
+	CodeTooManyViewers Code = "too_many_viewers"
+	// CodeRecordedAtOutOfRange is the code of an error that occurs when the device time of a location is outside of the clock skew window
+	CodeRecordedAtOutOfRange Code = "recorded_at_out_of_range"
+	// CodeInvalidTransition is the code of an error that occurs when the job can not move from its status to the given status
+	CodeInvalidTransition Code = "invalid_status_transition"
+	// CodeUnknownTopic is the code of an error that occurs when the topic does not exist in the location bus
+	CodeUnknownTopic Code = "unknown_topic"
+	// CodePrimaryTopic is the code of an error that occurs when the primary topic is added to the pool as an extra topic
+	CodePrimaryTopic Code = "primary_topic"
+	// CodeNotFound is the code of an error that occurs when the requested route does not exist
+	CodeNotFound Code = "not_found"
+	// CodeMethodNotAllowed is the code of an error that occurs when the route does not support the request method
+	CodeMethodNotAllowed Code = "method_not_allowed"
+)
+
+// Error is an error that carries a stable code, the HTTP status it maps to and optional details
+type Error struct {
+	// Details contains optional machine readable information about the error
+	Details map[string]any
+	Code    Code
+	Message string
+	Status  int
+}
+
+// New is a function that is used to create a new error with the given code, HTTP status and message
+func New(code Code, status int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	}
+}
+
+// Error is used to get the human readable message of the error
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Is is used to match errors by their code, so that errors with details still match the original error
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == err.Code
+}
+
+// WithDetails is used to get a copy of the error with the given details added to it
+func (err *Error) WithDetails(details map[string]any) *Error {
+	e := *err
+	e.Details = make(map[string]any, len(err.Details)+len(details))
+	maps.Copy(e.Details, err.Details)
+	maps.Copy(e.Details, details)
+
+	return &e
+}
+
+// From is a function that is used to get the Error from the given error, errors that do not carry a code
+// are reported as internal server errors so that the internal details are not leaked to the client
+func From(err error) *Error {
+	var e *Error
+	if ers.As(err, &e) {
+		return e
+	}
+
+	return ErrServer
+}
 
 var (
 	// ErrServer is to indicate an internal server error
-	ErrServer = fmt.Errorf("something went wrong, please try again later")
-	// ErrBusy is to indicate that the server is busy at the moment
-	ErrBusy = fmt.Errorf("server is busy right now, please try again later")
+	ErrServer = New(CodeServer, http.StatusInternalServerError, "something went wrong, please try again later")
 	// ErrUnauthorized is to indicate the user is unauthorized to perform the given operation
-	ErrUnauthorized = fmt.Errorf("you are not authorized to perform this operation")
+	ErrUnauthorized = New(CodeUnauthorized, http.StatusUnauthorized, "you are not authorized to perform this operation")
 	// ErrBadRequest is to indicate that the request is a bad request
-	ErrBadRequest = fmt.Errorf("invalid data, please check and try agan")
+	ErrBadRequest = New(CodeBadRequest, http.StatusBadRequest, "invalid data, please check and try agan")
 	// ErrNotAdmin is to indicate that the requesting user is not the admin
-	ErrNotAdmin = fmt.Errorf("you are not an admin to perform this operation")
+	ErrNotAdmin = New(CodeNotAdmin, http.StatusForbidden, "you are not an admin to perform this operation")
 	// ErrBookingIDNotValid is to indicate that the given booking id is not valid
-	ErrBookingIDNotValid = fmt.Errorf("booking id you provided is not valid")
+	ErrBookingIDNotValid = New(CodeBookingIDNotValid, http.StatusBadRequest, "booking id you provided is not valid")
+	// ErrBookingNotFound is to indicate that there is no booking with the given booking id
+	ErrBookingNotFound = New(CodeBookingNotFound, http.StatusNotFound, "there is no booking with the booking id you provided")
+	// ErrReservationNotValid is to indicate that the given reservation id is not valid
+	ErrReservationNotValid = New(CodeReservationNotValid, http.StatusBadRequest, "reservation id you provided is not valid")
+	// ErrReservationNotFound is to indicate that the given reservation does not exist or it has expired
+	ErrReservationNotFound = New(CodeReservationNotFound, http.StatusNotFound, "reservation id you provided does not exist or it has expired")
 	// ErrUnsuportedMedia is to indicate that the request body that the client is providing is not supported
-	ErrUnsuportedMedia = fmt.Errorf("request body is not supported")
+	ErrUnsuportedMedia = New(CodeUnsupportedMedia, http.StatusUnsupportedMediaType, "request body is not supported")
+	// ErrUnsupportedContentType is to indicate that the content type of the request is not application/json
+	ErrUnsupportedContentType = New(CodeUnsupportedContentType, http.StatusBadRequest, "only conent type of application/json is allowed")
+	// ErrRequestTooLarge is to indicate that the request body is larger than the allowed size
+	ErrRequestTooLarge = New(CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "request body is too large")
+	// ErrTooManyRequests is to indicate that the client has sent too many requests
+	ErrTooManyRequests = New(CodeTooManyRequests, http.StatusTooManyRequests, "too many requests, please try again later")
+	// ErrTooManyViewers is to indicate that the stream has reached the maximum number of viewers
+	ErrTooManyViewers = New(CodeTooManyViewers, http.StatusTooManyRequests, "the stream has reached the maximum number of viewers")
+	// ErrRecordedAtOutOfRange is to indicate that the device time of a location is too far from the time of the server
+	ErrRecordedAtOutOfRange = New(CodeRecordedAtOutOfRange, http.StatusBadRequest, "recorded time of the location is too far from the time of the server")
+	// ErrInvalidTransition is to indicate that the job can not move from its current status to the given status
+	ErrInvalidTransition = New(CodeInvalidTransition, http.StatusConflict, "the job can not move from its current status to the status you provided")
+	// ErrUnknownTopic is to indicate that the given topic does not exist in the location bus
+	ErrUnknownTopic = New(CodeUnknownTopic, http.StatusBadRequest, "topic you provided does not exist")
+	// ErrPrimaryTopic is to indicate that the primary topic can not be added to the pool as an extra topic
+	ErrPrimaryTopic = New(CodePrimaryTopic, http.StatusBadRequest, "the primary topic is already in the pool and can not be added as an extra topic")
+	// ErrNotFound is to indicate that the requested route does not exist
+	ErrNotFound = New(CodeNotFound, http.StatusNotFound, "the requested resource does not exist")
+	// ErrMethodNotAllowed is to indicate that the requested route does not support the request method
+	ErrMethodNotAllowed = New(CodeMethodNotAllowed, http.StatusMethodNotAllowed, "the request method is not allowed")
 )
@@ -0,0 +1,137 @@
+// Package filter is used to reject and smooth the noisy locations that are sent by the drivers
+package filter
+
+import (
+	"math"
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+)
+
+// Mode is used to select how the locations are filtered
+type Mode string
+
+const (
+	// None passes every location as it is
+	None Mode = "none"
+	// Speed rejects the locations that can only be reached by moving faster than the maximum speed
+	Speed Mode = "speed"
+	// Kalman rejects the locations like Speed and smooths the rest of them with a Kalman filter that is
+	// weighted by the accuracy of every location
+	Kalman Mode = "kalman"
+)
+
+const (
+	// defaultAccuracy is the accuracy in meters that is used when the device does not provide the accuracy
+	defaultAccuracy = 25
+	// processNoise is how fast the uncertainty of the filtered location grows in meters per second, it is
+	// high enough for the filtered location to keep up with a moving car
+	processNoise = 10
+	// maxStreak is the number of locations that can be rejected in a row before the filter starts over
+	// from the next location, so that a wrong starting location does not reject the rest of the stream
+	maxStreak = 5
+)
+
+// Config contains the settings of the filter
+type Config struct {
+	Mode Mode
+	// MaxSpeed is the maximum speed in meters per second
+	MaxSpeed float64
+	// MaxAccuracy is the largest accuracy radius in meters that is accepted, zero accepts every accuracy
+	MaxAccuracy float64
+}
+
+// Point is a location that is passed through the filter
+type Point struct {
+	Time time.Time
+	Lat  float64
+	Lon  float64
+	// Accuracy is the accuracy radius in meters, zero when it is not known
+	Accuracy float64
+}
+
+// State is the state of the filter of a single booking, it is serialized and kept in the state store
+// between the locations of the booking
+type State struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	// Variance is the uncertainty of the filtered location in square meters
+	Variance float64 `json:"variance"`
+	// Time is the time of the last accepted location in unix milliseconds
+	Time int64 `json:"time"`
+	// Rejected is the number of locations that are rejected for the booking
+	Rejected int `json:"rejected"`
+	// Streak is the number of locations that are rejected in a row
+	Streak int `json:"streak"`
+}
+
+// reset is used to start the filter over from the given point
+func (s *State) reset(point Point, accuracy float64) {
+	s.Lat = point.Lat
+	s.Lon = point.Lon
+	s.Variance = accuracy * accuracy
+	s.Time = point.Time.UnixMilli()
+	s.Streak = 0
+}
+
+// reject is used to record a rejected location
+func (s *State) reject() bool {
+	s.Rejected++
+	s.Streak++
+	return false
+}
+
+// Apply is used to pass the given point through the filter, false is returned when the point is rejected and
+// the coordinates of the point are replaced with the filtered coordinates when the point is smoothed
+func (s *State) Apply(config Config, point *Point) bool {
+	if config.Mode == None || config.Mode == "" {
+		return true
+	}
+
+	accuracy := point.Accuracy
+	if accuracy <= 0 {
+		accuracy = defaultAccuracy
+	}
+	if config.MaxAccuracy > 0 && point.Accuracy > config.MaxAccuracy {
+		return s.reject()
+	}
+
+	if s.Time == 0 || s.Streak >= maxStreak {
+		s.reset(*point, accuracy)
+		return true
+	}
+
+	dt := point.Time.Sub(time.UnixMilli(s.Time)).Seconds()
+	if dt < 0 {
+		// buffered locations that are older than the filtered location can not be compared with it
+		return true
+	}
+	dt = math.Max(dt, 1)
+
+	// the uncertainty of both locations is allowed for, so that noisy locations that are close to each other
+	// do not look like a jump
+	distance := geo.Distance(s.Lat, s.Lon, point.Lat, point.Lon) - accuracy - math.Sqrt(s.Variance)
+	if distance/dt > config.MaxSpeed {
+		return s.reject()
+	}
+
+	s.Streak = 0
+	s.Time = point.Time.UnixMilli()
+	if config.Mode != Kalman {
+		s.Lat = point.Lat
+		s.Lon = point.Lon
+		s.Variance = accuracy * accuracy
+		return true
+	}
+
+	variance := s.Variance + dt*processNoise*processNoise
+	gain := variance / (variance + accuracy*accuracy)
+
+	s.Lat += gain * (point.Lat - s.Lat)
+	s.Lon += gain * (point.Lon - s.Lon)
+	s.Variance = (1 - gain) * variance
+
+	point.Lat = s.Lat
+	point.Lon = s.Lon
+	return true
+}
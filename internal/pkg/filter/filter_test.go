@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+// start is the time of the first point of every test
+var start = time.UnixMilli(1700000000000)
+
+// point is used to get a point that is the given number of seconds after the start and the given number of
+// degrees north of the starting coordinate
+func point(seconds int, north, accuracy float64) Point {
+	return Point{
+		Time:     start.Add(time.Duration(seconds) * time.Second),
+		Lat:      51.5 + north,
+		Lon:      -0.12,
+		Accuracy: accuracy,
+	}
+}
+
+// oneMeter is roughly the number of degrees of latitude in a meter
+const oneMeter = 1.0 / 111195
+
+func TestApplyNone(t *testing.T) {
+	for _, mode := range []Mode{None, ""} {
+		var state State
+		p := point(0, 0, 0)
+		far := point(1, 1, 0)
+		if !state.Apply(Config{Mode: mode, MaxSpeed: 1}, &p) || !state.Apply(Config{Mode: mode, MaxSpeed: 1}, &far) {
+			t.Errorf("%q: want every point to pass", mode)
+		}
+		if far.Lat != 52.5 || state != (State{}) {
+			t.Errorf("%q: got %f and %+v, want the point and the state to be left alone", mode, far.Lat, state)
+		}
+	}
+}
+
+func TestApplySpeed(t *testing.T) {
+	config := Config{Mode: Speed, MaxSpeed: 50, MaxAccuracy: 200}
+	var state State
+
+	first := point(0, 0, 10)
+	if !state.Apply(config, &first) {
+		t.Fatal("want the first point to start the filter")
+	}
+
+	// a jump of about 11 kilometers in a second is faster than the maximum speed
+	jump := point(1, 0.1, 10)
+	if state.Apply(config, &jump) {
+		t.Error("want the jump to be rejected")
+	}
+	if state.Rejected != 1 || state.Streak != 1 {
+		t.Errorf("got %d rejected with a streak of %d, want 1 and 1", state.Rejected, state.Streak)
+	}
+
+	// a point that is less accurate than the maximum accuracy is rejected wherever it is
+	vague := point(2, 0, 500)
+	if state.Apply(config, &vague) {
+		t.Error("want the inaccurate point to be rejected")
+	}
+
+	next := point(3, 60*oneMeter, 10)
+	if !state.Apply(config, &next) {
+		t.Fatal("want the point within the maximum speed to be accepted")
+	}
+	if state.Streak != 0 || state.Lat != next.Lat || next.Lat != 51.5+60*oneMeter {
+		t.Errorf("got %+v, want the speed filter to move to the point as it is", state)
+	}
+
+	// a buffered point that is older than the filtered point can not be compared with it
+	old := point(-10, 1, 10)
+	if !state.Apply(config, &old) || state.Lat != next.Lat {
+		t.Errorf("got %+v, want the older point to pass without moving the filter", state)
+	}
+}
+
+func TestApplyStreak(t *testing.T) {
+	config := Config{Mode: Speed, MaxSpeed: 50}
+	var state State
+
+	first := point(0, 0, 10)
+	state.Apply(config, &first)
+
+	// the filter starts over once too many points are rejected in a row, so a wrong first point does not
+	// reject the rest of the stream
+	for i := 1; i <= maxStreak; i++ {
+		p := point(i, 0.1, 10)
+		if state.Apply(config, &p) {
+			t.Fatalf("point %d: want the jump to be rejected", i)
+		}
+	}
+
+	p := point(maxStreak+1, 0.1, 10)
+	if !state.Apply(config, &p) {
+		t.Fatal("want the filter to start over from the point after the streak")
+	}
+	if state.Lat != p.Lat || state.Streak != 0 || state.Rejected != maxStreak {
+		t.Errorf("got %+v, want the filter to start over at the point", state)
+	}
+}
+
+func TestApplyKalman(t *testing.T) {
+	config := Config{Mode: Kalman, MaxSpeed: 50}
+	var state State
+
+	first := point(0, 0, 10)
+	state.Apply(config, &first)
+
+	// a noisy point is only moved part of the way towards its coordinate
+	noisy := point(1, 30*oneMeter, 30)
+	if !state.Apply(config, &noisy) {
+		t.Fatal("want the noisy point to be accepted")
+	}
+	if noisy.Lat <= first.Lat || noisy.Lat >= 51.5+30*oneMeter {
+		t.Errorf("got %f, want the point to be smoothed between %f and %f", noisy.Lat, first.Lat, 51.5+30*oneMeter)
+	}
+	if state.Lat != noisy.Lat || state.Variance <= 0 {
+		t.Errorf("got %+v, want the filter to follow the smoothed point", state)
+	}
+
+	// a more accurate point is trusted more than a less accurate point
+	var accurate, vague State
+	accurate.Apply(config, &Point{Time: first.Time, Lat: first.Lat, Lon: first.Lon, Accuracy: 10})
+	vague.Apply(config, &Point{Time: first.Time, Lat: first.Lat, Lon: first.Lon, Accuracy: 10})
+	a := point(1, 30*oneMeter, 5)
+	v := point(1, 30*oneMeter, 50)
+	accurate.Apply(config, &a)
+	vague.Apply(config, &v)
+	if a.Lat <= v.Lat {
+		t.Errorf("got %f for the accurate point and %f for the vague point, want the accurate point to move further", a.Lat, v.Lat)
+	}
+}
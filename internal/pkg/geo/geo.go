@@ -0,0 +1,53 @@
+// Package geo contains helpers to work with geographical coordinates
+package geo
+
+import "math"
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// radians is used to convert the given degrees to radians
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Distance is a function that is used to get the great circle distance between the two given coordinates in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing is a function that is used to get the initial bearing from the first coordinate to the second coordinate
+// in degrees clockwise from the north
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	dLon := radians(lon2 - lon1)
+
+	y := math.Sin(dLon) * math.Cos(radians(lat2))
+	x := math.Cos(radians(lat1))*math.Sin(radians(lat2)) -
+		math.Sin(radians(lat1))*math.Cos(radians(lat2))*math.Cos(dLon)
+
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// SegmentDistance is a function that is used to get the shortest distance in meters from the given coordinate to
+// the segment between the two given coordinates, the segment is treated as a straight line which is accurate
+// enough for segments that are a few kilometers long
+func SegmentDistance(lat, lon, lat1, lon1, lat2, lon2 float64) float64 {
+	// the segment is projected onto a plane that is centered at the given coordinate
+	scale := math.Cos(radians(lat))
+	x1, y1 := radians(lon1-lon)*scale, radians(lat1-lat)
+	x2, y2 := radians(lon2-lon)*scale, radians(lat2-lat)
+
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/length))
+	}
+
+	return Distance(lat, lon, lat1+(lat2-lat1)*t, lon1+(lon2-lon1)*t)
+}
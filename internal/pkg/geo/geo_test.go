@@ -0,0 +1,96 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// near is used to check that the given value is within the given tolerance of the wanted value
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestDistance(t *testing.T) {
+	cases := map[string]struct {
+		lat1, lon1, lat2, lon2 float64
+		want, tolerance        float64
+	}{
+		"same point":       {51.5, -0.12, 51.5, -0.12, 0, 1e-9},
+		"one degree north": {0, 0, 1, 0, 111195, 1},
+		"one degree east":  {0, 0, 0, 1, 111195, 1},
+		"london to paris":  {51.5074, -0.1278, 48.8566, 2.3522, 343556, 500},
+		"antipodes":        {0, 0, 0, 180, math.Pi * earthRadius, 1},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Distance(tc.lat1, tc.lon1, tc.lat2, tc.lon2)
+			if !near(got, tc.want, tc.tolerance) {
+				t.Errorf("got %f meters, want %f", got, tc.want)
+			}
+			if back := Distance(tc.lat2, tc.lon2, tc.lat1, tc.lon1); !near(back, got, 1e-6) {
+				t.Errorf("got %f meters in the other direction, want %f", back, got)
+			}
+		})
+	}
+}
+
+func TestBearing(t *testing.T) {
+	cases := map[string]struct {
+		lat2, lon2 float64
+		want       float64
+	}{
+		"north": {1, 0, 0},
+		"east":  {0, 1, 90},
+		"south": {-1, 0, 180},
+		"west":  {0, -1, 270},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Bearing(0, 0, tc.lat2, tc.lon2); !near(got, tc.want, 1e-9) {
+				t.Errorf("got %f degrees, want %f", got, tc.want)
+			}
+		})
+	}
+
+	// the bearing is always within [0, 360)
+	if got := Bearing(51.5, -0.12, 51.49, -0.1201); got < 0 || got >= 360 {
+		t.Errorf("got %f degrees, want a bearing within [0, 360)", got)
+	}
+}
+
+func TestSegmentDistance(t *testing.T) {
+	// one degree is 111195 meters along the equator and along a meridian
+	cases := map[string]struct {
+		lat, lon  float64
+		want      float64
+		tolerance float64
+	}{
+		"on the segment":      {0, 0.5, 0, 1e-6},
+		"beside the segment":  {0.01, 0.5, 1112, 1},
+		"before the start":    {0, -0.01, 1112, 1},
+		"after the end":       {0, 1.01, 1112, 1},
+		"beside the start":    {0.01, 0, 1112, 1},
+		"diagonal to the end": {0.01, 1.01, 1572, 1},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := SegmentDistance(tc.lat, tc.lon, 0, 0, 0, 1)
+			if !near(got, tc.want, tc.tolerance) {
+				t.Errorf("got %f meters, want %f", got, tc.want)
+			}
+			if back := SegmentDistance(tc.lat, tc.lon, 0, 1, 0, 0); !near(back, got, 1e-6) {
+				t.Errorf("got %f meters for the reversed segment, want %f", back, got)
+			}
+		})
+	}
+
+	// a segment without a length is the distance to its only point
+	if got, want := SegmentDistance(51.5, -0.12, 51.51, -0.12, 51.51, -0.12), Distance(51.5, -0.12, 51.51, -0.12); !near(got, want, 1e-6) {
+		t.Errorf("got %f meters, want %f", got, want)
+	}
+
+	// the segment is projected at the latitude of the coordinate so that a longitude is shorter away from the equator
+	if got := SegmentDistance(60.01, 10.5, 60, 10, 60, 11); !near(got, 1112, 2) {
+		t.Errorf("got %f meters, want the distance to the segment that runs along the latitude", got)
+	}
+}
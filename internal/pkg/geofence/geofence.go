@@ -0,0 +1,131 @@
+// Package geofence is used to detect when a driver arrives at and departs from the stops of a booking
+package geofence
+
+import (
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+)
+
+// exitFactor is how much further than the radius the driver has to move to depart from a fence, so that a
+// driver waiting on the edge of a fence does not arrive and depart over and over again
+const exitFactor = 1.25
+
+// Kind is used to classify the fences of a booking
+type Kind string
+
+const (
+	// Pickup is the fence around a stop where the passenger is picked up
+	Pickup Kind = "pickup"
+	// Dropoff is the fence around a stop where the passenger is dropped off
+	Dropoff Kind = "dropoff"
+)
+
+// EventType is used to classify the geofence events
+type EventType string
+
+const (
+	// Arrival is the event that occurs when the driver stays inside a fence for the dwell time
+	Arrival EventType = "arrival"
+	// Departure is the event that occurs when the driver leaves the fence that the driver has arrived at
+	Departure EventType = "departure"
+)
+
+// Config contains the settings of the geofences
+type Config struct {
+	// Radius is the radius of every fence in meters
+	Radius float64
+	// Dwell is the time that the driver has to stay inside a fence to arrive at it
+	Dwell time.Duration
+}
+
+// Fence is a circular fence around a stop of a booking
+type Fence struct {
+	Kind Kind `json:"kind"`
+	// Index is the index of the stop within the pickups or the dropoffs
+	Index int     `json:"index"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+}
+
+// Event is an arrival at or a departure from a fence
+type Event struct {
+	Type  EventType `json:"type"`
+	Kind  Kind      `json:"kind"`
+	Index int       `json:"index"`
+	// At is the time of the event in unix milliseconds
+	At int64 `json:"at"`
+}
+
+// State is the state of the geofences of a single booking, it is serialized and kept in the state store
+// between the locations of the booking
+type State struct {
+	Fences []Fence `json:"fences"`
+	// Inside is the index of the fence that the driver has arrived at, -1 when the driver is outside of every fence
+	Inside int `json:"inside"`
+	// Entering is the index of the fence that the driver is inside of without arriving at it yet, -1 when there is none
+	Entering int `json:"entering"`
+	// Since is the time that the driver entered the fence in Entering in unix milliseconds
+	Since int64 `json:"since"`
+}
+
+// NewState is a function that is used to create the state of the geofences with the given fences
+func NewState(fences []Fence) State {
+	return State{
+		Fences:   fences,
+		Inside:   -1,
+		Entering: -1,
+	}
+}
+
+// distance is used to get the distance in meters from the given coordinates to the fence
+func (f Fence) distance(lat, lon float64) float64 {
+	return geo.Distance(f.Lat, f.Lon, lat, lon)
+}
+
+// Apply is used to move the driver to the given location, the arrival or the departure that occurs at the given
+// location is returned and nil is returned when nothing occurs
+func (s *State) Apply(config Config, lat, lon float64, at time.Time) *Event {
+	if s.Inside >= 0 && s.Inside < len(s.Fences) {
+		fence := s.Fences[s.Inside]
+		if fence.distance(lat, lon) <= config.Radius*exitFactor {
+			return nil
+		}
+
+		s.Inside = -1
+		s.Entering = -1
+		return &Event{
+			Type:  Departure,
+			Kind:  fence.Kind,
+			Index: fence.Index,
+			At:    at.UnixMilli(),
+		}
+	}
+
+	nearest, shortest := -1, config.Radius
+	for i, fence := range s.Fences {
+		if distance := fence.distance(lat, lon); distance <= shortest {
+			nearest, shortest = i, distance
+		}
+	}
+	if nearest < 0 {
+		s.Entering = -1
+		return nil
+	}
+	if nearest != s.Entering {
+		s.Entering = nearest
+		s.Since = at.UnixMilli()
+	}
+	if at.Sub(time.UnixMilli(s.Since)) < config.Dwell {
+		return nil
+	}
+
+	s.Inside = nearest
+	s.Entering = -1
+	return &Event{
+		Type:  Arrival,
+		Kind:  s.Fences[nearest].Kind,
+		Index: s.Fences[nearest].Index,
+		At:    at.UnixMilli(),
+	}
+}
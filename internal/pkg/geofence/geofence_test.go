@@ -0,0 +1,102 @@
+package geofence
+
+import (
+	"testing"
+	"time"
+)
+
+// oneMeter is roughly the number of degrees of latitude in a meter
+const oneMeter = 1.0 / 111195
+
+var (
+	start  = time.UnixMilli(1700000000000)
+	config = Config{Radius: 100, Dwell: 30 * time.Second}
+)
+
+// testState is used to get the fences of a booking with a single pickup and a single dropoff about 2 kilometers
+// north of it
+func testState() State {
+	return NewState([]Fence{
+		{Kind: Pickup, Index: 0, Lat: 51.5, Lon: -0.12},
+		{Kind: Dropoff, Index: 0, Lat: 51.5 + 2000*oneMeter, Lon: -0.12},
+	})
+}
+
+// at is used to get the time that is the given number of seconds after the start
+func at(seconds int) time.Time {
+	return start.Add(time.Duration(seconds) * time.Second)
+}
+
+func TestApplyArrival(t *testing.T) {
+	state := testState()
+
+	// entering the fence is not an arrival until the driver stays inside of it for the dwell time
+	if event := state.Apply(config, 51.5+50*oneMeter, -0.12, at(0)); event != nil {
+		t.Fatalf("got %+v, want no event while entering the fence", event)
+	}
+	if event := state.Apply(config, 51.5+20*oneMeter, -0.12, at(29)); event != nil {
+		t.Fatalf("got %+v, want no event before the dwell time", event)
+	}
+
+	event := state.Apply(config, 51.5, -0.12, at(30))
+	if event == nil || event.Type != Arrival || event.Kind != Pickup || event.Index != 0 || event.At != at(30).UnixMilli() {
+		t.Fatalf("got %+v, want an arrival at the pickup", event)
+	}
+
+	// the driver stays arrived while waiting at the stop
+	if event := state.Apply(config, 51.5, -0.12, at(60)); event != nil {
+		t.Errorf("got %+v, want a single arrival", event)
+	}
+}
+
+func TestApplyLeavingBeforeDwell(t *testing.T) {
+	state := testState()
+
+	state.Apply(config, 51.5, -0.12, at(0))
+	// driving through the fence does not arrive at it
+	state.Apply(config, 51.5+500*oneMeter, -0.12, at(10))
+	if event := state.Apply(config, 51.5, -0.12, at(35)); event != nil {
+		t.Errorf("got %+v, want the dwell time to start over after leaving the fence", event)
+	}
+}
+
+func TestApplyDeparture(t *testing.T) {
+	state := testState()
+	state.Apply(config, 51.5, -0.12, at(0))
+	state.Apply(config, 51.5, -0.12, at(30))
+
+	// waiting just outside of the radius is not a departure, so the driver does not arrive and depart over and over
+	if event := state.Apply(config, 51.5+110*oneMeter, -0.12, at(40)); event != nil {
+		t.Fatalf("got %+v, want no departure within the exit radius", event)
+	}
+
+	event := state.Apply(config, 51.5+200*oneMeter, -0.12, at(50))
+	if event == nil || event.Type != Departure || event.Kind != Pickup {
+		t.Fatalf("got %+v, want a departure from the pickup", event)
+	}
+	if state.Inside != -1 {
+		t.Errorf("got %d, want the driver to be outside of every fence", state.Inside)
+	}
+
+	// the next fence is arrived at like the first one
+	dropoff := 51.5 + 2000*oneMeter
+	state.Apply(config, dropoff, -0.12, at(300))
+	event = state.Apply(config, dropoff, -0.12, at(330))
+	if event == nil || event.Type != Arrival || event.Kind != Dropoff {
+		t.Errorf("got %+v, want an arrival at the dropoff", event)
+	}
+}
+
+func TestApplyNearest(t *testing.T) {
+	// the fences overlap so the driver is inside of both of them
+	state := NewState([]Fence{
+		{Kind: Pickup, Index: 0, Lat: 51.5, Lon: -0.12},
+		{Kind: Pickup, Index: 1, Lat: 51.5 + 120*oneMeter, Lon: -0.12},
+	})
+
+	state.Apply(config, 51.5+80*oneMeter, -0.12, at(0))
+	event := state.Apply(config, 51.5+80*oneMeter, -0.12, at(30))
+	if event == nil || event.Index != 1 {
+		t.Errorf("got %+v, want an arrival at the nearest fence", event)
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	ers "errors"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/VinukaThejana/go-utils/logger"
 	"github.com/bytedance/sonic"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/errors"
+	"github.com/go-playground/validator/v10"
 )
 
 // LogFatal is a function that is used to Run various functions that need to crash if an error is found
@@ -55,6 +59,76 @@ func JSONResponseWInterface(w http.ResponseWriter, statusCode int, res any) {
 	sonic.ConfigDefault.NewEncoder(w).Encode(res)
 }
 
+// Problem is the RFC 7807 problem details body that is sent to the client with every error response
+type Problem struct {
+	Details  map[string]any `json:"details,omitempty"`
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Detail   string         `json:"detail"`
+	Instance string         `json:"instance,omitempty"`
+	Code     errors.Code    `json:"code"`
+	Status   int            `json:"status"`
+}
+
+// ErrorResponse is a function that is used to send the given error to the client as an application/problem+json response
+func ErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	e := errors.From(err)
+
+	problem := Problem{
+		Type:    "about:blank",
+		Title:   http.StatusText(e.Status),
+		Status:  e.Status,
+		Detail:  e.Message,
+		Code:    e.Code,
+		Details: e.Details,
+	}
+	if r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	sonic.ConfigDefault.NewEncoder(w).Encode(problem)
+}
+
+// NewValidator is a function that is used to create a validator that reports the fields by their json names
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+
+		return name
+	})
+
+	return v
+}
+
+// ValidationError is a function that is used to convert the error of the validator to a bad request error
+// that contains the fields that failed the validation
+func ValidationError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if !ers.As(err, &validationErrs) {
+		return errors.ErrBadRequest
+	}
+
+	fields := make(map[string]string, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		fields[validationErr.Field()] = validationErr.Tag()
+	}
+
+	return errors.ErrBadRequest.WithDetails(map[string]any{
+		"fields": fields,
+	})
+}
+
 // Base64URLDecode decodes base64url string to byte array
 // The following functions are adapted from the code provided by DV[dvsekhvalnov]
 // Source: https://github.com/dvsekhvalnov/jose2go/blob/master/base64url/base64url.go
@@ -0,0 +1,191 @@
+package partitions_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testTopic      = "locations"
+	testPartitions = 8
+	// testClaimers is the number of goroutines that claim the slots at the same time, it is larger than the
+	// number of slots so that the slots run out while they are being claimed
+	testClaimers = 64
+)
+
+// testAllocators is used to get an allocator on top of every claimer, the memory state store claims the slots with
+// its compare and set operation and Redis claims them with the Lua script
+func testAllocators(t *testing.T) map[string]*partitions.Allocator {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	memory := connections.NewMemoryState()
+	store := connections.NewRedis(client, "partitions", testTopic)
+
+	return map[string]*partitions.Allocator{
+		"memory": partitions.New(partitions.NewStoreClaimer(memory), memory, testTopic, testPartitions),
+		"redis":  partitions.New(partitions.NewRedisClaimer(client, "partitions", testTopic), store, testTopic, testPartitions),
+	}
+}
+
+// allocateAll is used to allocate from the given allocator with testClaimers goroutines at the same time, the
+// slots that are allocated are returned along with the number of allocations that found every slot in use
+func allocateAll(t *testing.T, allocator *partitions.Allocator) ([]partitions.Slot, int) {
+	t.Helper()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		slots     []partitions.Slot
+		exhausted int
+		failures  []error
+	)
+
+	start := make(chan struct{})
+	for i := 0; i < testClaimers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			slot, err := allocator.Allocate(context.Background())
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, partitions.ErrNoPartition):
+				exhausted++
+			case err != nil:
+				failures = append(failures, err)
+			default:
+				slots = append(slots, slot)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range failures {
+		t.Errorf("unexpected error while allocating: %v", err)
+	}
+
+	return slots, exhausted
+}
+
+func TestAllocatorConcurrentClaims(t *testing.T) {
+	for name, allocator := range testAllocators(t) {
+		t.Run(name, func(t *testing.T) {
+			slots, exhausted := allocateAll(t, allocator)
+
+			if len(slots) != testPartitions {
+				t.Errorf("got %d slots, want every one of the %d slots to be allocated", len(slots), testPartitions)
+			}
+			if exhausted != testClaimers-testPartitions {
+				t.Errorf("got %d exhausted allocations, want %d", exhausted, testClaimers-testPartitions)
+			}
+
+			seen := make(map[partitions.Slot]bool, len(slots))
+			for _, slot := range slots {
+				if seen[slot] {
+					t.Errorf("the slot %s is allocated more than once", slot)
+				}
+				seen[slot] = true
+			}
+		})
+	}
+}
+
+func TestAllocatorRelease(t *testing.T) {
+	ctx := context.Background()
+
+	for name, allocator := range testAllocators(t) {
+		t.Run(name, func(t *testing.T) {
+			slots, _ := allocateAll(t, allocator)
+			if len(slots) != testPartitions {
+				t.Fatalf("got %d slots, want %d", len(slots), testPartitions)
+			}
+
+			// a slot that is not freed is never handed out again
+			if slot, err := allocator.Allocate(ctx); !errors.Is(err, partitions.ErrNoPartition) {
+				t.Fatalf("got %s, %v, want every slot to be in use", slot, err)
+			}
+
+			released := slots[3]
+			if err := allocator.Release(ctx, released); err != nil {
+				t.Fatal(err)
+			}
+
+			// the released slot is the only slot that comes back, and only once
+			again, _ := allocateAll(t, allocator)
+			if len(again) != 1 || again[0] != released {
+				t.Errorf("got %v, want only the released slot %s", again, released)
+			}
+		})
+	}
+}
+
+func TestAllocatorRoundRobin(t *testing.T) {
+	ctx := context.Background()
+
+	for name, allocator := range testAllocators(t) {
+		t.Run(name, func(t *testing.T) {
+			first, err := allocator.Allocate(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := allocator.Release(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+
+			// the search starts after the last allocated slot instead of reusing the lowest slot
+			second, err := allocator.Allocate(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second == first {
+				t.Errorf("got %s twice, want the next slot", first)
+			}
+		})
+	}
+}
+
+func TestAllocatorLegacyMembers(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	defer client.Close()
+
+	// the older releases add the partitions of the primary topic without the topic
+	if err := client.SAdd(ctx, "partitions", "0", "2").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := connections.NewRedis(client, "partitions", testTopic)
+	allocator := partitions.New(partitions.NewRedisClaimer(client, "partitions", testTopic), store, testTopic, testPartitions)
+
+	slots, _ := allocateAll(t, allocator)
+	if len(slots) != testPartitions-2 {
+		t.Fatalf("got %d slots, want every slot except the 2 legacy members", len(slots))
+	}
+	for _, slot := range slots {
+		if slot.Partition == 0 || slot.Partition == 2 {
+			t.Errorf("the slot %s of a legacy member is allocated", slot)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package partitions contains the allocator that is used to hand out the slots of the location bus to the bookings
+package partitions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoPartition is an error that occurs when every slot is currently in use
+var ErrNoPartition = fmt.Errorf("no partitions are currently available")
+
+// Store is the interface of the state store that keeps track of the slots that are in use
+type Store interface {
+	// ClaimPartition is used to mark the given slot as in use, false is returned when the
+	// slot is already in use
+	ClaimPartition(ctx context.Context, slot Slot) (bool, error)
+	// Free is used to deallocate the used slot for upcomming jobs
+	Free(ctx context.Context, slot Slot) error
+	// Topics is used to get the topics that are added to the pool along with their number of partitions
+	Topics(ctx context.Context) (map[string]int, error)
+}
+
+// Claimer is the interface that is used to atomically claim a free slot
+type Claimer interface {
+	// Claim is used to claim the first free slot of the given slots while starting the search from
+	// the given index, ErrNoPartition is returned when every slot is in use
+	Claim(ctx context.Context, slots []Slot, start int) (Slot, error)
+}
+
+// Allocator is used to allocate and release the slots of the location bus
+//
+// The pool contains every partition of the primary topic and of the topics that are added to the
+// store at runtime, the search for a free slot starts right after the last allocated slot so that
+// the slots are handed out in a round robin order instead of always reusing the lowest slot
+type Allocator struct {
+	claimer Claimer
+	store   Store
+	stats   *stats
+	// onRelease is called every time a slot is released so that the waiting queue can admit
+	// the next reservation
+	onRelease  func(ctx context.Context)
+	topic      string
+	partitions int
+	cursor     atomic.Int64
+}
+
+// New is a function that is used to create a new allocator where the pool always contains the given
+// number of partitions of the primary topic
+func New(claimer Claimer, store Store, topic string, partitions int) *Allocator {
+	return &Allocator{
+		claimer:    claimer,
+		store:      store,
+		stats:      newStats(),
+		topic:      topic,
+		partitions: partitions,
+	}
+}
+
+// Slots is used to get every slot in the pool, the slots of the primary topic come first and the
+// rest of the topics follow in the alphabetical order
+func (a *Allocator) Slots(ctx context.Context) ([]Slot, error) {
+	topics, err := a.store.Topics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		if topic != a.topic {
+			names = append(names, topic)
+		}
+	}
+	sort.Strings(names)
+
+	slots := []Slot{}
+	add := func(topic string, partitions int) {
+		for partition := 0; partition < partitions; partition++ {
+			slots = append(slots, Slot{
+				Topic:     topic,
+				Partition: partition,
+			})
+		}
+	}
+
+	add(a.topic, a.partitions)
+	for _, topic := range names {
+		add(topic, topics[topic])
+	}
+
+	return slots, nil
+}
+
+// Allocate is used to atomically claim a free slot
+func (a *Allocator) Allocate(ctx context.Context) (Slot, error) {
+	start := time.Now()
+
+	slots, err := a.Slots(ctx)
+	if err != nil {
+		a.stats.allocated(Slot{}, time.Since(start), err)
+		return Slot{}, err
+	}
+	if len(slots) == 0 {
+		a.stats.allocated(Slot{}, time.Since(start), ErrNoPartition)
+		return Slot{}, ErrNoPartition
+	}
+
+	cursor := int(a.cursor.Load() % int64(len(slots)))
+	slot, err := a.claimer.Claim(ctx, slots, cursor)
+	a.stats.allocated(slot, time.Since(start), err)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	for i, s := range slots {
+		if s == slot {
+			a.cursor.Store(int64(i + 1))
+			break
+		}
+	}
+
+	return slot, nil
+}
+
+// Release is used to deallocate the given slot for upcomming jobs
+func (a *Allocator) Release(ctx context.Context, slot Slot) error {
+	err := a.release(ctx, slot)
+	if err != nil {
+		return err
+	}
+
+	if a.onRelease != nil {
+		a.onRelease(ctx)
+	}
+
+	return nil
+}
+
+// release is used to deallocate the given slot without letting the waiting queue know, it is used by the waiting
+// queue itself
+func (a *Allocator) release(ctx context.Context, slot Slot) error {
+	err := a.store.Free(ctx, slot)
+	if err != nil {
+		return err
+	}
+
+	a.stats.released()
+	return nil
+}
+
+// Admit is used to let the waiting queue know that new slots may be available, for an example after
+// a topic is added to the pool
+func (a *Allocator) Admit(ctx context.Context) {
+	if a.onRelease != nil {
+		a.onRelease(ctx)
+	}
+}
+
+// Stats is used to get a snapshot of the allocation statistics of every slot in the pool
+func (a *Allocator) Stats(ctx context.Context) (Stats, error) {
+	slots, err := a.Slots(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return a.stats.snapshot(slots), nil
+}
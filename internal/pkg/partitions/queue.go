@@ -0,0 +1,310 @@
+package partitions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// pollInterval is the frequency to check the state store for a partition while long polling a reservation, the
+// partition can be assigned by any instance of the service
+const pollInterval = 250 * time.Millisecond
+
+var (
+	// ErrReservationNotFound is an error that occurs when the reservation does not exist or it has expired
+	ErrReservationNotFound = fmt.Errorf("there is no reservation with the given reservation id")
+	// ErrReservationPending is an error that occurs when a reservation is claimed before a partition is assigned to it
+	ErrReservationPending = fmt.Errorf("the reservation is still waiting for a partition")
+)
+
+// ReservationStatus is used to represent the state of a reservation
+type ReservationStatus string
+
+const (
+	// ReservationWaiting is the status of a reservation that is waiting for a free partition
+	ReservationWaiting ReservationStatus = "waiting"
+	// ReservationReady is the status of a reservation that holds a partition until it is claimed
+	ReservationReady ReservationStatus = "ready"
+)
+
+// Reservation contains the state of a single reservation in the waiting queue
+type Reservation struct {
+	ExpiresAt time.Time         `json:"expires_at"`
+	ID        string            `json:"reservation_id"`
+	BookingID string            `json:"booking_id"`
+	Status    ReservationStatus `json:"status"`
+	// Position is the 1 based position of the reservation in the queue, it is 0 once a partition is assigned
+	Position int `json:"position"`
+	// Slot is the slot that is held for the reservation once it is ready
+	Slot Slot `json:"-"`
+}
+
+// QueueStore is the interface of the state store that keeps the waiting queue, the queue is kept in the state store
+// so that every instance of the service shares the same queue
+type QueueStore interface {
+	// Enqueue is used to add the given waiting reservation to the end of the queue
+	Enqueue(ctx context.Context, reservation Reservation) error
+	// GetReservation is used to get the given reservation along with its position in the queue, false is returned
+	// when the reservation does not exist
+	GetReservation(ctx context.Context, id string) (Reservation, bool, error)
+	// AssignReservation is used to remove the reservation at the head of the queue while holding the given slot
+	// for it until the given time, false is returned when nobody is waiting in the queue
+	AssignReservation(ctx context.Context, slot Slot, expiresAt time.Time) (Reservation, bool, error)
+	// RefreshReservation is used to move the expiry of the given reservation to the given time
+	RefreshReservation(ctx context.Context, id string, expiresAt time.Time) error
+	// TakeReservation is used to remove the given reservation only when a slot is held for it, false is returned
+	// when the reservation does not exist or it is still waiting
+	TakeReservation(ctx context.Context, id string) (Reservation, bool, error)
+	// ExpireReservations is used to remove every reservation that expires before the given time, the removed
+	// reservations are returned so that the slots that are held for them can be released
+	ExpireReservations(ctx context.Context, now time.Time) ([]Reservation, error)
+	// Waiting is used to get the number of reservations that are waiting in the queue
+	Waiting(ctx context.Context) (int, error)
+}
+
+// Queue is the admission queue that is used to hand out partitions in the order of the requests
+// when every partition is in use
+//
+// A partition is assigned to the head of the queue whenever the allocator releases a partition or
+// a request reaches the queue, the assigned partition is held for the reservation until it is
+// claimed or the reservation times out
+type Queue struct {
+	allocator *Allocator
+	store     QueueStore
+	timeout   time.Duration
+}
+
+// NewQueue is a function that is used to create a waiting queue on top of the given allocator and state store
+// where every reservation expires after the given timeout
+func NewQueue(allocator *Allocator, store QueueStore, timeout time.Duration) *Queue {
+	q := &Queue{
+		allocator: allocator,
+		store:     store,
+		timeout:   timeout,
+	}
+	allocator.onRelease = q.admit
+
+	return q
+}
+
+// Acquire is used to allocate a partition right away when nobody is waiting in the queue, otherwise a
+// reservation is added to the end of the queue and returned instead of a partition
+func (q *Queue) Acquire(ctx context.Context, bookingID string) (Slot, *Reservation, error) {
+	q.admit(ctx)
+
+	waiting, err := q.store.Waiting(ctx)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+	if waiting == 0 {
+		slot, err := q.allocator.Allocate(ctx)
+		if err == nil {
+			return slot, nil, nil
+		}
+		if !errors.Is(err, ErrNoPartition) {
+			return Slot{}, nil, err
+		}
+	}
+
+	id := uuid.New().String()
+	err = q.store.Enqueue(ctx, Reservation{
+		ID:        id,
+		BookingID: bookingID,
+		Status:    ReservationWaiting,
+		ExpiresAt: time.Now().UTC().Add(q.timeout),
+	})
+	if err != nil {
+		return Slot{}, nil, err
+	}
+
+	// a partition may have been released while the reservation was added
+	q.admit(ctx)
+
+	r, ok, err := q.store.GetReservation(ctx, id)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+	if !ok {
+		return Slot{}, nil, ErrReservationNotFound
+	}
+
+	return Slot{}, &r, nil
+}
+
+// Wait is used to get the state of the given reservation, when the reservation is still waiting it
+// blocks until a partition is assigned, the given time has passed or the context is done
+//
+// A waiting reservation does not expire while it is polled, the timeout starts again once the
+// request returns
+func (q *Queue) Wait(ctx context.Context, id string, wait time.Duration) (Reservation, error) {
+	q.admit(ctx)
+
+	r, ok, err := q.store.GetReservation(ctx, id)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if !ok {
+		return Reservation{}, ErrReservationNotFound
+	}
+	if r.Status != ReservationWaiting {
+		return r, nil
+	}
+	if wait <= 0 {
+		return q.refresh(r)
+	}
+
+	err = q.store.RefreshReservation(ctx, id, time.Now().UTC().Add(wait+q.timeout))
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for r.Status == ReservationWaiting {
+		select {
+		case <-ctx.Done():
+			return q.refresh(r)
+		case <-timer.C:
+			return q.refresh(r)
+		case <-ticker.C:
+		}
+
+		q.admit(ctx)
+		r, ok, err = q.store.GetReservation(ctx, id)
+		if err != nil {
+			return Reservation{}, err
+		}
+		if !ok {
+			return Reservation{}, ErrReservationNotFound
+		}
+	}
+
+	return r, nil
+}
+
+// refresh is used to restart the timeout of the given waiting reservation once the request that polls it returns
+func (q *Queue) refresh(r Reservation) (Reservation, error) {
+	r.ExpiresAt = time.Now().UTC().Add(q.timeout)
+
+	// the context of the request may already be done
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := q.store.RefreshReservation(ctx, r.ID, r.ExpiresAt); err != nil {
+		return Reservation{}, err
+	}
+
+	return r, nil
+}
+
+// Claim is used to take the slot that is held for the given reservation
+func (q *Queue) Claim(ctx context.Context, id, bookingID string) (Slot, *Reservation, error) {
+	q.admit(ctx)
+
+	r, ok, err := q.store.GetReservation(ctx, id)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+	if !ok || r.BookingID != bookingID {
+		return Slot{}, nil, ErrReservationNotFound
+	}
+	if r.Status != ReservationReady {
+		return Slot{}, &r, ErrReservationPending
+	}
+
+	r, ok, err = q.store.TakeReservation(ctx, id)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+	if !ok {
+		return Slot{}, nil, ErrReservationNotFound
+	}
+
+	return r.Slot, nil, nil
+}
+
+// admit is used to assign the free partitions to the reservations at the head of the queue
+func (q *Queue) admit(ctx context.Context) {
+	q.expire(ctx)
+
+	for {
+		waiting, err := q.store.Waiting(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get the number of reservations in the waiting queue")
+			return
+		}
+		if waiting == 0 {
+			return
+		}
+
+		slot, err := q.allocator.Allocate(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrNoPartition) {
+				log.Error().Err(err).Msg("failed to allocate a partition for the waiting queue")
+			}
+			return
+		}
+
+		_, ok, err := q.store.AssignReservation(ctx, slot, time.Now().UTC().Add(q.timeout))
+		if err != nil || !ok {
+			if err != nil {
+				log.Error().Err(err).
+					Msgf(
+						"slot : %s\tfailed to assign the slot to the waiting queue",
+						slot,
+					)
+			}
+
+			// the queue is emptied by another instance in the meantime
+			if err := q.allocator.release(ctx, slot); err != nil {
+				log.Error().Err(err).
+					Msgf(
+						"slot : %s\tfailed to release the slot that is not assigned to a reservation",
+						slot,
+					)
+			}
+			return
+		}
+	}
+}
+
+// expire is used to remove the reservations that have timed out while releasing the partitions that are held
+// for them
+func (q *Queue) expire(ctx context.Context) {
+	expired, err := q.store.ExpireReservations(ctx, time.Now().UTC())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to remove the expired reservations")
+		return
+	}
+
+	for _, r := range expired {
+		log.Warn().
+			Msgf(
+				"reservation_id : %s\tbooking_id : %s\tstatus : %s\tthe reservation has expired",
+				r.ID,
+				r.BookingID,
+				r.Status,
+			)
+
+		if r.Status != ReservationReady {
+			continue
+		}
+
+		// the partition is assigned to the next reservation in the queue by the caller
+		if err := q.allocator.release(ctx, r.Slot); err != nil {
+			log.Error().Err(err).
+				Msgf(
+					"reservation_id : %s\tslot : %s\tfailed to release the slot of the expired reservation",
+					r.ID,
+					r.Slot,
+				)
+		}
+	}
+}
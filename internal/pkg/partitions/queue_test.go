@@ -0,0 +1,244 @@
+package partitions_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/connections"
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/partitions"
+	"github.com/redis/go-redis/v9"
+)
+
+// instance is a queue along with the allocator that it hands out the slots of
+type instance struct {
+	*partitions.Queue
+	allocator *partitions.Allocator
+}
+
+// newInstance is used to create a queue with a single partition on top of the given claimer and state store
+func newInstance(claimer partitions.Claimer, store connections.StateStore, timeout time.Duration) instance {
+	allocator := partitions.New(claimer, store, testTopic, 1)
+	return instance{
+		Queue:     partitions.NewQueue(allocator, store, timeout),
+		allocator: allocator,
+	}
+}
+
+// release is used to release the given slot through the allocator of the instance
+func (i instance) release(t *testing.T, slot partitions.Slot) {
+	t.Helper()
+
+	if err := i.allocator.Release(context.Background(), slot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testQueues is used to get the given number of instances of the queue on top of every state store, the instances
+// of a state store share it like the instances of the service do
+func testQueues(t *testing.T, instances int, timeout time.Duration) map[string][]instance {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	memory := connections.NewMemoryState()
+	store := connections.NewRedis(client, "partitions", testTopic)
+
+	queues := map[string][]instance{}
+	for i := 0; i < instances; i++ {
+		queues["memory"] = append(queues["memory"], newInstance(partitions.NewStoreClaimer(memory), memory, timeout))
+		queues["redis"] = append(queues["redis"], newInstance(partitions.NewRedisClaimer(client, "partitions", testTopic), store, timeout))
+	}
+
+	return queues
+}
+
+// acquire is used to acquire a slot for the given booking, the reservation is returned when it has to wait
+func acquire(t *testing.T, queue instance, bookingID string) (partitions.Slot, *partitions.Reservation) {
+	t.Helper()
+
+	slot, reservation, err := queue.Acquire(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return slot, reservation
+}
+
+func TestQueueOrder(t *testing.T) {
+	ctx := context.Background()
+
+	for name, queues := range testQueues(t, 1, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			queue := queues[0]
+
+			slot, reservation := acquire(t, queue, "B0")
+			if reservation != nil {
+				t.Fatalf("got %v, want the free slot right away", reservation)
+			}
+
+			bookings := []string{"B1", "B2", "B3"}
+			ids := []string{}
+			for i, booking := range bookings {
+				_, reservation := acquire(t, queue, booking)
+				if reservation == nil || reservation.Position != i+1 || reservation.Status != partitions.ReservationWaiting {
+					t.Fatalf("got %v, want a waiting reservation at the position %d", reservation, i+1)
+				}
+				ids = append(ids, reservation.ID)
+			}
+
+			// every released slot goes to the head of the queue
+			for i, id := range ids {
+				if _, _, err := queue.Claim(ctx, id, bookings[i]); !errors.Is(err, partitions.ErrReservationPending) {
+					t.Fatalf("got %v, want the reservation to be pending", err)
+				}
+
+				queue.release(t, slot)
+
+				got, _, err := queue.Claim(ctx, id, bookings[i])
+				if err != nil || got != slot {
+					t.Fatalf("got %s, %v, want the released slot %s", got, err, slot)
+				}
+
+				for j, next := range ids[i+1:] {
+					r, err := queue.Wait(ctx, next, 0)
+					if err != nil || r.Position != j+1 {
+						t.Errorf("got %v, %v, want the reservation to move to the position %d", r, err, j+1)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestQueueClaim(t *testing.T) {
+	ctx := context.Background()
+
+	for name, queues := range testQueues(t, 1, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			queue := queues[0]
+
+			slot, _ := acquire(t, queue, "B0")
+			_, reservation := acquire(t, queue, "B1")
+			queue.release(t, slot)
+
+			// only the booking that made the reservation claims it, and only once
+			if _, _, err := queue.Claim(ctx, reservation.ID, "B2"); !errors.Is(err, partitions.ErrReservationNotFound) {
+				t.Errorf("got %v, want another booking not to claim the reservation", err)
+			}
+			if _, _, err := queue.Claim(ctx, reservation.ID, "B1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := queue.Claim(ctx, reservation.ID, "B1"); !errors.Is(err, partitions.ErrReservationNotFound) {
+				t.Errorf("got %v, want the reservation to be claimed once", err)
+			}
+		})
+	}
+}
+
+func TestQueueExpiry(t *testing.T) {
+	ctx := context.Background()
+	timeout := 100 * time.Millisecond
+
+	for name, queues := range testQueues(t, 1, timeout) {
+		t.Run(name, func(t *testing.T) {
+			queue := queues[0]
+
+			slot, _ := acquire(t, queue, "B0")
+			_, waiting := acquire(t, queue, "B1")
+
+			// a waiting reservation that is not polled expires
+			time.Sleep(2 * timeout)
+			if _, err := queue.Wait(ctx, waiting.ID, 0); !errors.Is(err, partitions.ErrReservationNotFound) {
+				t.Fatalf("got %v, want the waiting reservation to expire", err)
+			}
+
+			_, ready := acquire(t, queue, "B2")
+			_, next := acquire(t, queue, "B3")
+			queue.release(t, slot)
+
+			r, err := queue.Wait(ctx, ready.ID, 0)
+			if err != nil || r.Status != partitions.ReservationReady {
+				t.Fatalf("got %v, %v, want the reservation to be ready", r, err)
+			}
+
+			// the slot that is held for a ready reservation that is not claimed goes to the next reservation, the
+			// next reservation is polled in the meantime so that it does not expire as well
+			r, err = queue.Wait(ctx, next.ID, 3*timeout)
+			if err != nil || r.Status != partitions.ReservationReady {
+				t.Fatalf("got %v, %v, want the next reservation to get the slot", r, err)
+			}
+			if _, _, err := queue.Claim(ctx, ready.ID, "B2"); !errors.Is(err, partitions.ErrReservationNotFound) {
+				t.Errorf("got %v, want the ready reservation to expire", err)
+			}
+			if got, _, err := queue.Claim(ctx, next.ID, "B3"); err != nil || got != slot {
+				t.Errorf("got %s, %v, want the slot %s", got, err, slot)
+			}
+		})
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	ctx := context.Background()
+
+	for name, queues := range testQueues(t, 1, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			queue := queues[0]
+
+			slot, _ := acquire(t, queue, "B0")
+			_, reservation := acquire(t, queue, "B1")
+
+			// the wait returns once the slot is released instead of running out
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				_ = queue.allocator.Release(ctx, slot)
+			}()
+
+			start := time.Now()
+			r, err := queue.Wait(ctx, reservation.ID, 5*time.Second)
+			if err != nil || r.Status != partitions.ReservationReady {
+				t.Fatalf("got %v, %v, want the reservation to be ready", r, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("the wait took %s, want it to return once the slot is released", elapsed)
+			}
+		})
+	}
+}
+
+func TestQueueInstances(t *testing.T) {
+	ctx := context.Background()
+
+	for name, queues := range testQueues(t, 2, time.Minute) {
+		t.Run(name, func(t *testing.T) {
+			first, second := queues[0], queues[1]
+
+			slot, _ := acquire(t, first, "B0")
+
+			// the queue is shared so the second instance does not skip the queue of the first one
+			_, waiting := acquire(t, first, "B1")
+			_, behind := acquire(t, second, "B2")
+			if behind == nil || behind.Position != 2 {
+				t.Fatalf("got %v, want the reservation behind the one of the other instance", behind)
+			}
+
+			// the slot that is released on one instance is handed to the reservation that is polled on the other one
+			first.release(t, slot)
+			r, err := second.Wait(ctx, waiting.ID, time.Second)
+			if err != nil || r.Status != partitions.ReservationReady {
+				t.Fatalf("got %v, %v, want the reservation to be ready on the other instance", r, err)
+			}
+			if got, _, err := second.Claim(ctx, waiting.ID, "B1"); err != nil || got != slot {
+				t.Errorf("got %s, %v, want the slot %s", got, err, slot)
+			}
+		})
+	}
+}
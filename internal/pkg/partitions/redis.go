@@ -0,0 +1,67 @@
+package partitions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimScript walks the pairs of a slot and its legacy member in ARGV[2..] in a round robin order starting from the
+// index in ARGV[1] and adds the first slot that is not a member of the partition manager set, a slot whose legacy
+// member is still in the set is in use by a booking that is created before the slots had a topic, since the script
+// runs atomically two callers can never claim the same slot
+var claimScript = redis.NewScript(`
+local start = tonumber(ARGV[1])
+local total = (#ARGV - 1) / 2
+
+for i = 0, total - 1 do
+	local j = ((start + i) % total) * 2 + 2
+	local slot, legacy = ARGV[j], ARGV[j + 1]
+	if legacy == "" or redis.call("SISMEMBER", KEYS[1], legacy) == 0 then
+		if redis.call("SADD", KEYS[1], slot) == 1 then
+			return slot
+		end
+	end
+end
+
+return false
+`)
+
+// RedisClaimer is used to claim slots with a single Lua script on the partition manager set
+type RedisClaimer struct {
+	client *redis.Client
+	key    string
+	// topic is the primary topic whose partitions are added to the set without the topic by the older releases
+	topic string
+}
+
+// NewRedisClaimer is a function that is used to create a claimer on top of the given partition manager set where
+// the given topic is the primary topic of the pool
+func NewRedisClaimer(client *redis.Client, partitionManagerKey, topic string) *RedisClaimer {
+	return &RedisClaimer{
+		client: client,
+		key:    partitionManagerKey,
+		topic:  topic,
+	}
+}
+
+// Claim is used to claim the first free slot of the given slots while starting the search from
+// the given index
+func (r *RedisClaimer) Claim(ctx context.Context, slots []Slot, start int) (Slot, error) {
+	args := make([]any, 0, 2*len(slots)+1)
+	args = append(args, start)
+	for _, slot := range slots {
+		args = append(args, slot.String(), slot.LegacyMember(r.topic))
+	}
+
+	val, err := claimScript.Run(ctx, r.client, []string{r.key}, args...).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return Slot{}, ErrNoPartition
+		}
+		return Slot{}, err
+	}
+
+	return ParseMember(val, r.topic)
+}
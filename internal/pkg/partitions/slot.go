@@ -0,0 +1,64 @@
+package partitions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSlot is an error that occurs when a slot cannot be parsed
+var ErrInvalidSlot = fmt.Errorf("the slot is not valid")
+
+// Slot is a single partition of a topic of the location bus, every active booking owns a single slot
+type Slot struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+}
+
+// String is used to get the "topic:partition" representation of the slot, which is used as the member
+// of the partition manager and as the suffix of the keys of the slot in the state store
+func (s Slot) String() string {
+	return fmt.Sprintf("%s:%d", s.Topic, s.Partition)
+}
+
+// ParseSlot is a function that is used to parse the "topic:partition" representation of a slot
+func ParseSlot(val string) (Slot, error) {
+	// kafka topic names cannot contain a colon
+	i := strings.LastIndex(val, ":")
+	if i <= 0 {
+		return Slot{}, ErrInvalidSlot
+	}
+
+	partition, err := strconv.Atoi(val[i+1:])
+	if err != nil || partition < 0 {
+		return Slot{}, ErrInvalidSlot
+	}
+
+	return Slot{
+		Topic:     val[:i],
+		Partition: partition,
+	}, nil
+}
+
+// ParseMember is a function that is used to parse a member of the partition manager, the members that are added
+// before the slots had a topic only contain the partition so they are parsed as a partition of the given primary topic
+func ParseMember(val, topic string) (Slot, error) {
+	if partition, err := strconv.Atoi(val); err == nil && partition >= 0 {
+		return Slot{
+			Topic:     topic,
+			Partition: partition,
+		}, nil
+	}
+
+	return ParseSlot(val)
+}
+
+// LegacyMember is used to get the member that the slot is added to the partition manager with before the slots had a
+// topic, an empty string is returned when the slot is not a partition of the given primary topic
+func (s Slot) LegacyMember(topic string) string {
+	if s.Topic != topic {
+		return ""
+	}
+
+	return strconv.Itoa(s.Partition)
+}
@@ -0,0 +1,118 @@
+package partitions
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Latency contains the latency of the allocations in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean_ms"`
+	Max  float64 `json:"max_ms"`
+	Last float64 `json:"last_ms"`
+}
+
+// Stats contains the allocation statistics of the allocator since the service was started
+type Stats struct {
+	// Allocated contains the number of times each slot in the pool was allocated
+	Allocated map[string]uint64 `json:"allocated"`
+	Latency   Latency           `json:"latency"`
+	// Fairness is the Jain's fairness index of the allocations across the slots, it is 1
+	// when every slot was allocated the same number of times and 1/total when only a single
+	// slot was ever allocated
+	Fairness    float64 `json:"fairness"`
+	Total       int     `json:"total"`
+	Allocations uint64  `json:"allocations"`
+	// Exhausted is the number of allocations that failed because every slot was in use
+	Exhausted uint64 `json:"exhausted"`
+	// Failures is the number of allocations that failed because of an error in the store
+	Failures uint64 `json:"failures"`
+	Releases uint64 `json:"releases"`
+}
+
+type stats struct {
+	allocations map[Slot]uint64
+	total       time.Duration
+	max         time.Duration
+	last        time.Duration
+	attempts    uint64
+	exhausted   uint64
+	failures    uint64
+	releases    uint64
+	mu          sync.Mutex
+}
+
+func newStats() *stats {
+	return &stats{
+		allocations: make(map[Slot]uint64),
+	}
+}
+
+func (s *stats) allocated(slot Slot, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts++
+	s.total += latency
+	s.last = latency
+	if latency > s.max {
+		s.max = latency
+	}
+
+	switch {
+	case errors.Is(err, ErrNoPartition):
+		s.exhausted++
+	case err != nil:
+		s.failures++
+	default:
+		s.allocations[slot]++
+	}
+}
+
+func (s *stats) released() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releases++
+}
+
+// snapshot is used to get the statistics of the given slots
+func (s *stats) snapshot(slots []Slot) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := Stats{
+		Allocated: make(map[string]uint64, len(slots)),
+		Total:     len(slots),
+		Exhausted: s.exhausted,
+		Failures:  s.failures,
+		Releases:  s.releases,
+		Fairness:  1,
+		Latency: Latency{
+			Max:  milliseconds(s.max),
+			Last: milliseconds(s.last),
+		},
+	}
+	if s.attempts > 0 {
+		snapshot.Latency.Mean = milliseconds(s.total / time.Duration(s.attempts))
+	}
+
+	var sum, squares float64
+	for _, slot := range slots {
+		count := s.allocations[slot]
+		snapshot.Allocated[slot.String()] = count
+		snapshot.Allocations += count
+		sum += float64(count)
+		squares += float64(count) * float64(count)
+	}
+	if squares > 0 {
+		snapshot.Fairness = (sum * sum) / (float64(len(slots)) * squares)
+	}
+
+	return snapshot
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,33 @@
+package partitions
+
+import "context"
+
+// StoreClaimer is used to claim slots through the compare and set operation of the state store
+type StoreClaimer struct {
+	store Store
+}
+
+// NewStoreClaimer is a function that is used to create a claimer on top of the given state store
+func NewStoreClaimer(store Store) *StoreClaimer {
+	return &StoreClaimer{
+		store: store,
+	}
+}
+
+// Claim is used to claim the first free slot of the given slots while starting the search from
+// the given index
+func (s *StoreClaimer) Claim(ctx context.Context, slots []Slot, start int) (Slot, error) {
+	for i := range slots {
+		slot := slots[(start+i)%len(slots)]
+
+		claimed, err := s.store.ClaimPartition(ctx, slot)
+		if err != nil {
+			return Slot{}, err
+		}
+		if claimed {
+			return slot, nil
+		}
+	}
+
+	return Slot{}, ErrNoPartition
+}
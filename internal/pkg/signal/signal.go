@@ -0,0 +1,39 @@
+// Package signal is used to classify how recently a driver has sent a location
+package signal
+
+import "time"
+
+// Status is the signal status of a driver
+type Status string
+
+const (
+	// Live is when the driver has sent a location recently
+	Live Status = "live"
+	// Stale is when the driver has not sent a location for the stale threshold, the driver may be stopped
+	// or the phone may be losing the connection
+	Stale Status = "stale"
+	// Lost is when the driver has not sent a location for the lost threshold, the phone is most likely offline
+	Lost Status = "lost"
+)
+
+// Config contains the thresholds of the signal status
+type Config struct {
+	// Stale is the time without a location after which the signal is stale
+	Stale time.Duration
+	// Lost is the time without a location after which the signal is lost
+	Lost time.Duration
+}
+
+// Classify is used to get the signal status of a driver that last sent a location at the given time
+func (c Config) Classify(lastUpdate, now time.Time) Status {
+	since := now.Sub(lastUpdate)
+
+	switch {
+	case since >= c.Lost:
+		return Lost
+	case since >= c.Stale:
+		return Stale
+	default:
+		return Live
+	}
+}
@@ -0,0 +1,31 @@
+package signal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	config := Config{Stale: 30 * time.Second, Lost: 2 * time.Minute}
+	now := time.UnixMilli(1700000000000)
+
+	cases := map[string]struct {
+		since time.Duration
+		want  Status
+	}{
+		"just now":          {0, Live},
+		"before stale":      {30*time.Second - time.Millisecond, Live},
+		"at stale":          {30 * time.Second, Stale},
+		"before lost":       {2*time.Minute - time.Millisecond, Stale},
+		"at lost":           {2 * time.Minute, Lost},
+		"long ago":          {time.Hour, Lost},
+		"clock ahead of us": {-5 * time.Second, Live},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := config.Classify(now.Add(-tc.since), now); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package track is used to derive the speed, bearing and distance of a booking from its locations
+package track
+
+import (
+	"time"
+
+	"github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+)
+
+// minMovement is the distance in meters that the driver has to move before the track moves, so that the noise of
+// a stationary device does not spin the bearing around or add up to the distance
+const minMovement = 2
+
+// Motion contains the values that are derived from the previous location of the booking
+type Motion struct {
+	// Speed is the ground speed in meters per second
+	Speed float64 `json:"speed"`
+	// Bearing is the direction of travel in degrees clockwise from the north
+	Bearing float64 `json:"bearing"`
+	// Distance is the distance that is travelled since the start of the booking in meters
+	Distance float64 `json:"distance"`
+}
+
+// State is the state of the track of a single booking, it is serialized and kept in the state store between
+// the locations of the booking
+type State struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	// Time is the time of the previous location in unix milliseconds
+	Time    int64   `json:"time"`
+	Bearing float64 `json:"bearing"`
+	// Distance is the distance that is travelled since the start of the booking in meters
+	Distance float64 `json:"distance"`
+}
+
+// Apply is used to move the track to the given location and to get the motion at the given location, false is
+// returned when the location is older than the previous location since the motion can not be derived from it
+func (s *State) Apply(lat, lon float64, at time.Time) (Motion, bool) {
+	if s.Time == 0 {
+		s.Lat = lat
+		s.Lon = lon
+		s.Time = at.UnixMilli()
+
+		return Motion{}, true
+	}
+
+	dt := at.Sub(time.UnixMilli(s.Time)).Seconds()
+	if dt < 0 {
+		return Motion{}, false
+	}
+
+	distance := geo.Distance(s.Lat, s.Lon, lat, lon)
+	speed := 0.0
+	if dt > 0 {
+		speed = distance / dt
+	}
+	if distance < minMovement {
+		// the previous location is kept until the driver moves far enough, so that the small movements still add
+		// up to the distance
+		return Motion{
+			Speed:    speed,
+			Bearing:  s.Bearing,
+			Distance: s.Distance,
+		}, true
+	}
+
+	s.Bearing = geo.Bearing(s.Lat, s.Lon, lat, lon)
+	s.Lat = lat
+	s.Lon = lon
+	s.Time = at.UnixMilli()
+	s.Distance += distance
+
+	return Motion{
+		Speed:    speed,
+		Bearing:  s.Bearing,
+		Distance: s.Distance,
+	}, true
+}
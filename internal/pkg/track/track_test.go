@@ -0,0 +1,72 @@
+package track
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// oneMeter is roughly the number of degrees of latitude in a meter
+const oneMeter = 1.0 / 111195
+
+func TestApply(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+	var state State
+
+	motion, ok := state.Apply(51.5, -0.12, start)
+	if !ok || motion != (Motion{}) {
+		t.Fatalf("got %+v, %t, want the first location to start the track without any motion", motion, ok)
+	}
+
+	// 100 meters north in 10 seconds
+	motion, ok = state.Apply(51.5+100*oneMeter, -0.12, start.Add(10*time.Second))
+	if !ok {
+		t.Fatal("want the motion to be derived")
+	}
+	if math.Abs(motion.Speed-10) > 0.01 || math.Abs(motion.Distance-100) > 0.1 {
+		t.Errorf("got %+v, want a speed of 10 m/s after 100 meters", motion)
+	}
+	if motion.Bearing > 1e-6 {
+		t.Errorf("got a bearing of %f, want north", motion.Bearing)
+	}
+
+	// 100 meters east in another 10 seconds
+	lon := -0.12 + 100*oneMeter/math.Cos(51.5*math.Pi/180)
+	motion, _ = state.Apply(51.5+100*oneMeter, lon, start.Add(20*time.Second))
+	if math.Abs(motion.Bearing-90) > 0.1 || math.Abs(motion.Distance-200) > 0.5 {
+		t.Errorf("got %+v, want to head east after 200 meters", motion)
+	}
+}
+
+func TestApplyStationary(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+	var state State
+	state.Apply(51.5, -0.12, start)
+	state.Apply(51.5+100*oneMeter, -0.12, start.Add(10*time.Second))
+
+	// the noise of a stationary device does not move the track or spin the bearing around
+	for i := 1; i <= 3; i++ {
+		motion, ok := state.Apply(51.5+100*oneMeter, -0.12+0.5*oneMeter*float64(i%2), start.Add(time.Duration(10+i)*time.Second))
+		if !ok || motion.Bearing > 1e-6 || math.Abs(motion.Distance-100) > 0.1 {
+			t.Errorf("got %+v, want the bearing and the distance to stay", motion)
+		}
+	}
+
+	// the small movements still add up once the driver moves far enough from the previous location
+	motion, _ := state.Apply(51.5+103*oneMeter, -0.12, start.Add(20*time.Second))
+	if math.Abs(motion.Distance-103) > 0.1 {
+		t.Errorf("got a distance of %f, want 103", motion.Distance)
+	}
+}
+
+func TestApplyOlder(t *testing.T) {
+	start := time.UnixMilli(1700000000000)
+	var state State
+	state.Apply(51.5, -0.12, start)
+
+	// the motion can not be derived from a location that is older than the previous location
+	before := state
+	if _, ok := state.Apply(51.6, -0.12, start.Add(-time.Second)); ok || state != before {
+		t.Errorf("got %+v, want the older location to be ignored", state)
+	}
+}
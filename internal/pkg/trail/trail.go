@@ -0,0 +1,77 @@
+// Package trail is used to simplify the path that a driver has taken so far before it is sent to a viewer
+package trail
+
+import "github.com/flitlabs/spotoncars_stream/internal/pkg/geo"
+
+// Config contains the settings of the simplification of the path
+type Config struct {
+	// Tolerance is the largest distance in meters between a point that is left out and the simplified path
+	Tolerance float64
+	// MaxPoints is the largest number of points of the simplified path, the tolerance is raised until the path
+	// fits in it
+	MaxPoints int
+}
+
+// Point is a coordinate of the path
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Simplify is a function that is used to get the indexes of the points of the given path that are kept with the
+// Douglas-Peucker algorithm, the first and the last points are always kept and the indexes are in order
+func Simplify(points []Point, config Config) []int {
+	if len(points) <= 2 {
+		indexes := make([]int, len(points))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+
+	tolerance := config.Tolerance
+	for {
+		kept := make([]bool, len(points))
+		kept[0], kept[len(points)-1] = true, true
+		simplify(points, kept, 0, len(points)-1, tolerance)
+
+		indexes := make([]int, 0, len(points))
+		for i, ok := range kept {
+			if ok {
+				indexes = append(indexes, i)
+			}
+		}
+		if config.MaxPoints <= 0 || len(indexes) <= max(config.MaxPoints, 2) {
+			return indexes
+		}
+
+		// the path is simplified again with a larger tolerance until it fits in the maximum number of points
+		tolerance = max(tolerance*2, 1)
+	}
+}
+
+// simplify is a function that is used to mark the points between the given first and last points that are further
+// away than the given tolerance from the segment between them
+func simplify(points []Point, kept []bool, first, last int, tolerance float64) {
+	// the segments are kept in a stack instead of recursing so that long paths do not grow the stack
+	stack := [][2]int{{first, last}}
+	for len(stack) > 0 {
+		segment := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		start, end := points[segment[0]], points[segment[1]]
+		farthest, distance := -1, tolerance
+		for i := segment[0] + 1; i < segment[1]; i++ {
+			d := geo.SegmentDistance(points[i].Lat, points[i].Lon, start.Lat, start.Lon, end.Lat, end.Lon)
+			if d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+
+		kept[farthest] = true
+		stack = append(stack, [2]int{segment[0], farthest}, [2]int{farthest, segment[1]})
+	}
+}